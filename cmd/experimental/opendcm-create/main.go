@@ -1,16 +1,11 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
 
 	od "github.com/b71729/opendcm"
 )
@@ -36,7 +31,12 @@ func usage() {
 }
 
 func main() {
-	od.GetConfig()
+	// the output is synthetic, so is identified as such unless configured otherwise
+	cfg := od.GetConfig()
+	if cfg.ImplementationClassUID == "" {
+		cfg.ImplementationClassUID = od.GetImplementationUID(true)
+		od.OverrideConfig(cfg)
+	}
 	if len(os.Args) == 2 && (os.Args[1] == "--help" || os.Args[1] == "-h") {
 		usage()
 	}
@@ -48,112 +48,76 @@ func main() {
 		od.Fatalf(`file "%s" already exists`, outFileName)
 	}
 
-	buffer := writeMeta()
-
-	// write output
-	f, err := os.Create(outFileName)
-	check(err)
-	nwrite, err := f.Write(buffer)
-	check(err)
-	if nwrite != len(buffer) {
-		od.Fatalf("could not write all meta elements to disk. nwrite=%d bytes, size=%d bytes", nwrite, len(buffer))
+	ds := make(od.DataSet)
+	add := func(e od.Element) {
+		ds[e.GetTag()] = e
 	}
 
-	od.Info("wrote meta information to disk")
-
-	elementBuffer := make([]byte, 0)
+	// the remainder of the meta group is written by the package
+	// Use 1.2.840.10008.5.1.4.1.1.66 (Raw Data Storage), but may need to be adjusted.
+	add(element(0x00020002, "1.2.840.10008.5.1.4.1.1.66"))
+	randUID, err := od.NewRandInstanceUID()
+	check(err)
+	add(element(0x00020003, randUID))
 
 	/// VRs with defined length
 	// AE
-	elementBytes, err := generateElement("0072,005E", []byte("AENAME"), "AE")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072005E, "AENAME"))
 
 	// AS
-	elementBytes, err = generateElement("0072,005F", []byte("012Y"), "AS")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072005F, "012Y"))
 
 	// AT
-	elementBytes, err = generateElement("0072,0060", []byte{0x42, 0x24, 0x01, 0x90}, "AT")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720060, []byte{0x42, 0x24, 0x01, 0x90}))
 
 	// CS
-	elementBytes, err = generateElement("0072,0062", []byte("CODESTRING_1"), "CS")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720062, "CODESTRING_1"))
 
 	// DA
-	elementBytes, err = generateElement("0072,0061", []byte("20180317"), "DA")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720061, "20180317"))
 
 	// DS
-	elementBytes, err = generateElement("0072,0072", []byte("360.8"), "DS")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720072, "360.8"))
 
 	// DT
-	elementBytes, err = generateElement("0072,0063", []byte("200508101215"), "DT")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720063, "200508101215"))
 
 	// FL
 	buf := make([]byte, 4)
 	binary.LittleEndian.PutUint32(buf, math.Float32bits(127.50812))
-	elementBytes, err = generateElement("0072,0076", buf, "FL")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720076, buf))
 
 	// FD
 	buf = make([]byte, 8)
 	binary.LittleEndian.PutUint64(buf, math.Float64bits(123456.123456789))
-	elementBytes, err = generateElement("0072,0074", buf, "FD")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720074, buf))
 
 	// IS
-	elementBytes, err = generateElement("0072,0064", []byte("0123456789"), "IS")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720064, "0123456789"))
 
 	// LO
-	elementBytes, err = generateElement("0072,0066", []byte(`Long String`), "LO")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720066, `Long String`))
 
 	// LT
-	elementBytes, err = generateElement("0072,0068", []byte(`Long\Text\No\Split`), "LT")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720068, `Long\Text\No\Split`))
 
 	// OB
-	elementBytes, err = generateElement("0072,0065", []byte{0x01, 0x02, 0x03, 0x04}, "OB")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720065, []byte{0x01, 0x02, 0x03, 0x04}))
 
 	// OB of undefined length
-	buf = genItemBytesRaw([]byte{0x01, 0x02, 0x03, 0x04}, 4)
-	elementBytes, err = generateElementWithLength("7FE0,0010", buf, "OB", 0xFFFFFFFF)
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x7FE00010, [][]byte{{0x01, 0x02, 0x03, 0x04}}))
 
 	// OD
 	buf = make([]byte, 16)
 	binary.LittleEndian.PutUint64(buf[0:], 888888887)
 	binary.LittleEndian.PutUint64(buf[8:], 777777778)
-	elementBytes, err = generateElement("0072,0073", buf, "OD")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720073, buf))
 
 	// OF
 	buf = make([]byte, 8)
 	binary.LittleEndian.PutUint32(buf[0:], math.Float32bits(123.4))
 	binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(567.8))
-	elementBytes, err = generateElement("0072,0067", buf, "OF")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720067, buf))
 
 	// OW
 	buf = make([]byte, 16)
@@ -161,246 +125,92 @@ func main() {
 	binary.LittleEndian.PutUint32(buf[4:], 8765)
 	binary.LittleEndian.PutUint32(buf[8:], 2109)
 	binary.LittleEndian.PutUint32(buf[12:], 6543)
-	elementBytes, err = generateElement("0072,0069", buf, "OW")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720069, buf))
 
 	// PN
-	elementBytes, err = generateElement("0072,006A", []byte(`Anderson^Leo`), "PN")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072006A, `Anderson^Leo`))
 
 	// SH
-	elementBytes, err = generateElement("0072,006C", []byte(`Short String`), "SH")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072006C, `Short String`))
 
 	// SL
 	buf = make([]byte, 4)
 	v := int32(-1234)
 	binary.LittleEndian.PutUint32(buf[0:], uint32(v))
-	elementBytes, err = generateElement("0072,007C", buf, "SL")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
-
-	// SQ
-	buf = make([]byte, 0)
+	add(element(0x0072007C, buf))
 
-	// SQ Encoding 5.12.1: undefined-len SQ with defined-len items
-	asBytes := genItemBytes("0072,005F", []byte("012Y"), "AS", 4)
-	stBytes := genItemBytes("0072,006E", []byte(`Unlimited\Text`), "UT", 14)
-	buf = append(buf, asBytes...)
-	buf = append(buf, stBytes...)
-	elementBytes, err = generateElementWithLength("0072,0080", buf, "SQ", 0xFFFFFFFF)
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	// SQ (sequences and their items are always written with undefined length)
+	// SQ of two items, each holding a single element
+	add(sequence(0x00720080, item(element(0x0072005F, "012Y")), item(element(0x0072006E, `Unlimited\Text`))))
 
-	// SQ Encoding 5.12.3: undefined-len SQ with undefined-len items
-	nestedAS := genItemBytes("0072,005F", []byte("012Y"), "AS", 4)
-	sequenceItem := genItemBytes("0072,0080", nestedAS, "SQ", 0xFFFFFFFF)
-	for i := 0; i < 4; i++ {
-		sequenceItem = genItemBytes("0072,0080", sequenceItem, "SQ", 0xFFFFFFFF)
+	// SQ of nested sequences
+	sequenceItem := item(element(0x0072005F, "012Y"))
+	for i := 0; i < 5; i++ {
+		sequenceItem = item(sequence(0x00720080, sequenceItem))
 	}
-
-	elementBytes, err = generateElementWithLength("0008,9121", sequenceItem, "SQ", 0xFFFFFFFF)
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(sequence(0x00089121, sequenceItem))
 
 	// SS
 	buf = make([]byte, 2)
 	v2 := int16(-1234)
 	binary.LittleEndian.PutUint16(buf[0:], uint16(v2))
-	elementBytes, err = generateElement("0072,007E", buf, "SS")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072007E, buf))
 
 	// ST
-	elementBytes, err = generateElement("0072,006E", []byte(`Short\Text\No\Split`), "ST")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072006E, `Short\Text\No\Split`))
 
 	// TM
-	elementBytes, err = generateElement("0072,006B", []byte(`121530.35`), "TM")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072006B, `121530.35`))
 
 	// UI
-	elementBytes, err = generateElement("0072,007F", []byte(`127.0.0.1`), "UI")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072007F, `127.0.0.1`))
 
 	// UL
 	buf = make([]byte, 4)
 	v3 := uint32(123456789)
 	binary.LittleEndian.PutUint32(buf[0:], v3)
-	elementBytes, err = generateElement("0072,0078", buf, "UL")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x00720078, buf))
 
 	// UN
-	elementBytes, err = generateElement("0072,006D", []byte("UnknownData"), "UN")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072006D, []byte("UnknownData")))
 
 	// US
 	buf = make([]byte, 2)
 	v4 := uint16(12345)
 	binary.LittleEndian.PutUint16(buf[0:], v4)
-	elementBytes, err = generateElement("0072,007A", buf, "US")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
+	add(element(0x0072007A, buf))
 
 	// UT
-	elementBytes, err = generateElement("0072,0070", []byte(`Unlimited\Text\No\Split`), "UT")
-	check(err)
-	elementBuffer = append(elementBuffer, elementBytes...)
-
-	nwrite, err = f.Write(elementBuffer)
-	check(err)
-	if nwrite != len(elementBuffer) {
-		od.Fatalf("could not write all elements to disk. nwrite=%d bytes, size=%d bytes", nwrite, len(elementBuffer))
-	}
-
-	od.Info("wrote elements to disk")
-
-	defer f.Close()
-}
-
-// TODO: move to common
-func tagStringToTagUint32(tag string) (uint32, error) {
-	tagString := strings.Replace(tag, ",", "", 1)
-	tagInt, err := strconv.ParseUint(tagString, 16, 32)
-	return uint32(tagInt), err
-}
-
-func generateElement(tagString string, value []byte, VR string) ([]byte, error) {
-	return generateElementWithLength(tagString, value, VR, uint32(len(value)))
-}
-
-// NOTE: Explicit VR, Little Endian
-func generateElementWithLength(tagString string, value []byte, VR string, length uint32) ([]byte, error) {
-	ret := make([]byte, 4)
-	tag, err := tagStringToTagUint32(tagString)
-	if err != nil {
-		return ret, nil
-	}
-	binary.LittleEndian.PutUint16(ret[0:], uint16(tag>>16))
-	binary.LittleEndian.PutUint16(ret[2:], uint16(tag))
-	ret = append(ret, []byte(VR)...)
-
-	if length > 0 && length < 0xFFFFFFFF {
-		// deal with padding
-		switch VR {
-		case "UI", "OB", "CS", "DS", "IS", "AE", "AS", "DA", "DT", "LO", "LT", "OD", "OF", "OW", "PN", "SH", "ST", "TM", "UT":
-			if length%2 != 0 {
-				value = append(value, 0x00)
-				length++
-			}
-		}
-	}
-
-	switch VR {
-	case "OB", "OW", "SQ", "UN", "UT":
-		if length > 0xFFFFFFFF {
-			return nil, errors.New("value length would overflow uint32")
-		}
-		// write length
-		ret = append(ret, make([]byte, 2)...) // skip two bytes
-		ret = append(ret, make([]byte, 4)...)
-		binary.LittleEndian.PutUint32(ret[len(ret)-4:], length)
-	default:
-		if length > 0xFFFF {
-			return nil, errors.New("value length would overflow uint16")
-		}
-		// write length
-		ret = append(ret, make([]byte, 2)...)
-		binary.LittleEndian.PutUint16(ret[len(ret)-2:], uint16(length))
-	}
-	if length > 0 {
-		ret = append(ret, value...)
-	}
-	if length == 0xFFFFFFFF {
-		ret = append(ret, []byte{
-			0xFE, 0xFF, 0xDD, 0xE0, // 4b: sequence end tag
-			0x00, 0x00, 0x00, 0x00, // 4b: filler
-		}...)
-	}
-	return ret, nil
-}
+	add(element(0x00720070, `Unlimited\Text\No\Split`))
 
-// TODO: move to common
-func elementFromBuffer(buf []byte) (od.Element, error) {
-	r := bufio.NewReader(bytes.NewReader(buf))
-	es := od.NewElementStream(r, int64(len(buf)))
-	return es.GetElement()
+	// write output
+	check(ds.WriteToFile(outFileName, od.ExplicitVRLittleEndian))
+	od.Infof("wrote %d elements to %s", ds.Len(), outFileName)
 }
 
-func writeMeta() []byte {
-	buffer := make([]byte, 128)
-	buffer = append(buffer, []byte("DICM")...)
-
-	// 0002,0001 File Meta Version
-	elementBytes, err := generateElement("0002,0001", []byte{0x00, 0x01}, "OB")
-	check(err)
-	buffer = append(buffer, elementBytes...)
-
-	// 0002,0002 Media Storage SOP Class UID
-	// Use 1.2.840.10008.5.1.4.1.1.66 (Raw Data Storage), but may need to be adjusted.
-	elementBytes, err = generateElement("0002,0002", []byte("1.2.840.10008.5.1.4.1.1.66"), "UI")
-	check(err)
-	buffer = append(buffer, elementBytes...)
-
-	// 0002,0003 Media Storage SOP Instance UID
-	randUID, err := od.NewRandInstanceUID()
-	check(err)
-	elementBytes, err = generateElement("0002,0003", []byte(randUID), "UI")
-	check(err)
-	buffer = append(buffer, elementBytes...)
-
-	// 0002,0010 Transfer Syntax UID
-	elementBytes, err = generateElement("0002,0010", []byte("1.2.840.10008.1.2.1"), "UI")
-	check(err)
-	buffer = append(buffer, elementBytes...)
-
-	// 0002,0012 Implementation Class UID
-	elementBytes, err = generateElement("0002,0012", []byte(od.GetImplementationUID(true)), "UI")
-	check(err)
-	buffer = append(buffer, elementBytes...)
-
-	// (0002,0013)    Implementation Version Name    opendcm-0.1
-	elementBytes, err = generateElement("0002,0013", []byte(fmt.Sprintf("opendcm-%s", od.OpenDCMVersion)), "SH")
-	check(err)
-	buffer = append(buffer, elementBytes...)
-
-	// Now return to File Meta Length and populate
-	val := make([]byte, 4)
-	binary.LittleEndian.PutUint32(val, uint32(len(buffer)-132))
-	elementBytes, err = generateElement("0002,0000", val, "UL")
-	check(err)
-	buffer = append(buffer[:132], append(elementBytes, buffer[132:]...)...)
-	return buffer
+// element returns the element indexed by `tag`, whose value is set from `value`.
+// See: `Element.SetValue`
+func element(tag uint32, value interface{}) od.Element {
+	e := od.NewElementWithTag(tag)
+	check(e.SetValue(value))
+	return e
 }
 
-func genItemBytesRaw(value []byte, length uint32) []byte {
-	outBytes := []byte{0xFE, 0xFF, 0x00, 0xE0}
-	outBytes = append(outBytes, make([]byte, 4)...)
-	binary.LittleEndian.PutUint32(outBytes[4:], length)
-	outBytes = append(outBytes, value...)
-	if length == 0xFFFFFFFF {
-		outBytes = append(outBytes, []byte{
-			0xFE, 0xFF, 0x0D, 0xE0, // 4b: item #1 end tag
-			0x00, 0x00, 0x00, 0x00, // 4b: filler
-		}...)
+// sequence returns the sequence indexed by `tag`, holding `items`.
+func sequence(tag uint32, items ...od.Item) od.Element {
+	e := od.NewElementWithTag(tag)
+	for _, itm := range items {
+		e.AddItem(itm)
 	}
-	return outBytes
+	return e
 }
 
-func genItemBytes(tagString string, value []byte, VR string, length uint32) []byte {
-	el, err := generateElementWithLength(tagString, value, VR, length)
-	if err != nil {
-		panic(err)
+// item returns an item whose data set holds `elements`.
+func item(elements ...od.Element) od.Item {
+	itm := od.NewItem()
+	ds := itm.GetDataSet()
+	for _, e := range elements {
+		ds[e.GetTag()] = e
 	}
-	outBytes := genItemBytesRaw(el, length)
-	return outBytes
-
+	return itm
 }
@@ -5,6 +5,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	od "github.com/b71729/opendcm"
 )
@@ -57,27 +59,36 @@ func main() {
 	}
 
 	seriesInstanceUIDs := make(map[string]bool, 0)
-	od.ConcurrentlyWalkDir(dirIn, func(filePath string) {
-		dcm, err := od.ParseDicom(filePath)
+	var mu sync.Mutex
+	onProgress := func(p od.WalkProgress) {
+		od.Infof("processed %d/%d files (%d/%d bytes)", p.FilesProcessed, p.FilesTotal, p.BytesProcessed, p.BytesTotal)
+	}
+	err = od.ConcurrentlyWalkDirWithProgress(dirIn, func(filePath string) {
+		dcm, err := od.FromFile(filePath)
 		check(err)
-		if e, found := dcm.GetElement(0x0020000E); found {
-			if val, ok := e.Value().(string); ok {
-				_, found := seriesInstanceUIDs[val]
-				if !found {
-					od.Infof("found unique: %s", val)
-					seriesInstanceUIDs[val] = true
-					outputFilePath := filepath.Join(dirOut, fmt.Sprintf("%s.dcm", val))
-					if _, err := os.Stat(outputFilePath); os.IsNotExist(err) {
-						// file does not exist - lets create it
-						err := copy(dcm.FilePath, outputFilePath)
-						check(err)
-					} else {
-						od.Infof(`skip "%s": file exists`, outputFilePath)
-					}
-				}
-			}
+		_, val, _, _, err := dcm.Identifiers()
+		if err != nil {
+			od.Infof(`skip "%s": %v`, filePath, err)
+			return
+		}
+		mu.Lock()
+		_, found := seriesInstanceUIDs[val]
+		seriesInstanceUIDs[val] = true
+		mu.Unlock()
+		if found {
+			return
 		}
-	})
+		od.Infof("found unique: %s", val)
+		outputFilePath := filepath.Join(dirOut, fmt.Sprintf("%s.dcm", val))
+		if _, err := os.Stat(outputFilePath); os.IsNotExist(err) {
+			// file does not exist - lets create it
+			err := copy(filePath, outputFilePath)
+			check(err)
+		} else {
+			od.Infof(`skip "%s": file exists`, outputFilePath)
+		}
+	}, time.Second, onProgress)
+	check(err)
 }
 
 // copy the src file to dst. Any existing file will be overwritten and will not
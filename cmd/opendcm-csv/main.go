@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	od "github.com/b71729/opendcm"
+)
+
+/*
+===============================================================================
+    Util: Export DICOM Elements as CSV
+===============================================================================
+*/
+
+var baseFile = filepath.Base(os.Args[0])
+
+func check(err error) {
+	if err != nil {
+		od.FatalfDepth(3, "error: %v", err)
+	}
+}
+
+func usage() {
+	fmt.Printf("OpenDCM version %s\n", od.OpenDCMVersion)
+	fmt.Printf("usage: %s in_dir tag [tag...]\n", baseFile)
+	fmt.Println("tags may be given by name (PatientID) or by group and element (0010,0020)")
+	os.Exit(1)
+}
+
+// parseTag resolves `arg` to a tag, either by dictionary name or as "gggg,eeee".
+func parseTag(arg string) (uint32, error) {
+	if tag, found := od.LookupTagByName(arg); found {
+		return tag, nil
+	}
+	tag, err := strconv.ParseUint(strings.Replace(strings.Trim(arg, "()"), ",", "", 1), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf(`"%s" is neither a recognised element name nor a tag`, arg)
+	}
+	return uint32(tag), nil
+}
+
+func main() {
+	if len(os.Args) == 2 && (os.Args[1] == "--help" || os.Args[1] == "-h") {
+		usage()
+	}
+	if len(os.Args) < 3 {
+		usage()
+	}
+	tags := make([]uint32, 0)
+	for _, arg := range os.Args[2:] {
+		tag, err := parseTag(arg)
+		check(err)
+		tags = append(tags, tag)
+	}
+	// only the header of each file is read, as per `ExportMetadata`
+	check(od.ExportMetadata(os.Args[1], os.Stdout, "csv", tags))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	od "github.com/b71729/opendcm"
+)
+
+/*
+===============================================================================
+    Util: Print a Single Element's Value
+===============================================================================
+*/
+
+var baseFile = filepath.Base(os.Args[0])
+
+func check(err error) {
+	if err != nil {
+		od.FatalfDepth(3, "error: %v", err)
+	}
+}
+
+func usage() {
+	fmt.Printf("OpenDCM version %s\n", od.OpenDCMVersion)
+	fmt.Printf("usage: %s in_file tag\n", baseFile)
+	fmt.Println("the tag may be given by name (PatientID) or by group and element (00100020)")
+	fmt.Println("exits with status 1 if the element is absent")
+	os.Exit(1)
+}
+
+// getElement writes the element identified by `arg`, either by dictionary name or as "ggggeeee",
+// into `dst`. its return value indicates whether the dicom contains said element.
+func getElement(dcm od.Dicom, arg string, dst *od.Element) (bool, error) {
+	if _, found := od.LookupTagByName(arg); found {
+		return dcm.GetElementByName(arg, dst), nil
+	}
+	tag, err := strconv.ParseUint(strings.Replace(strings.Trim(arg, "()"), ",", "", 1), 16, 32)
+	if err != nil {
+		return false, fmt.Errorf(`"%s" is neither a recognised element name nor a tag`, arg)
+	}
+	return dcm.GetElement(uint32(tag), dst), nil
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		usage()
+	}
+	// informational output would be interleaved with the value upon stdout
+	od.SetLoggingLevel("error")
+	dcm, err := od.FromFile(os.Args[1])
+	check(err)
+	e := od.NewElement()
+	found, err := getElement(dcm, os.Args[2], &e)
+	check(err)
+	if !found {
+		os.Exit(1)
+	}
+	fmt.Println(e.DescribeValue())
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	od "github.com/b71729/opendcm"
+)
+
+/*
+===============================================================================
+    Util: Redact DICOM File
+===============================================================================
+*/
+
+var baseFile = filepath.Base(os.Args[0])
+
+func check(err error) {
+	if err != nil {
+		od.FatalfDepth(3, "error: %v", err)
+	}
+}
+
+func usage() {
+	fmt.Printf("OpenDCM version %s\n", od.OpenDCMVersion)
+	fmt.Printf("usage: %s in_file out_file\n", baseFile)
+	fmt.Println("  writes a copy of in_file whose text values and pixel data have been redacted,")
+	fmt.Println("  but whose structure is preserved, such that it may be shared in bug reports")
+	fmt.Println("  sequences are written with undefined length, whatever their original framing")
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		usage()
+	}
+	// the files worth reporting are those which cannot be parsed, so the elements read before
+	// the failure are redacted nonetheless
+	dcm, err := od.FromFile(os.Args[1])
+	if err != nil {
+		if dcm.Len() == 0 {
+			check(err)
+		}
+		od.Warnf("error parsing %s: %v; only the %d elements preceding the error will be written", os.Args[1], err, dcm.Len())
+	}
+	redacted := dcm.Redact()
+	check(redacted.WriteToFile(os.Args[2]))
+}
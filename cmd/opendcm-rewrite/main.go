@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	od "github.com/b71729/opendcm"
+)
+
+/*
+===============================================================================
+    Util: Rewrite DICOM File
+===============================================================================
+*/
+
+var baseFile = filepath.Base(os.Args[0])
+
+func check(err error) {
+	if err != nil {
+		od.FatalfDepth(3, "error: %v", err)
+	}
+}
+
+func usage() {
+	fmt.Printf("OpenDCM version %s\n", od.OpenDCMVersion)
+	fmt.Printf("usage: %s [--strip-retired] in_file out_file\n", baseFile)
+	fmt.Println("  --strip-retired: remove elements which have been retired from the standard")
+	os.Exit(1)
+}
+
+func main() {
+	args := os.Args[1:]
+	stripRetired := false
+	if len(args) > 0 && args[0] == "--strip-retired" {
+		stripRetired = true
+		args = args[1:]
+	}
+	if len(args) != 2 {
+		usage()
+	}
+	dcm, err := od.FromFile(args[0])
+	check(err)
+	if stripRetired {
+		before := dcm.Len()
+		dcm.DataSet = dcm.NonRetired()
+		od.Infof("removed %d retired elements", before-dcm.Len())
+	}
+	check(dcm.WriteToFile(args[1]))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	od "github.com/b71729/opendcm"
+)
+
+/*
+===============================================================================
+    Util: Validate a Directory of DICOM Files
+===============================================================================
+*/
+
+var baseFile = filepath.Base(os.Args[0])
+
+func check(err error) {
+	if err != nil {
+		od.FatalfDepth(3, "error: %v", err)
+	}
+}
+
+func usage() {
+	fmt.Printf("OpenDCM version %s\n", od.OpenDCMVersion)
+	fmt.Printf("usage: %s in_dir report.json\n", baseFile)
+	fmt.Println("parses and validates each file within in_dir, writing a JSON report of the issues found")
+	os.Exit(1)
+}
+
+// FileReport lists the problems found with one file.
+type FileReport struct {
+	Path string `json:"path"`
+	// Error is set if the file could not be parsed, and ErrorKind describes why
+	Error     string   `json:"error,omitempty"`
+	ErrorKind string   `json:"errorKind,omitempty"`
+	Issues    []string `json:"issues,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// Summary counts the files by outcome.
+type Summary struct {
+	Files      int `json:"files"`
+	Valid      int `json:"valid"`
+	Invalid    int `json:"invalid"`
+	Unreadable int `json:"unreadable"`
+}
+
+// Report is written to the report file.
+type Report struct {
+	Summary Summary      `json:"summary"`
+	Files   []FileReport `json:"files"`
+}
+
+// errorKind categorises a parse error.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, od.ErrCorruptElement):
+		return "corrupt element"
+	case errors.Is(err, od.ErrCorruptDicom):
+		return "corrupt dicom"
+	}
+	return "unreadable"
+}
+
+// checkElements appends an issue to `issues` for each element within `ds`, including those
+// nested within sequences, whose value exceeds the length permitted by its VR.
+func checkElements(ds od.DataSet, issues []string) []string {
+	tags := make([]int, 0, len(ds))
+	for tag := range ds {
+		tags = append(tags, int(tag))
+	}
+	sort.Ints(tags)
+	for _, tag := range tags {
+		e := ds[uint32(tag)]
+		if !e.ConformsToVRLength() {
+			issues = append(issues, fmt.Sprintf("(%04X,%04X) %s: value does not conform to the length permitted for VR %s",
+				tag>>16, tag&0xFFFF, e.GetName(), e.GetVR()))
+		}
+		for _, itm := range e.GetItems() {
+			issues = checkElements(itm.GetDataSet(), issues)
+		}
+	}
+	return issues
+}
+
+// validate returns the report for a parsed file.
+func validate(result od.ParseResult) FileReport {
+	report := FileReport{Path: result.Path, Issues: make([]string, 0)}
+	if result.Err != nil {
+		report.Error, report.ErrorKind = result.Err.Error(), errorKind(result.Err)
+		return report
+	}
+	dcm := result.Dicom
+	report.Warnings = dcm.Warnings()
+	if err := dcm.ValidateMeta(); err != nil {
+		report.Issues = append(report.Issues, err.Error())
+	}
+	if err := dcm.CheckAlignment(); err != nil {
+		report.Issues = append(report.Issues, err.Error())
+	}
+	report.Issues = checkElements(dcm.DataSet, report.Issues)
+	return report
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		usage()
+	}
+	od.SetLoggingLevel("error")
+	report := Report{Files: make([]FileReport, 0)}
+	for result := range od.ParseDirectory(os.Args[1], od.GetConfig().OpenFileLimit) {
+		file := validate(result)
+		switch {
+		case file.Error != "":
+			report.Summary.Unreadable++
+		case len(file.Issues) > 0:
+			report.Summary.Invalid++
+		default:
+			report.Summary.Valid++
+		}
+		report.Files = append(report.Files, file)
+	}
+	report.Summary.Files = len(report.Files)
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].Path < report.Files[j].Path })
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	check(err)
+	check(ioutil.WriteFile(os.Args[2], append(out, '\n'), 0644))
+	fmt.Printf("%d files: %d valid, %d invalid, %d unreadable\n", report.Summary.Files,
+		report.Summary.Valid, report.Summary.Invalid, report.Summary.Unreadable)
+}
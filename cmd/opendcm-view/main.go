@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"image/png"
 	"os"
 	"path/filepath"
+	"time"
 
 	od "github.com/b71729/opendcm"
 )
@@ -41,15 +44,28 @@ func main() {
 		dcm, err := od.FromFile(os.Args[1])
 		check(err)
 		for _, element := range dcm.DataSet {
-			fmt.Printf("%08x = %v\n", element.GetTag(), element.GetName())
+			fmt.Println(element.Describe())
 		}
 		pd := dcm.GetPixelData()
 		fmt.Printf("NUM PIXEL FRAMES: %d\n", pd.NumFrames())
 		for i := 0; i < pd.NumFrames(); i++ {
-			fmt.Printf("Frame: (len %d)\n", len(pd.GetFrame(i)))
+			img, err := dcm.Frame(i)
+			if err == nil {
+				f, err := os.Create(fmt.Sprintf("frame-%d.png", i))
+				check(err)
+				check(png.Encode(f, img))
+				f.Close()
+				continue
+			}
+			if !errors.Is(err, od.ErrUnsupportedPixelEncoding) {
+				check(err)
+			}
+			// no decoder is available; dump the raw frame instead
+			frame := pd.FrameTrimmed(i)
+			fmt.Printf("Frame: (len %d)\n", len(frame))
 			f, err := os.Create(fmt.Sprintf("frame-%d.jpg", i))
 			check(err)
-			f.Write(pd.GetFrame(i)[:len(pd.GetFrame(i))-1])
+			f.Write(frame)
 			f.Close()
 		}
 		tsuid := ""
@@ -62,7 +78,10 @@ func main() {
 	} else {
 		errorCount := 0
 		successCount := 0
-		err := od.ConcurrentlyWalkDir(os.Args[1], func(path string) {
+		onProgress := func(p od.WalkProgress) {
+			od.Infof("processed %d/%d files (%d/%d bytes)", p.FilesProcessed, p.FilesTotal, p.BytesProcessed, p.BytesTotal)
+		}
+		err := od.ConcurrentlyWalkDirWithProgress(os.Args[1], func(path string) {
 			_, err := od.FromFile(path)
 			check(err)
 			basePath := filepath.Base(path)
@@ -73,7 +92,7 @@ func main() {
 			}
 			successCount++
 			od.Debugf(`parsed "%s"`, basePath)
-		})
+		}, time.Second, onProgress)
 		check(err)
 		if errorCount == 0 {
 			od.Infof("parsed %d files without errors", successCount)
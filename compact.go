@@ -0,0 +1,149 @@
+package opendcm
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+/*
+===============================================================================
+	Compact DataSet
+	---
+	Provides a slimmer in-memory representation of a parsed data set, for use
+	cases such as indexing large archives, where many data sets are retained
+	at once. Dictionary lookups are deferred until an element is accessed.
+===============================================================================
+*/
+
+// CompactElement holds only the tag, source VR and value of an element.
+// Items within sequences are not retained; only whether the element had any.
+type CompactElement struct {
+	tag            uint32
+	vr             [2]byte
+	isLittleEndian bool
+	hasItems       bool
+	data           []byte
+}
+
+// CompactDataSet provides a mapping between tag and CompactElement.
+type CompactDataSet map[uint32]CompactElement
+
+// newCompactElement returns the compact representation of `e`.
+func newCompactElement(e Element) CompactElement {
+	ce := CompactElement{
+		tag:            e.GetTag(),
+		isLittleEndian: e.isLittleEndian,
+		hasItems:       e.HasItems(),
+		data:           e.data,
+	}
+	copy(ce.vr[:], e.GetVR())
+	return ce
+}
+
+// Compact returns the compact representation of the data set.
+func (ds *DataSet) Compact() CompactDataSet {
+	cds := make(CompactDataSet, len(*ds))
+	for tag, e := range *ds {
+		cds[tag] = newCompactElement(e)
+	}
+	return cds
+}
+
+// GetTag returns the element's "Tag" component
+func (ce *CompactElement) GetTag() uint32 {
+	return ce.tag
+}
+
+// GetVR returns the element's "VR" component
+func (ce *CompactElement) GetVR() string {
+	return string(ce.vr[:])
+}
+
+// GetName returns the element's name, as looked up from the dictionary.
+func (ce *CompactElement) GetName() string {
+	entry, _ := lookupTag(ce.tag)
+	return entry.Name
+}
+
+// HasItems returns whether the element contained any items
+func (ce *CompactElement) HasItems() bool {
+	return ce.hasItems
+}
+
+// Element expands the compact element into a full Element, looking up its
+// dictionary entry. Any items that the element contained are not restored.
+func (ce *CompactElement) Element() Element {
+	e := NewElementWithTag(ce.tag)
+	if vr := ce.GetVR(); vr != e.GetVR() {
+		// do not modify the dictionary's own entry
+		entry := *e.dictEntry
+		entry.VR = vr
+		e.dictEntry = &entry
+	}
+	e.isLittleEndian = ce.isLittleEndian
+	e.data = ce.data
+	e.datalen = uint32(len(ce.data))
+	return e
+}
+
+// GetValue writes the element's "value" component to "dst".
+// See: Element.GetValue for more information
+func (ce *CompactElement) GetValue(dst interface{}) error {
+	e := ce.Element()
+	return e.GetValue(dst)
+}
+
+// GetElement writes the element indexed by `tag` into `dst`, expanded to a full Element.
+// its return value indicates whether the CompactDataSet contains said `tag`.
+func (cds *CompactDataSet) GetElement(tag uint32, dst *Element) bool {
+	if ce, found := (*cds)[tag]; found {
+		*dst = ce.Element()
+		return true
+	}
+	return false
+}
+
+// GetElementValue writes the element's value indexed by `tag` into `dst`
+// its return value (bool) indicates whether the CompactDataSet contains said `tag`.
+// its return value (error) indicates whether there are any other problems.
+func (cds *CompactDataSet) GetElementValue(tag uint32, dst interface{}) (bool, error) {
+	if ce, found := (*cds)[tag]; found {
+		return true, ce.GetValue(dst)
+	}
+	return false, nil
+}
+
+// HasElement returns whether the element indexed by `tag` exists.
+func (cds *CompactDataSet) HasElement(tag uint32) bool {
+	_, found := (*cds)[tag]
+	return found
+}
+
+// Len returns the number of elements.
+func (cds *CompactDataSet) Len() int {
+	return len(*cds)
+}
+
+// FromReaderCompact decodes a dicom from `source` in the manner of `FromReader`,
+// returning only the compact representation of its data set. Each element is compacted as
+// it is read, such that the full data set is never held in memory. The values of large
+// binary elements, such as PixelData, are skipped as per `FromReaderLazy`, so are not retained.
+func FromReaderCompact(source io.Reader) (CompactDataSet, error) {
+	cds := make(CompactDataSet)
+	if _, err := fromReader(source, false, cds); err != nil {
+		return nil, err
+	}
+	return cds, nil
+}
+
+// FromFileCompact decodes a dicom file from the given file path
+// See: FromReaderCompact for more information
+func FromFileCompact(path string) (CompactDataSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return FromReaderCompact(sizedReader{bufio.NewReader(f), fileSize(f)})
+}
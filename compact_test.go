@@ -0,0 +1,104 @@
+package opendcm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromFileCompact(t *testing.T) {
+	// ensures that a compact data set, compacted as it is parsed, exposes the same (decoded)
+	// values as the full data set, except for those of large binary elements, which are skipped.
+	t.Parallel()
+	skipped := 0
+	for _, path := range []string{
+		filepath.Join("testdata", "synthetic", "VRTest.dcm"),
+		filepath.Join("testdata", "synthetic", "ShiftJIS.dcm"),
+		filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"),
+	} {
+		dcm, err := FromFile(path)
+		assert.NoError(t, err)
+		cds, err := FromFileCompact(path)
+		assert.NoError(t, err)
+		assert.Equal(t, dcm.Len(), cds.Len())
+		for tag, e := range dcm.DataSet {
+			assert.True(t, cds.HasElement(tag))
+			ce := cds[tag]
+			assert.Equal(t, e.GetName(), ce.GetName())
+			assert.Equal(t, e.HasItems(), ce.HasItems())
+			expanded := NewElement()
+			assert.True(t, cds.GetElement(tag, &expanded))
+			assert.Equal(t, e.GetVR(), expanded.GetVR())
+			if (&ElementReader{compact: true}).shouldSkipValue(&e) {
+				assert.Nil(t, expanded.data, "%s %s", path, e.dictEntry)
+				continue
+			}
+			assert.Equal(t, e.data, expanded.data, "%s %s", path, e.dictEntry)
+		}
+		if pixelData, found := cds[pixelDataTag]; found && len(pixelData.data) == 0 {
+			skipped++
+		}
+	}
+	assert.True(t, skipped > 0)
+
+	_, err := FromFileCompact("__.__0000")
+	assert.Error(t, err)
+}
+
+func TestCompactElementGetValue(t *testing.T) {
+	t.Parallel()
+	ds := newDataSet(newUSElement(0x00280010, 512), newStringElement(0x00100010, "DOE^JOHN"))
+	cds := ds.Compact()
+	rows := uint16(0)
+	found, err := cds.GetElementValue(0x00280010, &rows)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(512), rows)
+	name := ""
+	found, err = cds.GetElementValue(0x00100010, &name)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "DOE^JOHN", name)
+	found, _ = cds.GetElementValue(0x00100020, &name)
+	assert.False(t, found)
+}
+
+// benchmarkRetained parses the VRTest dicom `b.N` times, retaining each result,
+// and reports the heap memory retained per parse.
+func benchmarkRetained(b *testing.B, parse func(buf []byte) interface{}) {
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	retained := make([]interface{}, 0, b.N)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		retained = append(retained, parse(buf))
+	}
+	b.StopTimer()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(b.N), "retained-B/op")
+	runtime.KeepAlive(retained)
+}
+
+func BenchmarkRetainedDataSet(b *testing.B) {
+	benchmarkRetained(b, func(buf []byte) interface{} {
+		dcm, _ := FromReader(bytes.NewReader(buf))
+		return dcm.DataSet
+	})
+}
+
+func BenchmarkRetainedCompactDataSet(b *testing.B) {
+	benchmarkRetained(b, func(buf []byte) interface{} {
+		cds, _ := FromReaderCompact(bytes.NewReader(buf))
+		return cds
+	})
+}
@@ -1,14 +1,24 @@
 package opendcm
 
 import (
+	"bufio"
 	"bytes"
+	"compress/flate"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
 
 	"github.com/b71729/bin"
 	"github.com/b71729/opendcm/dictionary"
@@ -18,6 +28,7 @@ import (
 	"golang.org/x/text/encoding/korean"
 	"golang.org/x/text/encoding/simplifiedchinese"
 	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/unicode/norm"
 )
 
 /*
@@ -38,9 +49,84 @@ const (
 
 	// PixelData (7FE0,0010)
 	pixelDataTag = uint32(0x7FE00010)
+
+	// TransferSyntaxUID (0002,0010)
+	transferSyntaxTag = uint32(0x00020010)
+
+	// CommandGroupLength (0000,0000)
+	commandGroupLengthTag = uint32(0x00000000)
+)
+
+// Transfer Syntax UIDs, as per http://dicom.nema.org/dicom/2013/output/chtml/part05/chapter_10.html
+const (
+	ImplicitVRLittleEndian         = "1.2.840.10008.1.2"
+	ExplicitVRLittleEndian         = "1.2.840.10008.1.2.1"
+	DeflatedExplicitVRLittleEndian = "1.2.840.10008.1.2.1.99"
+	ExplicitVRBigEndian            = "1.2.840.10008.1.2.2"
+	JPEGBaseline                   = "1.2.840.10008.1.2.4.50"
+	JPEGLSLossless                 = "1.2.840.10008.1.2.4.80"
+	JPEGLSNearLossless             = "1.2.840.10008.1.2.4.81"
+	JPEG2000Lossless               = "1.2.840.10008.1.2.4.90"
+	JPEG2000                       = "1.2.840.10008.1.2.4.91"
+	RLELossless                    = "1.2.840.10008.1.2.5"
+)
+
+// VRConformance describes the permitted length of each value of a VR.
+// For the textual VRs subject to the specific character set (LO, LT, PN, SH, ST), the
+// maximum is measured in characters; for the remainder, in bytes. Values of a `FixedLength`
+// VR must be exactly `MaximumLengthBytes` long, except that textual values may be empty.
+type VRConformance struct {
+	MaximumLengthBytes int
+	FixedLength        bool
+}
+
+// CorruptElement is returned when an element is malformed, such that it violates the standard.
+type CorruptElement struct {
+	error
+}
+
+// CorruptDicom is returned when a dicom is malformed as a whole, rather than within any one element.
+type CorruptDicom struct {
+	error
+}
+
+var (
+	// ErrCorruptElement is matched by every `CorruptElement`, such that, for example,
+	// `errors.Is(err, ErrCorruptElement)` reports whether a parse failed for this reason.
+	ErrCorruptElement = errors.New("corrupt element")
+
+	// ErrCorruptDicom is matched by every `CorruptDicom`, as per `ErrCorruptElement`.
+	ErrCorruptDicom = errors.New("corrupt dicom")
 )
 
+// Unwrap returns the cause of the error, such that it may be inspected by `errors.Is` and `errors.As`.
+func (err CorruptElement) Unwrap() error {
+	return err.error
+}
+
+// Is returns whether `target` is `ErrCorruptElement`.
+func (err CorruptElement) Is(target error) bool {
+	return target == ErrCorruptElement
+}
+
+// Unwrap returns the cause of the error, such that it may be inspected by `errors.Is` and `errors.As`.
+func (err CorruptDicom) Unwrap() error {
+	return err.error
+}
+
+// Is returns whether `target` is `ErrCorruptDicom`.
+func (err CorruptDicom) Is(target error) bool {
+	return target == ErrCorruptDicom
+}
+
 var (
+	// uniqueIdentifierRe matches a UID consisting only of the permitted characters: digits and dots.
+	uniqueIdentifierRe = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+	// ErrValueTooShort is returned by `GetValue` when an element's value contains
+	// fewer bytes than are needed to decode the requested type.
+	ErrValueTooShort = errors.New("element value is too short for the requested type")
+
 	// dicmTestString contains the dicom magic value
 	dicmTestString = []byte("DICM")
 
@@ -48,7 +134,36 @@ var (
 	// See ``6.2 Value Representation (VR)`` for more information
 	RecognisedVRs = []string{
 		"AE", "AS", "AT", "CS", "DA", "DS", "DT", "FL", "FD", "IS", "LO", "LT", "OB", "OD",
-		"OF", "OW", "PN", "SH", "SL", "SQ", "SS", "ST", "TM", "UI", "UL", "UN", "US", "UT",
+		"OF", "OL", "OV", "OW", "PN", "SH", "SL", "SQ", "SS", "ST", "SV", "TM", "UC", "UI", "UL", "UN",
+		"UR", "US", "UT", "UV",
+	}
+
+	// VRConformanceMap provides, for each VR of restricted length, the maximum length of each of its
+	// values, as per ``6.2 Value Representation (VR)``. VRs which are absent are unrestricted.
+	VRConformanceMap = map[string]VRConformance{
+		"AE": {MaximumLengthBytes: 16},
+		"AS": {MaximumLengthBytes: 4, FixedLength: true},
+		"AT": {MaximumLengthBytes: 4, FixedLength: true},
+		"CS": {MaximumLengthBytes: 16},
+		"DA": {MaximumLengthBytes: 8, FixedLength: true},
+		"DS": {MaximumLengthBytes: 16},
+		"DT": {MaximumLengthBytes: 26},
+		"FD": {MaximumLengthBytes: 8, FixedLength: true},
+		"FL": {MaximumLengthBytes: 4, FixedLength: true},
+		"IS": {MaximumLengthBytes: 12},
+		"LO": {MaximumLengthBytes: 64},
+		"LT": {MaximumLengthBytes: 10240},
+		"PN": {MaximumLengthBytes: 64},
+		"SH": {MaximumLengthBytes: 16},
+		"SL": {MaximumLengthBytes: 4, FixedLength: true},
+		"SS": {MaximumLengthBytes: 2, FixedLength: true},
+		"ST": {MaximumLengthBytes: 1024},
+		"SV": {MaximumLengthBytes: 8, FixedLength: true},
+		"TM": {MaximumLengthBytes: 14},
+		"UI": {MaximumLengthBytes: 64},
+		"UL": {MaximumLengthBytes: 4, FixedLength: true},
+		"US": {MaximumLengthBytes: 2, FixedLength: true},
+		"UV": {MaximumLengthBytes: 8, FixedLength: true},
 	}
 
 	// CharacterSetMap provides a mapping between character set name, and character set characteristics.
@@ -99,15 +214,33 @@ type Dicom struct {
 	preamble [128]byte
 	DataSet
 	pixelData PixelData
+	warnings  []string
+	rawMeta   []byte
+	// encoding is that with which the (non-meta) data set was read
+	encoding Encoding
+	// layout records the position of each top-level element within the source, and
+	// inputLength the number of bytes read from it, for use by `CheckAlignment`
+	layout      []elementSpan
+	inputLength int64
 	tmpBuffers
 }
 
+// elementSpan records the position and encoded length of an element within its source.
+// `complete` is unset if the source ended within the element.
+type elementSpan struct {
+	tag      uint32
+	offset   int64
+	length   int64
+	complete bool
+}
+
 // NewDicom returns a fresh Dicom suitable for parsing
 // dicom data.
 func newDicom() Dicom {
 	dcm := Dicom{}
 	dcm.DataSet = make(DataSet, 0)
 	dcm.pixelData = newPixelData()
+	dcm.encoding = Encoding{ImplicitVR: true, LittleEndian: true}
 	return dcm
 }
 
@@ -120,6 +253,246 @@ func (dcm *Dicom) GetPreamble() [128]byte {
 	return dcm.preamble
 }
 
+// Warnings returns the non-fatal problems encountered whilst parsing,
+// such as elements which violate the standard but could still be read.
+func (dcm *Dicom) Warnings() []string {
+	return dcm.warnings
+}
+
+// RawMetaBytes returns the exact bytes of the preamble, "DICM" magic and (0002) meta group,
+// as they were read. This is only populated when `CaptureRawMeta` is enabled in the config.
+func (dcm *Dicom) RawMetaBytes() []byte {
+	return dcm.rawMeta
+}
+
+// ParseStats summarises the structure of a parsed dicom, such that anomalous files (e.g. those
+// with far fewer elements than is typical) can be identified.
+type ParseStats struct {
+	// Elements is the number of top-level elements, as returned by `Len`
+	Elements int
+	// NestedElements is the number of elements within the items of sequences, at any depth
+	NestedElements int
+	// BytesParsed is the number of bytes read from the source, including any preamble
+	BytesParsed int64
+	// Sequences is the number of elements, at any depth, holding items
+	Sequences int
+	// MaxDepth is the deepest nesting of sequences; zero if there are none
+	MaxDepth int
+	// EncodingGuessed is set if the encoding of the data set was not that declared by
+	// (0002,0010) TransferSyntaxUID, as it was absent, unregistered or incorrect
+	EncodingGuessed bool
+}
+
+// Stats returns the `ParseStats` of the dicom.
+func (dcm *Dicom) Stats() ParseStats {
+	stats := ParseStats{Elements: dcm.Len(), BytesParsed: dcm.inputLength}
+	var walk func(ds DataSet, depth int)
+	walk = func(ds DataSet, depth int) {
+		if depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+		}
+		for _, e := range ds {
+			if depth > 0 {
+				stats.NestedElements++
+			}
+			if e.HasItems() && e.GetTag() != pixelDataTag {
+				stats.Sequences++
+				for _, itm := range e.GetItems() {
+					walk(itm.GetDataSet(), depth+1)
+				}
+			}
+		}
+	}
+	walk(dcm.DataSet, 0)
+	ts := ""
+	if found, _ := dcm.GetElementValue(transferSyntaxTag, &ts); !found {
+		stats.EncodingGuessed = true
+	} else {
+		enc, registered := GetEncodingForTransferSyntax(ts)
+		stats.EncodingGuessed = !registered || enc != dcm.encoding
+	}
+	return stats
+}
+
+// DescribeJSON returns an indented JSON tree describing the elements of the dicom, as per `Describe`.
+// Each element is given as an object with "tag", "name", "vr" and "value" members, in ascending tag
+// order. Sequences instead have an "items" member (omitted if there are none): an array holding, for
+// each item, an array of its elements. Unlike a flat tag map, the hierarchy of the data set is preserved, for visualisation.
+func (dcm *Dicom) DescribeJSON() ([]byte, error) {
+	return json.MarshalIndent(describeDataSet(dcm.DataSet), "", "  ")
+}
+
+// ValidateMeta checks that the required (0002) meta elements are present and well-formed:
+// (0002,0001) FileMetaInformationVersion, (0002,0002) MediaStorageSOPClassUID,
+// (0002,0003) MediaStorageSOPInstanceUID, (0002,0010) TransferSyntaxUID and
+// (0002,0012) ImplementationClassUID. The error returned lists every problem found.
+func (dcm *Dicom) ValidateMeta() error {
+	missing := make([]string, 0)
+	problems := make([]string, 0)
+	version := NewElementWithTag(0x00020001)
+	if !dcm.GetElement(0x00020001, &version) {
+		missing = append(missing, version.GetName())
+	} else if len(version.data) == 0 {
+		// the leading NULL byte of the version (0x00, 0x01) is stripped as padding upon parse
+		problems = append(problems, fmt.Sprintf("%s is empty", version.dictEntry))
+	}
+	for _, tag := range []uint32{0x00020002, mediaStorageSOPInstanceUIDTag, transferSyntaxTag, 0x00020012} {
+		if !dcm.HasElement(tag) {
+			e := NewElementWithTag(tag)
+			missing = append(missing, e.GetName())
+			continue
+		}
+		if _, err := dcm.getUID(tag); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(missing) > 0 {
+		problems = append([]string{"missing " + strings.Join(missing, ", ")}, problems...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("ValidateMeta(): %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// CheckSOPConsistency verifies that (0002,0002) MediaStorageSOPClassUID and (0002,0003)
+// MediaStorageSOPInstanceUID of the meta group match (0008,0016) SOPClassUID and (0008,0018)
+// SOPInstanceUID of the data set, as receivers commonly reject files whose identifiers differ.
+// A pair of which only one is present is also inconsistent. The error returned lists every problem found.
+// See: `SyncSOPUIDsOnWrite`, which causes the writer to make them consistent.
+func (dcm *Dicom) CheckSOPConsistency() error {
+	problems := make([]string, 0)
+	for _, pair := range [][2]uint32{{0x00020002, sopClassUIDTag}, {mediaStorageSOPInstanceUIDTag, sopInstanceUIDTag}} {
+		meta, body := NewElementWithTag(pair[0]), NewElementWithTag(pair[1])
+		hasMeta, hasBody := dcm.HasElement(pair[0]), dcm.HasElement(pair[1])
+		switch {
+		case !hasMeta && !hasBody:
+			continue
+		case !hasMeta || !hasBody:
+			present, absent := meta, body
+			if hasBody {
+				present, absent = body, meta
+			}
+			problems = append(problems, fmt.Sprintf("%s is present, but %s is not", present.GetName(), absent.GetName()))
+			continue
+		}
+		metaUID, err := dcm.getUID(pair[0])
+		if err != nil {
+			return err
+		}
+		bodyUID, err := dcm.getUID(pair[1])
+		if err != nil {
+			return err
+		}
+		if metaUID != bodyUID {
+			problems = append(problems, fmt.Sprintf("%s %q does not match %s %q", meta.GetName(), metaUID, body.GetName(), bodyUID))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("CheckSOPConsistency(): %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// CheckAlignment verifies that the elements read by the parser account for the whole of the
+// input: that each top-level element begins where its predecessor ended, and that the last
+// ends where the input ended. As the parser stops silently when the input ends, an element
+// whose length was misread (for example, when an implicit VR length is read from misaligned
+// bytes) otherwise goes unnoticed. If the check fails, the returned `CorruptDicom` describes
+// the element at which alignment likely broke.
+// Dicoms which were not parsed from a source always pass.
+func (dcm *Dicom) CheckAlignment() error {
+	if len(dcm.layout) == 0 {
+		return nil
+	}
+	if dcm.inputLength == 0 {
+		return CorruptDicom{errors.New("dicom was not parsed through to the end of its input")}
+	}
+	// the first element follows the preamble, if present
+	expected := dcm.layout[0].offset
+	if expected != 0 && expected != 132 {
+		expected = 0
+	}
+	for _, span := range dcm.layout {
+		tag := fmt.Sprintf("(%04X,%04X)", span.tag>>16, span.tag&0xFFFF)
+		switch {
+		case span.offset != expected:
+			return CorruptDicom{fmt.Errorf("%s at offset %d does not follow its predecessor, which ended at offset %d", tag, span.offset, expected)}
+		case !span.complete:
+			return CorruptDicom{fmt.Errorf("input ended within %s at offset %d; its length was likely misread", tag, span.offset)}
+		}
+		expected = span.offset + span.length
+	}
+	if expected != dcm.inputLength {
+		return CorruptDicom{fmt.Errorf("elements end at offset %d, but the input is %d bytes", expected, dcm.inputLength)}
+	}
+	return nil
+}
+
+// metaRecorder records the bytes read from a source until it is stopped.
+type metaRecorder struct {
+	buf     bytes.Buffer
+	stopped bool
+}
+
+// Write satisfies `io.Writer`, such that the recorder can be used with `io.TeeReader`.
+func (rec *metaRecorder) Write(p []byte) (int, error) {
+	if !rec.stopped {
+		rec.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// stop ceases recording, returning the first `n` bytes recorded.
+// the remainder will have been buffered by the reader, but not yet consumed.
+func (rec *metaRecorder) stop(n int64) []byte {
+	rec.stopped = true
+	if n > int64(rec.buf.Len()) {
+		n = int64(rec.buf.Len())
+	}
+	return rec.buf.Bytes()[:n]
+}
+
+// sizedReader is a source whose length is known ahead of reading, such as a file.
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+// sourceLength returns the number of bytes which remain to be read from `source`,
+// or zero if unknown.
+func sourceLength(source io.Reader) int64 {
+	switch sized := source.(type) {
+	case sizedReader:
+		return sized.size
+	case interface{ Len() int }:
+		return int64(sized.Len())
+	}
+	return 0
+}
+
+// fileSize returns the size of `f`, or zero if it is not a regular file.
+func fileSize(f *os.File) int64 {
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		return info.Size()
+	}
+	return 0
+}
+
+// countingReader counts the bytes read from `r`, such that those which have been buffered by a
+// `bin.Reader` but not yet consumed can be determined.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read satisfies `io.Reader`.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
 // tmpBuffers provides an assortment of temporary variables used internally
 // to reduce allocation overhead.
 //
@@ -157,42 +530,153 @@ func (dcm *Dicom) onPixelData(pdElement Element) {
 		Warn("Has fragmented data.")
 		// decode offset table
 		offsetTableRaw := pdElement.items[0].fragment
-		offsetTable := make([]int, 0)
-		for i := 0; i < len(offsetTableRaw)-4; i += 4 {
-			offsetTable = append(offsetTable, int(binary.LittleEndian.Uint32(offsetTableRaw[i:(i+4)])))
+		offsetTable := make([]int64, 0)
+		for i := 0; i+4 <= len(offsetTableRaw); i += 4 {
+			offsetTable = append(offsetTable, int64(binary.LittleEndian.Uint32(offsetTableRaw[i:(i+4)])))
+		}
+		// the 64-bit Extended Offset Table is used in place of an empty Basic Offset Table
+		if extended, found := dcm.extendedOffsetTable(nil); found && len(offsetTable) == 0 {
+			offsetTable = extended
+		}
+		// offsets are measured from the first byte of the first fragment's item tag, such
+		// that each includes the 8-byte item headers of the fragments preceding it
+		fragments := pdElement.items[1:]
+		starts := make(map[int64]int, len(fragments))
+		position := int64(0)
+		for i, itm := range fragments {
+			starts[position] = i
+			position += 8 + int64(len(itm.fragment))
 		}
-		// we must concatenate all items other than the offsettable into one slice
 
-		concatenated := make([]byte, 0)
-		for i := 1; i < len(pdElement.items); i++ {
-			concatenated = append(concatenated, pdElement.items[i].fragment...)
+		// offsets must be ascending and lie at the start of a fragment
+		for i, offset := range offsetTable {
+			if _, found := starts[offset]; !found || (i > 0 && offset <= offsetTable[i-1]) {
+				Debugf("ignoring invalid offset table entry #%d: %d", i, offset)
+				offsetTable = offsetTable[:0]
+				break
+			}
 		}
 
-		// process frames
-		for i := 0; i < len(offsetTable); i++ {
-			var frame []byte
-			if i == len(offsetTable)-1 {
-				frame = concatenated[offsetTable[i]:]
-			} else {
-				frame = concatenated[offsetTable[i]:offsetTable[i+1]]
+		// an empty offset table indicates that each fragment holds exactly one frame
+		if len(offsetTable) == 0 {
+			for _, itm := range fragments {
+				dcm.pixelData.addFrame(itm.fragment, true)
 			}
-			dcm.pixelData.frames = append(dcm.pixelData.frames, frame)
+			return
+		}
+
+		// process frames, concatenating the fragments of each
+		for i, offset := range offsetTable {
+			end := len(fragments)
+			if i < len(offsetTable)-1 {
+				end = starts[offsetTable[i+1]]
+			}
+			frame := make([]byte, 0)
+			for _, itm := range fragments[starts[offset]:end] {
+				frame = append(frame, itm.fragment...)
+			}
+			dcm.pixelData.addFrame(frame, true)
 		}
 		for i, frame := range dcm.pixelData.frames {
 			Errorf("frame #%d: %d bytes", i, len(frame))
 		}
 	} else {
 		Warn("No fragmented data.")
-		dcm.pixelData.frames = append(dcm.pixelData.frames, pdElement.data)
+		dcm.pixelData.isLittleEndian = pdElement.isLittleEndian
+		dcm.pixelData.addFrame(pdElement.data, false)
+	}
+}
+
+// extendedOffsetTable returns the 64-bit offsets of (7FE0,0001) ExtendedOffsetTable, which may be
+// present in place of the Basic Offset Table when encapsulated pixel data exceeds 4GB. Each offset
+// is that of the single fragment holding a frame. A value skipped during a lazy parse is read
+// from `src`, if non-nil. Its return value (bool) indicates whether the table is present and
+// has as many entries as (7FE0,0002) ExtendedOffsetTableLengths.
+func (ds *DataSet) extendedOffsetTable(src io.ReaderAt) ([]int64, bool) {
+	tables := [2][]int64{}
+	for i, tag := range []uint32{extendedOffsetTableTag, extendedOffsetTableLengthsTag} {
+		e := NewElement()
+		if !ds.GetElement(tag, &e) {
+			return nil, false
+		}
+		if e.IsLazy() && src != nil {
+			if err := e.LoadValue(src); err != nil {
+				return nil, false
+			}
+		}
+		// encapsulated pixel data is always little endian
+		for _, v := range splitBinaryVM(e.data, 8) {
+			tables[i] = append(tables[i], int64(binary.LittleEndian.Uint64(v)))
+		}
+	}
+	if len(tables[0]) == 0 || len(tables[0]) != len(tables[1]) {
+		return nil, false
+	}
+	return tables[0], true
+}
+
+// onFloatPixelData is called when a FloatPixelData or DoubleFloatPixelData element is detected in the dicom.
+// Such pixel data is always native.
+func (dcm *Dicom) onFloatPixelData(e Element) {
+	dcm.pixelData.isLittleEndian = e.isLittleEndian
+	dcm.pixelData.floating = true
+	dcm.pixelData.addFrame(e.data, false)
+}
+
+// transferSyntaxOf returns the value of (0002,0010) TransferSyntaxUID from within `elements`, if present.
+func transferSyntaxOf(elements []Element) string {
+	ts := ""
+	for _, e := range elements {
+		if e.GetTag() == transferSyntaxTag {
+			e.GetValue(&ts)
+		}
 	}
+	return ts
 }
 
 // FromReader decodes a dicom file from `source`, returning an error
-// if something went wrong during the process.
+// if something went wrong during the process. If so, the returned dicom holds the elements
+// which were read before the failure, without their text having been decoded.
 // This takes ownership of `source`; do not use it after passing through.
 func FromReader(source io.Reader) (Dicom, error) {
-	dcm := newDicom()
-	binaryReader := bin.NewReader(source, binary.LittleEndian)
+	return fromReader(source, false, nil)
+}
+
+// FromStream decodes a dicom from `source`, a stream of unknown length such as a pipe or
+// network connection, which is read until EOF. Reads from `source` are buffered according to
+// `DicomReadBufferSize`, such that many small reads are not made of the underlying stream.
+func FromStream(source io.Reader) (Dicom, error) {
+	size := config.DicomReadBufferSize
+	if size <= 0 {
+		size = 4096
+	}
+	return FromReader(bufio.NewReaderSize(source, size))
+}
+
+// fromReader decodes a dicom file from `source`. If `lazy` is set, large binary values
+// are skipped rather than read, and element framing is validated strictly. If `compact` is
+// not nil, each element is added to it as soon as it is read, rather than to the returned
+// dicom, such that the full data set is never held in memory. See: `FromReaderCompact`.
+func fromReader(source io.Reader, lazy bool, compact CompactDataSet) (dcm Dicom, err error) {
+	dcm = newDicom()
+	inputLength := sourceLength(source)
+	elements := make([]Element, 0)
+	// should parsing fail, the elements read thus far are returned alongside the error, such
+	// that a broken file can still be inspected (or redacted, to be shared)
+	defer func() {
+		if err != nil {
+			for _, e := range elements {
+				dcm.addElement(e)
+			}
+		}
+	}()
+	var recorder *metaRecorder
+	if config.CaptureRawMeta {
+		recorder = &metaRecorder{}
+		source = io.TeeReader(source, recorder)
+	}
+	counter := &countingReader{r: source}
+	binaryReader := bin.NewReader(counter, binary.LittleEndian)
 
 	// attempt to parse preamble
 	dcm._bool, dcm.err = dcm.attemptReadPreamble(&binaryReader)
@@ -204,14 +688,20 @@ func FromReader(source io.Reader) (Dicom, error) {
 	}
 
 	elr := NewElementReader(binaryReader)
+	elr.lazy = lazy
+	elr.compact = compact != nil
+	elr.inputLength = inputLength
+	// text is decoded as each compact element is read, so (0008,0005) must precede it
+	compactDecoder := dcm.GetCharacterSet().Encoding.NewDecoder()
 	// meta elements are always explicit vr, little endian
 	elr.SetImplicitVR(false)
 	elr.SetLittleEndian(true)
 
 	// read elements
 	inMeta := true
-	// initialise array of elements
-	elements := make([]Element, 0)
+	// the positions of a deflated data set are relative to the start of the inflated stream,
+	// so are offset by the length of the meta group
+	offsetBase := int64(0)
 	for {
 		e := NewElement()
 		if inMeta {
@@ -220,6 +710,9 @@ func FromReader(source io.Reader) (Dicom, error) {
 			// we have reached boundary of meta section
 			if dcm.err = elr.br.Peek(dcm._1kb[:2]); dcm.err != nil {
 				if dcm.err == io.EOF {
+					if recorder != nil {
+						dcm.rawMeta = recorder.stop(elr.br.GetPosition())
+					}
 					break
 				}
 				return dcm, dcm.err
@@ -228,6 +721,27 @@ func FromReader(source io.Reader) (Dicom, error) {
 			// of meta section
 			if binary.LittleEndian.Uint16(dcm._1kb[:2]) != 0x0002 {
 				inMeta = false
+				if recorder != nil {
+					dcm.rawMeta = recorder.stop(elr.br.GetPosition())
+				}
+				ts := transferSyntaxOf(elements)
+				if ts != "" && !IsTransferSyntaxSupported(ts) {
+					elr.addWarning("transfer syntax %s is not registered; its encoding will be inferred", ts)
+				}
+				// the data set of a deflated dicom is compressed in its entirety, beginning with
+				// the bytes that have been peeked (which may extend beyond the tag just peeked)
+				if ts == DeflatedExplicitVRLittleEndian {
+					peeked := make([]byte, counter.n-elr.br.GetPosition())
+					if dcm.err = elr.br.Peek(peeked); dcm.err != nil {
+						return dcm, dcm.err
+					}
+					inflater := flate.NewReader(io.MultiReader(bytes.NewReader(peeked), counter))
+					defer inflater.Close()
+					offsetBase = elr.br.GetPosition()
+					elr.br = bin.NewReader(inflater, binary.LittleEndian)
+					elr.inflated = true
+					elr.inputLength = 0
+				}
 				// determine binary encoding of non-meta section
 				// we do this by peeking six bytes from the reader
 				// and passing through to `determineEncoding`
@@ -238,48 +752,170 @@ func FromReader(source io.Reader) (Dicom, error) {
 					return dcm, dcm.err
 				}
 				elr.determineEncoding(dcm._1kb[:6])
+			} else if !elr.IsImplicitVR() {
+				// the meta group should always be explicit vr, but some writers encode it
+				// as implicit vr. this is detectable by the absence of a recognised VR.
+				if dcm.err = elr.br.Peek(dcm._1kb[:6]); dcm.err != nil {
+					return dcm, dcm.err
+				}
+				if !isRecognisedVR(string(dcm._1kb[4:6])) {
+					elr.addWarning("meta group is encoded with implicit VR; should be explicit VR")
+					elr.SetImplicitVR(true)
+				}
 			}
 		}
+		startPos := elr.br.GetPosition()
+		// a second preamble signifies that another dicom has been concatenated onto this one;
+		// its meta group would otherwise be parsed as garbage elements of this data set
+		if !inMeta && elr.hasEmbeddedPreamble() {
+			return dcm, CorruptDicom{fmt.Errorf("found preamble or %q magic of another dicom at offset %d", dicmTestString, startPos)}
+		}
 		if dcm.err = elr.ReadElement(&e); dcm.err != nil {
-			if dcm.err == io.EOF {
+			if dcm.err == io.EOF && (!lazy || elr.br.GetPosition() == startPos) {
+				if elr.br.GetPosition() != startPos {
+					dcm.layout = append(dcm.layout, elementSpan{tag: e.GetTag(), offset: offsetBase + startPos, length: elr.br.GetPosition() - startPos})
+				}
 				break
 			}
+			if lazy {
+				dcm.err = CorruptElement{fmt.Errorf("element at offset %d is malformed: %v", startPos, dcm.err)}
+			}
 			return dcm, dcm.err
 		}
+		dcm.layout = append(dcm.layout, elementSpan{tag: e.GetTag(), offset: offsetBase + startPos, length: elr.br.GetPosition() - startPos, complete: true})
 		//Debugf("Adding element: %s [%s] @ %d", e.dictEntry, e.GetVR(), elr.br.GetPosition())
-		switch e.GetTag() {
-		case 0x00080005:
+		switch {
+		case e.skipped:
+			continue
+		case compact != nil:
+			if e.GetTag() == 0x00080005 {
+				dcm.addElement(e)
+				compactDecoder = dcm.GetCharacterSet().Encoding.NewDecoder()
+			}
+			decodeText(&e, compactDecoder)
+			compact[e.GetTag()] = newCompactElement(e)
+		case e.GetTag() == 0x00080005:
 			dcm.addElement(e)
 		default:
 			elements = append(elements, e)
 		}
 	}
+	dcm.inputLength = offsetBase + elr.br.GetPosition()
 
 	// we must re-encode the parsed elements from their native characterset into UTF-8:
 	// lookup character set according to the pre-defined table
 	cs := dcm.GetCharacterSet()
 	Debugf("CS: %v", cs.Name)
+	charsets := []string{}
+	if found, _ := dcm.GetElementValue(0x00080005, &charsets); found && len(charsets) > 0 {
+		if _, recognised := CharacterSetMap[charsets[len(charsets)-1]]; !recognised {
+			elr.addWarning("SpecificCharacterSet %q is not recognised; using %s", charsets[len(charsets)-1], cs.Name)
+		}
+	}
+	// `CharacterSetMap` is shared between concurrent parses, so each parse must use its own decoder
 	decoder := cs.Encoding.NewDecoder()
 	// for each element in dataset:
 	for _, e := range elements {
-		// 	is it of ("SH", "LO", "ST", "PN", "LT", "UT")?
-		switch e.GetVR() {
-		case "SH", "LO", "ST", "PN", "LT", "UT":
-			// if so, decode data in-place
-			e.data, _ = decoder.Bytes(e.data) // this will not result in an error as replacement runes are enforced
-		}
+		decodeText(&e, decoder)
 
-		// look for PixelData
-		if e.GetTag() == pixelDataTag {
+		// look for PixelData. if it has been parsed as elements, it has no frames to extract
+		switch {
+		case e.GetTag() == pixelDataTag && !config.ParsePixelDataAsElements:
 			dcm.onPixelData(e)
-			continue
+		case e.GetTag() == floatPixelDataTag || e.GetTag() == doubleFloatPixelDataTag:
+			dcm.onFloatPixelData(e)
 		}
 		dcm.addElement(e)
 	}
+	dcm.warnings = elr.warnings
+	dcm.encoding = Encoding{ImplicitVR: elr.IsImplicitVR(), LittleEndian: elr.IsLittleEndian()}
+	if config.WarningsAreErrors && len(dcm.warnings) > 0 {
+		return dcm, CorruptDicom{errors.New(strings.Join(dcm.warnings, "; "))}
+	}
 
 	return dcm, nil
 }
 
+// decodeText decodes the value of `e` in-place into UTF-8 using `decoder`, if it is of a VR
+// ("SH", "LO", "ST", "PN", "LT", "UT") which is subject to the specific character set.
+func decodeText(e *Element, decoder *encoding.Decoder) {
+	switch e.GetVR() {
+	case "SH", "LO", "ST", "PN", "LT", "UT":
+		e.data, _ = decoder.Bytes(e.data) // this will not result in an error as replacement runes are enforced
+	}
+}
+
+// ParseCommandAndDataset decodes a DIMSE message from `r`, as would be received by a service
+// class provider: a (0000) command set, which is always encoded with implicit VR little endian,
+// followed by a data set encoded according to the negotiated transfer syntax `ts`. Neither
+// a preamble nor a meta group is present. If the message has no data set, it is returned empty.
+// Deflated data sets are not supported.
+func ParseCommandAndDataset(r io.Reader, ts string) (command DataSet, dataset DataSet, err error) {
+	command, dataset = make(DataSet, 0), make(DataSet, 0)
+	if ts == DeflatedExplicitVRLittleEndian {
+		return command, dataset, errors.New("ParseCommandAndDataset(): deflated data sets are not supported")
+	}
+	elr := NewElementReader(bin.NewReader(r, binary.LittleEndian))
+	// the command set begins with its group length, which delimits it from the data set
+	groupLength := NewElement()
+	if err = elr.ReadElement(&groupLength); err != nil {
+		return
+	}
+	length := uint32(0)
+	if groupLength.GetTag() != commandGroupLengthTag {
+		return command, dataset, fmt.Errorf("ParseCommandAndDataset(): command set begins with %s; expected (0000,0000) CommandGroupLength", groupLength.dictEntry)
+	} else if err = groupLength.GetValue(&length); err != nil {
+		return
+	}
+	command.addElement(groupLength)
+	endPos := elr.br.GetPosition() + int64(length)
+	for elr.br.GetPosition() < endPos {
+		e := NewElement()
+		if err = elr.ReadElement(&e); err != nil {
+			return
+		}
+		if e.GetTag()>>16 != 0x0000 {
+			return command, dataset, fmt.Errorf("ParseCommandAndDataset(): command set contains %s", e.dictEntry)
+		}
+		if !e.skipped {
+			command.addElement(e)
+		}
+	}
+
+	elr.SetTransferSyntax(ts)
+	for {
+		e := NewElement()
+		e.isLittleEndian = elr.IsLittleEndian()
+		startPos := elr.br.GetPosition()
+		if err = elr.ReadElement(&e); err != nil {
+			if err == io.EOF && elr.br.GetPosition() == startPos {
+				break
+			}
+			return
+		}
+		if !e.skipped {
+			dataset.addElement(e)
+		}
+	}
+	decoder := dataset.GetCharacterSet().Encoding.NewDecoder()
+	for tag, e := range dataset {
+		decodeText(&e, decoder)
+		dataset[tag] = e
+	}
+	return command, dataset, nil
+}
+
+// ParseElement decodes one element from `buf`, which is encoded according to the transfer syntax `ts`.
+func ParseElement(buf []byte, ts string) (Element, error) {
+	elr := NewElementReader(bin.NewReaderBytes(buf, binary.LittleEndian))
+	elr.SetTransferSyntax(ts)
+	elr.inputLength = int64(len(buf))
+	e := NewElement()
+	e.isLittleEndian = elr.IsLittleEndian()
+	err := elr.ReadElement(&e)
+	return e, err
+}
+
 // FromFile decodes a dicom file from the given file path
 // See: FromReader for more information
 func FromFile(path string) (Dicom, error) {
@@ -289,21 +925,159 @@ func FromFile(path string) (Dicom, error) {
 		return dcm, dcm.err
 	}
 	defer f.Close()
-	return FromReader(f)
+	// the parser makes many small reads, which would otherwise each be a system call
+	return FromReader(sizedReader{bufio.NewReader(f), fileSize(f)})
+}
+
+// ParseDicomWithHash decodes a dicom file from the given file path, in the manner of `FromFile`,
+// whilst computing the SHA-256 digest of the file's contents, such that the file need not be
+// read twice. Any bytes not consumed by the parser are read and included in the digest.
+// The digest is only returned if the file is parsed successfully.
+func ParseDicomWithHash(path string) (Dicom, [32]byte, error) {
+	var digest [32]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return newDicom(), digest, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	dcm, err := FromReader(io.TeeReader(f, hash))
+	if err != nil {
+		return dcm, digest, err
+	}
+	if _, err = io.Copy(hash, f); err != nil {
+		return dcm, digest, err
+	}
+	copy(digest[:], hash.Sum(nil))
+	return dcm, digest, nil
+}
+
+// ValidationResult describes whether the elements of a dicom are correctly framed.
+type ValidationResult struct {
+	// Valid is set if every element, through to the end of the input, could be read.
+	Valid bool
+	// Err is the first framing problem encountered, if any.
+	Err error
+	// Warnings lists the non-fatal problems encountered.
+	Warnings []string
+}
+
+// FromReaderLazy decodes a dicom file from `source` in the manner of `FromReader`, but skipping
+// (rather than buffering) large binary values such as PixelData. Skipped values can later be
+// read with `Element.LoadValue`; the fragments of encapsulated PixelData are not retained.
+//
+// Element framing (tags, VRs and lengths) is validated throughout, such that
+// truncated or malformed files are reported within the returned ValidationResult.
+func FromReaderLazy(source io.Reader) (Dicom, ValidationResult) {
+	dcm, err := fromReader(source, true, nil)
+	return dcm, ValidationResult{Valid: err == nil, Err: err, Warnings: dcm.warnings}
+}
+
+// FromFileLazy decodes a dicom file from the given file path, lazily.
+// An error is returned only if the file could not be opened.
+// See: FromReaderLazy for more information
+func FromFileLazy(path string) (Dicom, ValidationResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return newDicom(), ValidationResult{}, err
+	}
+	defer f.Close()
+	dcm, result := FromReaderLazy(sizedReader{f, fileSize(f)})
+	return dcm, result, nil
+}
+
+// FixMetaLength verifies the value of (0002,0000) FileMetaInformationGroupLength within the
+// dicom file at `path`, and if incorrect, patches it in-place with the actual length of
+// the meta group. As the value is a fixed-length UL, the rest of the file is unaffected.
+func FixMetaLength(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dcm := newDicom()
+	binaryReader := bin.NewReader(f, binary.LittleEndian)
+	if _, err = dcm.attemptReadPreamble(&binaryReader); err != nil {
+		return err
+	}
+	// meta elements are always explicit vr, little endian
+	elr := NewElementReader(binaryReader)
+	elr.SetImplicitVR(false)
+	elr.SetLittleEndian(true)
+
+	valuePos, startPos := int64(-1), int64(0)
+	groupLength := uint32(0)
+	for {
+		if err = elr.br.Peek(dcm._1kb[:2]); err != nil && err != io.EOF {
+			return err
+		}
+		if err == io.EOF || binary.LittleEndian.Uint16(dcm._1kb[:2]) != 0x0002 {
+			break
+		}
+		e := NewElement()
+		if err = elr.ReadElement(&e); err != nil {
+			return err
+		}
+		if e.GetTag() == 0x00020000 {
+			if err = e.GetValue(&groupLength); err != nil {
+				return err
+			}
+			startPos = elr.br.GetPosition()
+			valuePos = startPos - 4
+		}
+	}
+	if valuePos < 0 {
+		return errors.New("FixMetaLength(): file does not contain (0002,0000) FileMetaInformationGroupLength")
+	}
+	actualLength := uint32(elr.br.GetPosition() - startPos)
+	if actualLength == groupLength {
+		return nil
+	}
+	Debugf("FixMetaLength(): correcting group length from %d to %d", groupLength, actualLength)
+	binary.LittleEndian.PutUint32(dcm._1kb[:4], actualLength)
+	_, err = f.WriteAt(dcm._1kb[:4], valuePos)
+	return err
 }
 
 type PixelData struct {
-	frames [][]byte
+	frames         [][]byte
+	padded         []bool
+	isLittleEndian bool
+	// encapsulated is set if the frames are compressed, rather than native
+	encapsulated bool
+	// floating is set if the pixel data was read from FloatPixelData or DoubleFloatPixelData
+	floating bool
 }
 
 func newPixelData() PixelData {
-	return PixelData{frames: make([][]byte, 0)}
+	return PixelData{frames: make([][]byte, 0), isLittleEndian: true}
 }
 
+// addFrame appends `frame` to the pixel data, recording whether it has been padded.
+// an encapsulated frame is considered padded when its codestream, terminated by the
+// EOI marker (FFD9), is of odd length and so followed by a single NULL byte.
+func (pd *PixelData) addFrame(frame []byte, encapsulated bool) {
+	n := len(frame)
+	padded := encapsulated && n >= 3 && n%2 == 0 && frame[n-1] == 0x00 && frame[n-3] == 0xFF && frame[n-2] == 0xD9
+	pd.frames = append(pd.frames, frame)
+	pd.padded = append(pd.padded, padded)
+	pd.encapsulated = encapsulated
+}
+
+// GetFrame returns the bytes of frame `index` as stored, including any padding.
 func (pd *PixelData) GetFrame(index int) []byte {
 	return pd.frames[index]
 }
 
+// FrameTrimmed returns the bytes of frame `index`, with the trailing padding byte
+// removed if padding was applied to the frame.
+func (pd *PixelData) FrameTrimmed(index int) []byte {
+	if index < len(pd.padded) && pd.padded[index] {
+		return pd.frames[index][:len(pd.frames[index])-1]
+	}
+	return pd.frames[index]
+}
+
 func (pd *PixelData) NumFrames() int {
 	return len(pd.frames)
 }
@@ -358,6 +1132,53 @@ func (ds *DataSet) GetElementValue(tag uint32, dst interface{}) (bool, error) {
 	return false, nil
 }
 
+// GetElementByName writes the element whose dictionary name (e.g. "PatientName") is `name` into `dst`.
+// its return value indicates whether the DataSet contains said element.
+func (ds *DataSet) GetElementByName(name string, dst *Element) bool {
+	tag, found := LookupTagByName(name)
+	if !found {
+		return false
+	}
+	return ds.GetElement(tag, dst)
+}
+
+// GetReferencedTags decodes the value of the AT (attribute tag) element indexed by `tag`,
+// such as (0028,0009) FrameIncrementPointer, returning the tags to which it refers.
+func (ds *DataSet) GetReferencedTags(tag uint32) ([]uint32, error) {
+	e := NewElement()
+	if !ds.GetElement(tag, &e) {
+		return nil, fmt.Errorf("GetReferencedTags(%08X): element not found", tag)
+	}
+	if e.GetVR() != "AT" {
+		return nil, fmt.Errorf("GetReferencedTags(%08X): element has VR %s; expected AT", tag, e.GetVR())
+	}
+	var bo binary.ByteOrder = binary.LittleEndian
+	if !e.isLittleEndian {
+		bo = binary.BigEndian
+	}
+	// each value is encoded as a pair of 16-bit words: group, then element
+	tags := make([]uint32, 0, len(e.data)/4)
+	for _, v := range splitBinaryVM(e.data, 4) {
+		tags = append(tags, uint32(bo.Uint16(v[0:2]))<<16|uint32(bo.Uint16(v[2:4])))
+	}
+	return tags, nil
+}
+
+// GetReferencedTagNames returns the dictionary names of the tags referred to by the
+// AT element indexed by `tag`. See: GetReferencedTags for more information
+func (ds *DataSet) GetReferencedTagNames(tag uint32) ([]string, error) {
+	tags, err := ds.GetReferencedTags(tag)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		entry, _ := lookupTag(t)
+		names = append(names, entry.Name)
+	}
+	return names, nil
+}
+
 // addElement adds Element `e` to the data set.
 func (ds *DataSet) addElement(e Element) {
 	(*ds)[e.GetTag()] = e
@@ -373,6 +1194,223 @@ func (ds *DataSet) Len() int {
 	return len((*ds))
 }
 
+// TagsInGroup returns, in ascending order, the tags of the elements within group `group`.
+func (ds *DataSet) TagsInGroup(group uint16) []uint32 {
+	tags := make([]uint32, 0)
+	for _, e := range ds.ElementsMatching(func(tag uint32) bool { return uint16(tag>>16) == group }) {
+		tags = append(tags, e.GetTag())
+	}
+	return tags
+}
+
+// ElementsMatching returns, in ascending order of tag, the elements whose tag satisfies `match`.
+// For example, the elements of the (60xx) overlay groups are matched by:
+//
+//	func(tag uint32) bool { return tag&0xFF000000 == 0x60000000 }
+//
+// Elements nested within sequences are not considered.
+func (ds *DataSet) ElementsMatching(match func(tag uint32) bool) []Element {
+	elements := make([]Element, 0)
+	for tag, e := range *ds {
+		if match(tag) {
+			elements = append(elements, e)
+		}
+	}
+	sort.Slice(elements, func(i, j int) bool { return elements[i].GetTag() < elements[j].GetTag() })
+	return elements
+}
+
+// NonRetired returns a copy of the data set excluding elements which have been retired
+// from the standard, including those nested within sequences.
+func (ds *DataSet) NonRetired() DataSet {
+	filtered := make(DataSet, len(*ds))
+	for _, e := range *ds {
+		if e.IsRetired() {
+			continue
+		}
+		if e.HasItems() {
+			items := make([]Item, len(e.items))
+			for i, itm := range e.items {
+				items[i] = Item{fragment: itm.fragment}
+				if itm.dataset != nil {
+					items[i].dataset = itm.dataset.NonRetired()
+				}
+			}
+			e.items = items
+		}
+		filtered.addElement(e)
+	}
+	return filtered
+}
+
+// Redact returns a copy of the dicom which is safe to share, for example to report a parsing
+// problem, without disclosing its content. The values of the text elements are replaced with
+// placeholders of the same length, and binary values (pixel data, OB, OD, OF, OL, OV, OW and
+// UN) are zeroed; tags, VRs, value lengths and sequence structure are preserved. The file meta
+// group, along with the coded (CS) and numeric string (DS, IS) values upon which parsing
+// depends, are left intact. Note that when written, sequences and items are encoded with
+// undefined length regardless of their original framing, so problems specific to defined
+// length framing may not be reproduced by the redacted file.
+func (dcm *Dicom) Redact() Dicom {
+	redacted := *dcm
+	redacted.DataSet = redactDataSet(dcm.DataSet)
+	// frames are derived from the redacted pixel data, as when parsed
+	redacted.pixelData = newPixelData()
+	e := NewElement()
+	switch {
+	case redacted.GetElement(pixelDataTag, &e) && !config.ParsePixelDataAsElements:
+		redacted.onPixelData(e)
+	case redacted.GetElement(floatPixelDataTag, &e) || redacted.GetElement(doubleFloatPixelDataTag, &e):
+		redacted.onFloatPixelData(e)
+	}
+	return redacted
+}
+
+// redactDataSet returns a copy of `ds`, redacted as per `Dicom.Redact`.
+func redactDataSet(ds DataSet) DataSet {
+	redacted := make(DataSet, len(ds))
+	for _, e := range ds {
+		if e.HasItems() {
+			items := make([]Item, len(e.items))
+			for i := range e.items {
+				// the elements of deferred items must be parsed, lest their raw bytes be retained
+				itm, err := e.parseItem(i)
+				if err != nil {
+					itm = NewItem()
+				}
+				items[i] = Item{fragment: itm.fragment}
+				if itm.dataset != nil {
+					items[i].dataset = redactDataSet(itm.dataset)
+				}
+				// the basic offset table describes only the structure of the fragments
+				if e.GetTag() == pixelDataTag && i > 0 {
+					items[i].fragment = make([]byte, len(itm.fragment))
+				}
+			}
+			e.items = items
+		}
+		e.data = redactValue(e)
+		e.lazy = false
+		redacted.addElement(e)
+	}
+	return redacted
+}
+
+// redactValue returns the redacted value of `e`. Text values have each character replaced
+// by `X`, retaining the backslashes separating multiple values. UIDs have their digits
+// replaced by `9`, such that they remain valid.
+func redactValue(e Element) []byte {
+	tag, vr := e.GetTag(), e.GetVR()
+	switch {
+	case tag>>16 == 0x0002 || tag == 0x00080005:
+		return e.data
+	case e.lazy:
+		return make([]byte, e.datalen)
+	case tag == pixelDataTag || tag == floatPixelDataTag || tag == doubleFloatPixelDataTag:
+		return make([]byte, len(e.data))
+	}
+	switch vr {
+	case "OB", "OD", "OF", "OL", "OV", "OW", "UN":
+		return make([]byte, len(e.data))
+	case "AE", "AS", "DA", "DT", "LO", "LT", "PN", "SH", "ST", "TM", "UC", "UI", "UR", "UT":
+		redacted := make([]byte, len(e.data))
+		for i, c := range e.data {
+			switch {
+			case c == '\\' || c == 0x00:
+				redacted[i] = c
+			case vr != "UI":
+				redacted[i] = 'X'
+			case c >= '0' && c <= '9':
+				redacted[i] = '9'
+			default:
+				redacted[i] = c
+			}
+		}
+		return redacted
+	}
+	return e.data
+}
+
+// NormalizeText normalizes, in-place, the values of the character string elements of the
+// data set, including those nested within sequences, such that values which differ only
+// by insignificant spaces compare equal. Leading and trailing spaces are removed from each
+// value, except for the text VRs (LT, ST, UT), from which only trailing spaces are removed.
+// If `nfc` is set, values are also converted to Unicode Normalization Form C.
+// Values are not normalized upon parse, so that they remain faithful to the source.
+func (ds *DataSet) NormalizeText(nfc bool) {
+	for tag, e := range *ds {
+		for _, itm := range e.items {
+			if itm.dataset != nil {
+				itm.dataset.NormalizeText(nfc)
+			}
+		}
+		normalized := ""
+		switch vr := e.GetVR(); vr {
+		case "AE", "AS", "CS", "DA", "DS", "DT", "IS", "LO", "PN", "SH", "TM", "UC", "UI", "UR":
+			cutset := " "
+			if vr == "UI" {
+				cutset = " \x00"
+			}
+			values := strings.Split(string(e.data), "\\")
+			for i := range values {
+				values[i] = strings.Trim(values[i], cutset)
+			}
+			normalized = strings.Join(values, "\\")
+		case "LT", "ST", "UT":
+			normalized = strings.TrimRight(string(e.data), " ")
+		default:
+			continue
+		}
+		if nfc {
+			normalized = norm.NFC.String(normalized)
+		}
+		e.data = []byte(normalized)
+		e.datalen = uint32(len(e.data))
+		(*ds)[tag] = e
+	}
+}
+
+// TextContent returns the values of the textual elements of the data set (LO, LT, PN, SH, ST,
+// UC and UT), including those nested within sequences such as the content of a structured
+// report, for full-text indexing. Values are given one per line, in ascending tag order, with
+// the contents of a sequence following the sequence itself. Empty values are omitted.
+// If `all` is set, the values of the remaining character string VRs (e.g. codes, dates, numbers
+// and UIDs) are included too; binary values never are.
+func (ds *DataSet) TextContent(all bool) string {
+	lines := make([]string, 0)
+	ds.appendTextContent(&lines, all)
+	return strings.Join(lines, "\n")
+}
+
+// appendTextContent appends the lines of `TextContent` to `lines`.
+func (ds *DataSet) appendTextContent(lines *[]string, all bool) {
+	for _, e := range ds.ElementsMatching(func(uint32) bool { return true }) {
+		switch e.GetVR() {
+		case "LO", "LT", "PN", "SH", "ST", "UC", "UT":
+		case "AE", "AS", "CS", "DA", "DS", "DT", "IS", "TM", "UI", "UR":
+			if !all {
+				continue
+			}
+		default:
+			for _, itm := range e.items {
+				if itm.dataset != nil {
+					itm.dataset.appendTextContent(lines, all)
+				}
+			}
+			continue
+		}
+		values := [][]byte{e.data}
+		if e.SupportsMultiVM() {
+			values = splitCharacterStringVM(e.data)
+		}
+		for _, v := range values {
+			if v := strings.TrimSpace(string(v)); v != "" {
+				*lines = append(*lines, v)
+			}
+		}
+	}
+}
+
 // GetCharacterSet returns either the character set as defined in (0008,0005),
 // or ISO_IR 100 (default character set)
 func (ds *DataSet) GetCharacterSet() (cs *CharacterSet) {
@@ -383,8 +1421,11 @@ func (ds *DataSet) GetCharacterSet() (cs *CharacterSet) {
 	if ds.GetElement(0x00080005, &e) {
 		sa := []string{}
 		e.GetValue(&sa)
-		if cs, found = CharacterSetMap[sa[len(sa)-1]]; found {
-			return
+		// a present, but empty, value indicates the default character set
+		if len(sa) > 0 {
+			if cs, found = CharacterSetMap[sa[len(sa)-1]]; found {
+				return
+			}
 		}
 	}
 
@@ -406,15 +1447,29 @@ func (ds *DataSet) GetCharacterSet() (cs *CharacterSet) {
 type Item struct {
 	dataset  DataSet
 	fragment []byte
+	// offset and length locate the value of the item within the source; offset is -1 if unknown.
+	offset int64
+	length int64
+	// deferred is set if parsing of the item's elements was deferred, as requested by `DeferItems`,
+	// in which case raw holds its encoded value. See: `Element.ParseItem`.
+	deferred bool
+	raw      []byte
 }
 
 // NewItem returns a fresh Item with a blank data set.
 func NewItem() Item {
 	return Item{
 		dataset: make(DataSet, 0),
+		offset:  -1,
 	}
 }
 
+// GetDataSet returns the data set embedded within this item. Elements added to the
+// returned data set are reflected within the item.
+func (itm *Item) GetDataSet() DataSet {
+	return itm.dataset
+}
+
 /*
 ===============================================================================
 	Element
@@ -433,6 +1488,16 @@ type Element struct {
 	isLittleEndian bool
 	datalen        uint32
 	items          []Item
+	// lazy is set if the value was skipped during a lazy parse, in which case
+	// valueOffset holds its position within the source (or -1 if unknown).
+	lazy        bool
+	valueOffset int64
+	// valueLength holds the length declared in the source, before any padding was stripped
+	valueLength uint32
+	// skipped is set if the value was discarded, as requested by `SkipElement`
+	skipped bool
+	// implicitVR is set if the element was read with implicit VR
+	implicitVR bool
 }
 
 // NewElement returns a fresh Element
@@ -490,19 +1555,309 @@ func (e *Element) GetVM() string {
 	return e.dictEntry.VM
 }
 
-// GetName returns the Element's "Name" component
-func (e *Element) GetName() string {
-	return e.dictEntry.Name
+// Encoding returns the encoding with which the element was read. This is usually that of the data
+// set as a whole (or explicit VR little endian for the meta group), but may differ if the source
+// changes encoding part way through; `Dicom.CheckAlignment` reports where the parse went astray.
+// Elements which were not read from a source report explicit VR, in their byte order.
+func (e *Element) Encoding() Encoding {
+	return Encoding{ImplicitVR: e.implicitVR, LittleEndian: e.isLittleEndian}
+}
+
+// GetName returns the Element's "Name" component
+func (e *Element) GetName() string {
+	return e.dictEntry.Name
+}
+
+// Keyword returns the Element's dictionary keyword (e.g. "PatientName"). Its return value (bool)
+// indicates whether the tag is in the dictionary; if not, the keyword is that synthesised
+// by `GetName`, e.g. "Unknown(0009,0010)", which is not authoritative.
+func (e *Element) Keyword() (string, bool) {
+	if entry, found := dictionary.DicomDictionary[e.GetTag()]; found {
+		return entry.Name, true
+	}
+	return e.GetName(), false
+}
+
+// IsRetired returns whether the element has been retired from the standard, as per the dictionary
+func (e *Element) IsRetired() bool {
+	return e.dictEntry.Retired
+}
+
+// SupportsMultiVM returns whether the element's VR permits multiple values.
+// Of the character string VRs, LT, ST, UR and UT may only hold a single value; the
+// "other" (O*) binary VRs, SQ and UN are each considered to hold a single value.
+func (e *Element) SupportsMultiVM() bool {
+	switch e.GetVR() {
+	case "AE", "AS", "AT", "CS", "DA", "DS", "DT", "FD", "FL", "IS", "LO", "PN", "SH",
+		"SL", "SS", "SV", "TM", "UC", "UI", "UL", "US", "UV":
+		return true
+	}
+	return false
+}
+
+// checkVRLength returns an error describing the first value of the element which violates
+// the length permitted by its VR, as given by `VRConformanceMap`, or nil if there is none.
+func (e *Element) checkVRLength() error {
+	conformance, restricted := VRConformanceMap[e.GetVR()]
+	if !restricted || e.HasItems() || e.lazy {
+		return nil
+	}
+	max := conformance.MaximumLengthBytes
+	switch vr := e.GetVR(); vr {
+	case "AT", "FD", "FL", "SL", "SS", "SV", "UL", "US", "UV":
+		if len(e.data)%max != 0 {
+			return fmt.Errorf("%s has value of %d bytes; VR %s requires a multiple of %d", e.dictEntry, len(e.data), vr, max)
+		}
+		return nil
+	}
+	values := [][]byte{e.data}
+	if e.SupportsMultiVM() {
+		values = splitCharacterStringVM(e.data)
+	}
+	for i, v := range values {
+		length := len(v)
+		switch e.GetVR() {
+		case "LO", "LT", "SH", "ST":
+			length = utf8.RuneCount(v)
+		case "PN":
+			// the limit applies to each component group: alphabetic, ideographic and phonetic
+			length = 0
+			for _, group := range bytes.Split(v, []byte("=")) {
+				if n := utf8.RuneCount(group); n > length {
+					length = n
+				}
+			}
+		}
+		switch {
+		case length > max:
+			return fmt.Errorf("%s value #%d has length %d, exceeding the maximum of %d for VR %s", e.dictEntry, i+1, length, max, e.GetVR())
+		case conformance.FixedLength && length != 0 && length != max:
+			return fmt.Errorf("%s value #%d has length %d; VR %s requires %d", e.dictEntry, i+1, length, e.GetVR(), max)
+		}
+	}
+	return nil
+}
+
+// ConformsToVRLength returns whether each value of the element is within the length
+// permitted by its VR, as given by `VRConformanceMap`. Sequences are not checked.
+func (e Element) ConformsToVRLength() bool {
+	return e.checkVRLength() == nil
+}
+
+// Describe returns a human-readable, single line description of the element: its tag, name,
+// VR and value. Multiple values are separated by "\". The values of sequences and binary
+// elements are summarised, rather than printed.
+func (e *Element) Describe() string {
+	return fmt.Sprintf("%s [%s] %s", e.dictEntry, e.GetVR(), e.DescribeValue())
+}
+
+// DescribeValue returns the value of the element, as given by `Describe`: multiple values are
+// separated by "\", and the values of sequences and binary elements are summarised.
+func (e *Element) DescribeValue() string {
+	value := ""
+	strs := []string{}
+	switch {
+	case e.lazy:
+		value = fmt.Sprintf("[%d bytes, not loaded]", e.datalen)
+	case e.HasItems() || e.GetVR() == "SQ":
+		value = fmt.Sprintf("[%d items]", len(e.items))
+	case e.GetVR() != "UN" && e.GetValue(&strs) == nil:
+		value = strings.Join(strs, `\`)
+	default:
+		value = fmt.Sprintf("[%d bytes]", len(e.data))
+		if e.GetVR() == "UN" || strings.HasPrefix(e.GetVR(), "O") {
+			break
+		}
+		for _, dst := range []interface{}{&[]uint16{}, &[]uint32{}, &[]uint64{}, &[]int16{}, &[]int32{}, &[]int64{}, &[]float32{}, &[]float64{}} {
+			if e.GetValue(dst) == nil {
+				value = strings.Replace(strings.Trim(fmt.Sprint(dst), "&[]"), " ", `\`, -1)
+				break
+			}
+		}
+	}
+	return value
+}
+
+// describedElement is the representation of an element within the tree given by `DescribeJSON`.
+type describedElement struct {
+	Tag   string               `json:"tag"`
+	Name  string               `json:"name"`
+	VR    string               `json:"vr"`
+	Value string               `json:"value,omitempty"`
+	Items [][]describedElement `json:"items,omitempty"`
+}
+
+// describeDataSet returns the elements of `ds`, in ascending tag order, with the
+// items of sequences described recursively.
+func describeDataSet(ds DataSet) []describedElement {
+	described := make([]describedElement, 0, len(ds))
+	for _, e := range ds.ElementsMatching(func(uint32) bool { return true }) {
+		de := describedElement{
+			Tag:  fmt.Sprintf("(%04X,%04X)", e.GetTag()>>16, e.GetTag()&0xFFFF),
+			Name: e.GetName(),
+			VR:   e.GetVR(),
+		}
+		if e.GetVR() == "SQ" && !e.lazy {
+			for _, itm := range e.items {
+				de.Items = append(de.Items, describeDataSet(itm.GetDataSet()))
+			}
+		} else {
+			de.Value = e.DescribeValue()
+		}
+		described = append(described, de)
+	}
+	return described
+}
+
+// IsEmpty returns whether the element is present with a zero-length value, such as a Type 2
+// attribute whose value is unknown. This is distinct from the element being absent, in which
+// case `GetElement` does not find it; empty elements are written with zero length, such that
+// they remain present when read back.
+func (e *Element) IsEmpty() bool {
+	return len(e.data) == 0 && len(e.items) == 0 && !e.lazy
+}
+
+// HasItems returns whether the element contains nested items
+func (e *Element) HasItems() bool {
+	return len(e.items) > 0
+}
+
+// GetItems returns nested items within this element
+func (e *Element) GetItems() []Item {
+	return e.items
+}
+
+// CollectItemValues appends the value of the element indexed by `subTag` within each of the
+// element's items to `dst`, which must be a pointer to a slice whose element type is accepted
+// by `GetValue`, e.g. *[]string or *[][]uint16. An error is returned if any item lacks the
+// element, such that the values remain aligned with the items.
+func (e *Element) CollectItemValues(subTag uint32, dst interface{}) error {
+	ptr := reflect.ValueOf(dst)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("CollectItemValues(%s): destination is not a pointer to a slice", reflect.TypeOf(dst))
+	}
+	slice := ptr.Elem()
+	for i, itm := range e.items {
+		sub := NewElement()
+		if !itm.dataset.GetElement(subTag, &sub) {
+			return fmt.Errorf("CollectItemValues(): item #%d of %s does not contain %s", i, e.dictEntry, NewElementWithTag(subTag).dictEntry)
+		}
+		value := reflect.New(slice.Type().Elem())
+		if err := sub.GetValue(value.Interface()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, value.Elem())
+	}
+	ptr.Elem().Set(slice)
+	return nil
+}
+
+// ItemRange returns the offset and length of the value of item `i` within the source from which it was
+// read, and whether its elements have been parsed. The offset is -1 if unknown, as is the case for items
+// not read from a source, or read from a deflated data set. Items are left unparsed if `DeferItems`
+// requested it, in which case they are parsed by `ParseItem`.
+func (e *Element) ItemRange(i int) (offset int64, length int64, parsed bool, err error) {
+	if i < 0 || i >= len(e.items) {
+		return -1, 0, false, fmt.Errorf("ItemRange(): %s has %d items; item #%d does not exist", e.dictEntry, len(e.items), i)
+	}
+	itm := e.items[i]
+	return itm.offset, itm.length, !itm.deferred, nil
+}
+
+// ParseItem returns item `i` of the element, parsing its elements if this was deferred (see `DeferItems`).
+// The parsed item replaces the deferred one, within any copy of the element, such that it is parsed once.
+func (e *Element) ParseItem(i int) (Item, error) {
+	if i < 0 || i >= len(e.items) {
+		return Item{}, fmt.Errorf("ParseItem(): %s has %d items; item #%d does not exist", e.dictEntry, len(e.items), i)
+	}
+	itm, err := e.parseItem(i)
+	if err != nil {
+		return Item{}, err
+	}
+	e.items[i] = itm
+	return itm, nil
+}
+
+// parseItem returns item `i` of the element, parsing its elements if this was deferred.
+// Unlike `ParseItem`, the element is left unchanged.
+func (e *Element) parseItem(i int) (Item, error) {
+	if !e.items[i].deferred {
+		return e.items[i], nil
+	}
+	raw := e.items[i].raw
+	elr := NewElementReader(bin.NewReaderBytes(raw, binary.LittleEndian))
+	elr.SetImplicitVR(e.implicitVR)
+	elr.SetLittleEndian(e.isLittleEndian)
+	itm := NewItem()
+	itm.offset, itm.length = e.items[i].offset, e.items[i].length
+	for elr.br.GetPosition() < int64(len(raw)) {
+		sub := NewElement()
+		sub.isLittleEndian = e.isLittleEndian
+		if err := elr.ReadElement(&sub); err != nil {
+			return Item{}, CorruptElement{fmt.Errorf("ParseItem(): item #%d of %s is malformed: %v", i, e.dictEntry, err)}
+		}
+		if !sub.skipped {
+			itm.dataset.addElement(sub)
+		}
+	}
+	return itm, nil
+}
+
+// Items returns nested items within this element
+// See: GetItems
+func (e *Element) Items() []Item {
+	return e.items
+}
+
+// AddItem appends `item` to the element's nested items. The element will be
+// treated as a sequence of undefined length, regardless of its previous value.
+func (e *Element) AddItem(item Item) {
+	e.items = append(e.items, item)
+	e.data = nil
+	e.datalen = 0xFFFFFFFF
+}
+
+// IsLazy returns whether the element's value was skipped, rather than read, during a lazy parse.
+func (e *Element) IsLazy() bool {
+	return e.lazy
 }
 
-// HasItems returns whether the element contains nested items
-func (e *Element) HasItems() bool {
-	return len(e.items) > 0
+// LoadValue reads the value of an element which was skipped during a lazy parse
+// from `src`, which should contain the same bytes as were parsed.
+func (e *Element) LoadValue(src io.ReaderAt) error {
+	if !e.lazy {
+		return nil
+	}
+	if e.valueOffset < 0 {
+		return errors.New("LoadValue(): value offset is unknown, as the data set was deflated")
+	}
+	data := make([]byte, e.datalen)
+	if _, err := src.ReadAt(data, e.valueOffset); err != nil {
+		return err
+	}
+	e.data, e.lazy = data, false
+	if e.GetTag() != pixelDataTag {
+		stripPadding(e)
+	}
+	return nil
 }
 
-// GetItems returns nested items within this element
-func (e *Element) GetItems() []Item {
-	return e.items
+// StringValueNoAlloc returns the value of a textual element as a single string, without
+// splitting multiple values or copying; its return value (bool) indicates whether the
+// element's VR is textual. This is intended for hot loops (e.g. filtering many files by
+// Modality), where `GetValue` would allocate upon every call.
+//
+// The returned string shares memory with the element's value. As Go strings are immutable,
+// the value must not be modified (e.g. by `SetValue`) whilst the string is in use; doing so
+// is undefined behaviour, and the string must not be relied upon to reflect the change.
+func (e *Element) StringValueNoAlloc() (string, bool) {
+	if e.lazy || !e.supportsType((*string)(nil)) {
+		return "", false
+	}
+	if len(e.data) == 0 {
+		return "", true
+	}
+	return *(*string)(unsafe.Pointer(&e.data)), true
 }
 
 // Len returns the data literal bytelength
@@ -510,6 +1865,13 @@ func (e *Element) Len() int {
 	return int(e.datalen)
 }
 
+// ValueLength returns the value length declared by the element in the source from which it was
+// read, which may be 0xFFFFFFFF (undefined). Unlike `Len`, this includes any padding which was
+// stripped when the value was read. Elements which were not read from a source return zero.
+func (e *Element) ValueLength() uint32 {
+	return e.valueLength
+}
+
 func (e *Element) supportsType(typ interface{}) bool {
 	/*
 			TODO:
@@ -525,7 +1887,7 @@ func (e *Element) supportsType(typ interface{}) bool {
 	case string, *string, []string, *[]string:
 		switch e.GetVR() {
 		case "SH", "LO", "ST", "PN", "LT", "UT",
-			"IS", "DS", "TM", "DA", "DT", "UI", "CS", "AS", "AE": // These shouldnt be parsed using charset btw
+			"IS", "DS", "TM", "DA", "DT", "UI", "CS", "AS", "AE", "UC", "UR": // These shouldnt be parsed using charset btw
 			return true
 		}
 	case float32, *float32, []float32, *[]float32:
@@ -552,6 +1914,14 @@ func (e *Element) supportsType(typ interface{}) bool {
 		if e.GetVR() == "UL" || e.GetVR() == "AT" {
 			return true
 		}
+	case int64, *int64, []int64, *[]int64:
+		if e.GetVR() == "SV" {
+			return true
+		}
+	case uint64, *uint64, []uint64, *[]uint64:
+		if e.GetVR() == "UV" || e.GetVR() == "OV" {
+			return true
+		}
 	case []byte, *[]byte:
 		// every VR can be expressed as a sequence of bytes
 		return true
@@ -574,6 +1944,10 @@ func (e *Element) GetValue(dst interface{}) error {
 		*typedDst = string(e.data)
 	case *[]string:
 		for _, v := range splitCharacterStringVM(e.data) {
+			if e.GetVR() == "UI" {
+				// only the value as a whole is padded, but some writers pad each UID
+				v = bytes.TrimRight(v, "\x00")
+			}
 			*typedDst = append(*typedDst, string(v))
 		}
 	case *[]byte:
@@ -587,6 +1961,9 @@ func (e *Element) GetValue(dst interface{}) error {
 			}
 		}
 	case *float32:
+		if len(e.data) < 4 {
+			return ErrValueTooShort
+		}
 		*typedDst = math.Float32frombits(binary.LittleEndian.Uint32(e.data[:4]))
 	case *[]float64:
 		for _, v := range splitBinaryVM(e.data, 8) {
@@ -597,6 +1974,9 @@ func (e *Element) GetValue(dst interface{}) error {
 			}
 		}
 	case *float64:
+		if len(e.data) < 8 {
+			return ErrValueTooShort
+		}
 		*typedDst = math.Float64frombits(binary.LittleEndian.Uint64(e.data[:8]))
 	case *[]int16:
 		for _, v := range splitBinaryVM(e.data, 2) {
@@ -607,6 +1987,9 @@ func (e *Element) GetValue(dst interface{}) error {
 			}
 		}
 	case *int16:
+		if len(e.data) < 2 {
+			return ErrValueTooShort
+		}
 		if e.isLittleEndian {
 			*typedDst = int16(binary.LittleEndian.Uint16(e.data))
 		} else {
@@ -621,11 +2004,82 @@ func (e *Element) GetValue(dst interface{}) error {
 			}
 		}
 	case *int32:
+		if len(e.data) < 4 {
+			return ErrValueTooShort
+		}
 		if e.isLittleEndian {
 			*typedDst = int32(binary.LittleEndian.Uint32(e.data))
 		} else {
 			*typedDst = int32(binary.BigEndian.Uint32(e.data))
 		}
+	case *[]uint16:
+		for _, v := range splitBinaryVM(e.data, 2) {
+			if e.isLittleEndian {
+				*typedDst = append(*typedDst, binary.LittleEndian.Uint16(v))
+			} else {
+				*typedDst = append(*typedDst, binary.BigEndian.Uint16(v))
+			}
+		}
+	case *uint16:
+		if len(e.data) < 2 {
+			return ErrValueTooShort
+		}
+		if e.isLittleEndian {
+			*typedDst = binary.LittleEndian.Uint16(e.data)
+		} else {
+			*typedDst = binary.BigEndian.Uint16(e.data)
+		}
+	case *[]uint32:
+		for _, v := range splitBinaryVM(e.data, 4) {
+			if e.isLittleEndian {
+				*typedDst = append(*typedDst, binary.LittleEndian.Uint32(v))
+			} else {
+				*typedDst = append(*typedDst, binary.BigEndian.Uint32(v))
+			}
+		}
+	case *uint32:
+		if len(e.data) < 4 {
+			return ErrValueTooShort
+		}
+		if e.isLittleEndian {
+			*typedDst = binary.LittleEndian.Uint32(e.data)
+		} else {
+			*typedDst = binary.BigEndian.Uint32(e.data)
+		}
+	case *[]int64:
+		for _, v := range splitBinaryVM(e.data, 8) {
+			if e.isLittleEndian {
+				*typedDst = append(*typedDst, int64(binary.LittleEndian.Uint64(v)))
+			} else {
+				*typedDst = append(*typedDst, int64(binary.BigEndian.Uint64(v)))
+			}
+		}
+	case *int64:
+		if len(e.data) < 8 {
+			return ErrValueTooShort
+		}
+		if e.isLittleEndian {
+			*typedDst = int64(binary.LittleEndian.Uint64(e.data))
+		} else {
+			*typedDst = int64(binary.BigEndian.Uint64(e.data))
+		}
+	case *[]uint64:
+		for _, v := range splitBinaryVM(e.data, 8) {
+			if e.isLittleEndian {
+				*typedDst = append(*typedDst, binary.LittleEndian.Uint64(v))
+			} else {
+				*typedDst = append(*typedDst, binary.BigEndian.Uint64(v))
+			}
+		}
+	case *uint64:
+		if len(e.data) < 8 {
+			return ErrValueTooShort
+		}
+		if e.isLittleEndian {
+			*typedDst = binary.LittleEndian.Uint64(e.data)
+		} else {
+			*typedDst = binary.BigEndian.Uint64(e.data)
+		}
 	// if not writable type (pointer), return error
 	case bool, string,
 		int, int8, int16, int32, int64,
@@ -638,6 +2092,91 @@ func (e *Element) GetValue(dst interface{}) error {
 	return nil
 }
 
+// maxDecimalStringLength is the maximum length, in bytes, of a single DS value.
+const maxDecimalStringLength = 16
+
+// formatDecimalString formats `value` as a DS value of no more than 16 characters,
+// reducing precision (and using exponential notation where needed) to fit.
+func formatDecimalString(value float64) (string, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", fmt.Errorf("%v cannot be represented as a decimal string", value)
+	}
+	if str := strconv.FormatFloat(value, 'g', -1, 64); len(str) <= maxDecimalStringLength {
+		return str, nil
+	}
+	for precision := maxDecimalStringLength; precision > 0; precision-- {
+		if str := strconv.FormatFloat(value, 'g', precision, 64); len(str) <= maxDecimalStringLength {
+			return str, nil
+		}
+	}
+	return "", fmt.Errorf("%v cannot be represented within %d characters", value, maxDecimalStringLength)
+}
+
+// SetValue sets the element's "value" component from `src`.
+// Currently supported are `string` for textual VRs, with multiple values separated by "\";
+// `[]byte` for the raw value of any VR, binary values being in the byte order of the element
+// (little endian, unless it was read otherwise); `[][]byte` for (7FE0,0010) PixelData, whose
+// frames are encapsulated one per fragment, with an empty Basic Offset Table; and `float64` and
+// `[]float64` for DS elements, which are formatted as conformant decimal strings.
+// The value is padded to even length upon write.
+func (e *Element) SetValue(src interface{}) error {
+	var values []float64
+	switch typedSrc := src.(type) {
+	case string:
+		return e.setData(src, []byte(typedSrc))
+	case []byte:
+		return e.setData(src, typedSrc)
+	case [][]byte:
+		if e.GetTag() != pixelDataTag {
+			return fmt.Errorf("SetValue(%s): only the frames of PixelData can be encapsulated, not %s", reflect.TypeOf(src), e.dictEntry)
+		}
+		offsetTable := NewItem()
+		offsetTable.fragment = []byte{}
+		e.items = []Item{offsetTable}
+		for _, frame := range typedSrc {
+			fragment := NewItem()
+			fragment.fragment = frame
+			e.items = append(e.items, fragment)
+		}
+		e.data = nil
+		e.datalen = 0xFFFFFFFF
+		return nil
+	case float64:
+		values = []float64{typedSrc}
+	case []float64:
+		values = typedSrc
+	default:
+		return fmt.Errorf(`SetValue(%s): setting from type "%v" is not yet implemented`, reflect.TypeOf(src), reflect.TypeOf(src))
+	}
+	if e.GetVR() != "DS" {
+		return fmt.Errorf("SetValue(%s): value of %s cannot be set from a %s", reflect.TypeOf(src), e.dictEntry, reflect.TypeOf(src))
+	}
+	strs := make([]string, len(values))
+	for i, v := range values {
+		str, err := formatDecimalString(v)
+		if err != nil {
+			return err
+		}
+		strs[i] = str
+	}
+	e.data = []byte(strings.Join(strs, "\\"))
+	e.datalen = uint32(len(e.data))
+	e.items = nil
+	return nil
+}
+
+// setData sets the value of the element to `data`, as given to `SetValue` as `src`.
+func (e *Element) setData(src interface{}, data []byte) error {
+	if !e.supportsType(src) {
+		return fmt.Errorf("SetValue(%s): value of %s cannot be set from a %s", reflect.TypeOf(src), e.dictEntry, reflect.TypeOf(src))
+	}
+	e.data = data
+	e.datalen = uint32(len(data))
+	e.items = nil
+	e.lazy = false
+	return nil
+}
+
 /*
 ===============================================================================
 	ElementReader
@@ -652,6 +2191,19 @@ type ElementReader struct {
 	br       bin.Reader
 	implicit bool
 	charSet  *CharacterSet
+	warnings []string
+	// sourceVR holds the VR most recently read from the source, in explicit VR mode
+	sourceVR string
+	// lazy causes large binary values to be skipped, and framing to be validated strictly
+	lazy bool
+	// compact causes large binary values to be skipped, as when lazy, for `FromReaderCompact`
+	compact bool
+	// inflated is set when reading from a deflated data set, whose offsets do not match the source
+	inflated bool
+	// inputLength is the length of the source, if known ahead of reading (else zero)
+	inputLength int64
+	// empty records the element most recently read with an empty value, in explicit VR
+	empty emptyElement
 	tmpBuffers
 }
 
@@ -675,9 +2227,12 @@ func NewElementReader(source bin.Reader) (er ElementReader) {
 // should, theoretically, contain embedded elements. (if false, it indicates
 // that the element will contain "data fragments")
 func shouldReadEmbeddedElements(e Element) bool {
-	// if tag is PixelData, return false
-	return e.GetTag() != pixelDataTag
+	// if tag is PixelData, return false, unless configured otherwise
+	if e.GetTag() == pixelDataTag {
+		return config.ParsePixelDataAsElements
+	}
 	// else return true
+	return true
 }
 
 // lookupTag searches for the corresponding `dictionary.DicomDictionary` entry for the given tag uint32
@@ -692,6 +2247,34 @@ func lookupTag(t uint32) (entry *dictionary.DictEntry, found bool) {
 	return
 }
 
+// LookupTagByName returns the tag whose dictionary name (e.g. "PatientName") is `name`.
+// its return value (bool) indicates whether such a tag was found.
+func LookupTagByName(name string) (uint32, bool) {
+	for tag, entry := range dictionary.DicomDictionary {
+		if entry.Name == name {
+			return tag, true
+		}
+	}
+	return 0, false
+}
+
+// TagCategory classifies `tag` as one of: "Command", for elements of the (0000) command group;
+// "FileMeta", for those of the (0002) meta group; "Private", for those of odd-numbered groups;
+// "Group Length", for the remaining (gggg,0000) group length elements; or otherwise "Standard".
+func TagCategory(tag uint32) string {
+	switch group := tag >> 16; {
+	case group == 0x0000:
+		return "Command"
+	case group == 0x0002:
+		return "FileMeta"
+	case group%2 == 1:
+		return "Private"
+	case tag&0xFFFF == 0x0000:
+		return "Group Length"
+	}
+	return "Standard"
+}
+
 // IsLittleEndian returns whether this ElementReader is set to parse
 // data according to Little Endian byte ordering.
 func (elr *ElementReader) IsLittleEndian() bool {
@@ -707,6 +2290,7 @@ func (elr *ElementReader) SetLittleEndian(isLittleEndian bool) {
 	} else {
 		elr.br.SetByteOrder(binary.BigEndian)
 	}
+	elr.empty = emptyElement{}
 }
 
 // IsImplicitVR returns whether this ElementReader is set to parse
@@ -719,6 +2303,20 @@ func (elr *ElementReader) IsImplicitVR() bool {
 // data according to the VR component being implicitly defined
 func (elr *ElementReader) SetImplicitVR(isImplicitVR bool) {
 	elr.implicit = isImplicitVR
+	// an element read in another encoding cannot be re-read
+	elr.empty = emptyElement{}
+}
+
+// SetTransferSyntax sets the VR encoding and byte ordering of this ElementReader
+// according to the registered encoding of transfer syntax `ts`. Unregistered transfer
+// syntaxes are assumed to use explicit VR little endian encoding, as do most compressed ones.
+func (elr *ElementReader) SetTransferSyntax(ts string) {
+	enc, found := GetEncodingForTransferSyntax(ts)
+	if !found {
+		enc = Encoding{ImplicitVR: false, LittleEndian: true}
+	}
+	elr.SetImplicitVR(enc.ImplicitVR)
+	elr.SetLittleEndian(enc.LittleEndian)
 }
 
 // readElementVR attempts to read/decode the "VR" component of an Element
@@ -734,14 +2332,90 @@ func (elr *ElementReader) readElementVR(dst *Element) error {
 	if elr.err = elr.br.ReadBytes(elr._1kb[:2]); elr.err != nil {
 		return elr.err
 	}
+	elr.sourceVR = internVR(elr._1kb[:2])
 	// only overwrite the existing dictionary entry's VR if we have UN
 	// and source has something else (has added value)
-	if (dst.GetVR() == "UN" || dst.GetVR() == "") && string(elr._1kb[:2]) != "UN" {
-		dst.dictEntry.VR = string(elr._1kb[:2])
+	if (dst.GetVR() == "UN" || dst.GetVR() == "") && elr.sourceVR != "UN" {
+		dst.dictEntry.VR = elr.sourceVR
 	}
 	return nil
 }
 
+// addWarning records a non-fatal problem encountered whilst reading.
+// Arguments are handled in the manner of fmt.Printf
+func (elr *ElementReader) addWarning(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	Debug(msg)
+	elr.warnings = append(elr.warnings, msg)
+}
+
+// Warnings returns the non-fatal problems encountered by this ElementReader.
+func (elr *ElementReader) Warnings() []string {
+	return elr.warnings
+}
+
+// paddingForVR returns the byte used to pad values of `vr` to an even length.
+// Textual VRs are padded with a space, whereas UI and binary VRs are padded with NULL.
+func paddingForVR(vr string) byte {
+	switch vr {
+	case "AE", "AS", "CS", "DA", "DS", "DT", "IS", "LO", "LT", "PN", "SH", "ST", "TM", "UR", "UT":
+		return 0x20
+	}
+	return 0x00
+}
+
+// stripPadding removes a leading or trailing padding byte from the value of `e`, per padding character.
+func stripPadding(e *Element) {
+	padchars := []byte{0x00, 0x20}
+	switch e.GetVR() {
+	// binary values (OB, OD, OF, OW) are held as read: a NULL byte may legitimately begin
+	// or end them, as for the `00 01` of (0002,0001) FileMetaInformationVersion
+	case "UI", "CS", "DS", "IS", "AE", "AS", "DA", "DT", "LO", "LT", "PN", "SH", "ST", "TM", "UR", "UT":
+		for _, chr := range padchars {
+			if len(e.data) == 0 {
+				break
+			}
+			if e.data[len(e.data)-1] == chr {
+				e.data = e.data[:len(e.data)-1]
+				e.datalen--
+			} else if e.data[0] == chr { // NOTE: assumes padding will only take place on one side. Should be fine.
+				e.data = e.data[1:]
+				e.datalen--
+			}
+		}
+	}
+}
+
+// lazyValueThreshold is the length above which binary values are skipped during a lazy (or compact) parse.
+const lazyValueThreshold = 1024
+
+// shouldSkipValue returns whether the value of `e` should be skipped rather than read,
+// which is the case for large binary values during a lazy (or compact) parse.
+func (elr *ElementReader) shouldSkipValue(e *Element) bool {
+	if !(elr.lazy || elr.compact) || e.datalen <= lazyValueThreshold {
+		return false
+	}
+	if e.GetTag() == pixelDataTag {
+		return true
+	}
+	switch e.GetVR() {
+	case "OB", "OD", "OF", "OL", "OV", "OW", "UN":
+		return true
+	}
+	return false
+}
+
+// skipValue discards the value of `e` from the reader, recording its offset such that
+// it can later be read with `Element.LoadValue`.
+func (elr *ElementReader) skipValue(e *Element) error {
+	e.lazy = true
+	e.valueOffset = elr.br.GetPosition()
+	if elr.inflated {
+		e.valueOffset = -1
+	}
+	return elr.br.Discard(int64(e.datalen))
+}
+
 // readElementLength attempts to read/decode the "Length" component of an Element
 // into `dst`.
 //
@@ -754,30 +2428,121 @@ func (elr *ElementReader) readElementLength(dst *Element) error {
 		}
 	} else {
 		// issue #6: use *source* VR as basis for deciding whether to skip / size of length integer.
-		// in explicit VR mode, if the VR is OB, OW, SQ, UN or UT, skip two bytes and read as uint32, else uint16.
-		switch dst.GetVR() {
-		case "OB", "OW", "SQ", "UN", "UT":
-			// skip 2 bytes
-			if elr.err = elr.br.Discard(2); elr.err != nil {
-				return elr.err
-			}
-			// and read length as 32 bits
+		// in explicit VR mode, if the VR is OB, OD, OF, OL, OV, OW, SQ, SV, UC, UN, UR, UT or UV, skip two
+		// bytes and read as uint32, else uint16.
+		vr := elr.sourceVR
+		if vr == "" {
+			vr = dst.GetVR()
+		}
+		elr.sourceVR = ""
+		long := hasLongLength(vr)
+		start := elr.br.GetPosition() - 6
+		// the first two bytes are either the reserved bytes, or the 16-bit length
+		if elr.err = elr.br.ReadUint16(&elr.ui16); elr.err != nil {
+			return elr.err
+		}
+		dst.datalen = uint32(elr.ui16)
+		switch {
+		case long && elr.ui16 != 0 && elr.isFollowedByElement(int(elr.ui16)):
+			// the reserved bytes must be zero, so may instead be a 16-bit length
+			return elr.reportAlternateLength(dst.dictEntry, start, vr, long)
+		case long:
 			if elr.err = elr.br.ReadUint32(&dst.datalen); elr.err != nil {
 				return elr.err
 			}
-		default:
-			// read length as 16 bits
-			if elr.err = elr.br.ReadUint16(&elr.ui16); elr.err != nil {
-				return elr.err
-			}
-			dst.datalen = uint32(elr.ui16)
+		case elr.ui16 == 0:
+			// should the next element be garbage, this may instead have been the reserved bytes of a
+			// 32-bit length. See: `rereadEmptyElement`
+			elr.empty = emptyElement{dictEntry: dst.dictEntry, vr: vr, start: start, end: elr.br.GetPosition()}
 		}
 	}
 	return nil
 }
 
+// reportAlternateLength reports that the element at offset `start`, of VR `vr`, was read with the
+// alternate size of length field to that which the standard specifies for its VR. In `StrictMode`,
+// a `CorruptElement` error is returned; otherwise a warning is recorded.
+func (elr *ElementReader) reportAlternateLength(entry *dictionary.DictEntry, start int64, vr string, long bool) error {
+	bits, alternateBits := 16, 32
+	if long {
+		bits, alternateBits = 32, 16
+	}
+	msg := fmt.Sprintf("%s at offset %d has VR %s, whose length should be encoded with %d bits, but was encoded with %d",
+		entry, start, vr, bits, alternateBits)
+	if config.StrictMode {
+		return CorruptElement{errors.New(msg)}
+	}
+	elr.addWarning("%s; reading it as such", msg)
+	return nil
+}
+
+// emptyElement records an element which was read with an empty value and a 16-bit length field,
+// from its tag at offset `start` to the end of its length field at offset `end`.
+type emptyElement struct {
+	dictEntry  *dictionary.DictEntry
+	vr         string
+	start, end int64
+}
+
+// rereadEmptyElement re-reads `empty`, the element read immediately before `dst`, as if its length
+// had been encoded with two reserved bytes and 32 bits, once the tag and VR read into `dst` proved
+// to be garbage: they are instead its length and the start of its value. This is only done should
+// an element, or the end of the input, follow the value. Its return value (bool) indicates whether
+// `dst` now holds the re-read element.
+func (elr *ElementReader) rereadEmptyElement(empty emptyElement, tag uint32, dst *Element) (bool, error) {
+	var raw [4]byte
+	bo := elr.br.GetByteOrder()
+	bo.PutUint16(raw[0:], uint16(tag>>16))
+	bo.PutUint16(raw[2:], uint16(tag))
+	length := bo.Uint32(raw[:])
+	if length < 2 || !elr.isFollowedByElement(int(length)-2) {
+		return false, nil
+	}
+	if elr.err = elr.reportAlternateLength(empty.dictEntry, empty.start, empty.vr, false); elr.err != nil {
+		return true, elr.err
+	}
+	dst.dictEntry = empty.dictEntry
+	dst.datalen, dst.valueLength = length, length
+	dst.data = make([]byte, length)
+	copy(dst.data, elr.sourceVR)
+	elr.sourceVR = ""
+	if elr.err = elr.br.ReadBytes(dst.data[2:]); elr.err != nil {
+		return true, elr.err
+	}
+	return true, elr.onValueRead(dst)
+}
+
+// isFollowedByElement returns whether the input ends, or a plausible element or item begins, `at`
+// bytes beyond the reader's position. It is used to diagnose an explicit VR element whose length
+// field was encoded with a 16-bit length where the standard specifies two reserved bytes and a
+// 32-bit length, or vice versa, which writers predating the newer VRs (e.g. UC and UR) are known
+// to do, and which corrupts every subsequent element. It is only consulted once the standard
+// reading of the length field has failed: the reserved bytes are not zero, or the element which
+// follows an empty value is garbage. Values too long to be peeked are presumed to be read as per the standard.
+func (elr *ElementReader) isFollowedByElement(at int) bool {
+	if at < 0 || at+6 > len(elr._1kb) {
+		return false
+	}
+	buf := elr._1kb[:at+6]
+	return isPlausibleElement(buf, elr.peekAvailable(buf), at, elr.br.GetByteOrder())
+}
+
+// isPlausibleElement returns whether an element (or item) could begin at position `at` of the
+// `n` bytes of `buf` which are available, or whether the input ends there.
+func isPlausibleElement(buf []byte, n int, at int, bo binary.ByteOrder) bool {
+	switch {
+	case n == at:
+		return true
+	case n < at+6:
+		return false
+	}
+	return bo.Uint16(buf[at:]) == 0xFFFE || isRecognisedVR(string(buf[at+4:at+6]))
+}
+
 // tagFromBytes parses a dicom tag from a block of four bytes.
 // If "src" is not of length four, an error will be returned.
+// The group and element are each decoded as 16-bit words in the reader's byte order,
+// which also holds for item and delimitation tags (FFFE,xxxx).
 func (elr *ElementReader) tagFromBytes(src []byte, dst *uint32) error {
 	if len(src) != 4 {
 		return errors.New("tagFromBytes requires four bytes")
@@ -810,6 +2575,77 @@ func (elr *ElementReader) hasReachedTag(tag uint32) (bool, error) {
 	return (elr.ui32 == tag), nil
 }
 
+// resyncWindow is the greatest number of stray bytes which will be skipped in order to find an
+// expected item or delimitation tag. It is smaller than any element or item header, such that
+// a tag found within the window cannot belong to a well-formed element.
+const resyncWindow = 7
+
+// resync looks for any of `tags` within `resyncWindow` bytes of the reader's position and, if
+// found, discards the stray bytes which precede it. Delimitation tags must be followed by a zero
+// length. This is only attempted in `RepairMode`, and never in `StrictMode`.
+// Its return value (bool) indicates whether bytes were discarded.
+func (elr *ElementReader) resync(tags ...uint32) (bool, error) {
+	if !config.RepairMode || config.StrictMode {
+		return false, nil
+	}
+	buf := elr._1kb[:resyncWindow+8]
+	available := elr.peekAvailable(buf)
+	for n := 1; n <= resyncWindow && n+8 <= available; n++ {
+		tag := uint32(0)
+		elr.tagFromBytes(buf[n:n+4], &tag)
+		for _, expected := range tags {
+			if tag != expected || (tag != itemTag && binary.LittleEndian.Uint32(buf[n+4:n+8]) != 0) {
+				continue
+			}
+			elr.addWarning("skipped %d unexpected bytes at offset %d, preceding (%04X,%04X)", n, elr.br.GetPosition(), tag>>16, tag&0xFFFF)
+			return true, elr.br.Discard(int64(n))
+		}
+	}
+	return false, nil
+}
+
+// peekAvailable peeks up to len(`dst`) bytes into `dst`, returning the number peeked. Should the
+// length of the input be known, what remains of it is peeked at once. Otherwise, bytes are peeked
+// one at a time, as a peek beyond the end of the input discards the bytes which it did read.
+func (elr *ElementReader) peekAvailable(dst []byte) int {
+	if elr.inputLength > 0 {
+		if remaining := elr.inputLength - elr.br.GetPosition(); remaining < int64(len(dst)) {
+			if remaining < 0 {
+				remaining = 0
+			}
+			dst = dst[:remaining]
+		}
+		if elr.br.Peek(dst) != nil {
+			return 0
+		}
+		return len(dst)
+	}
+	available := 0
+	for available < len(dst) && elr.br.Peek(dst[:available+1]) == nil {
+		available++
+	}
+	return available
+}
+
+// hasEmbeddedPreamble returns whether the reader is positioned at the "DICM" magic of another
+// dicom, or at a zeroed preamble followed by the magic, as results from concatenating files.
+// The tag of the next element is peeked in a single call; should fewer than four bytes remain,
+// there is no next element, and the input is treated as having ended.
+func (elr *ElementReader) hasEmbeddedPreamble() bool {
+	buf := elr._1kb[:132]
+	if elr.br.Peek(buf[:4]) != nil {
+		return false
+	}
+	if bytes.Equal(buf[:4], dicmTestString) {
+		return true
+	}
+	// only zeroed preambles are detected, as no element of a data set has tag (0000,0000)
+	if binary.LittleEndian.Uint32(buf[:4]) != 0 {
+		return false
+	}
+	return elr.peekAvailable(buf) == len(buf) && bytes.Equal(buf[128:132], dicmTestString)
+}
+
 // readItemUndefLength attempts to read the "data" component of an item that is of
 // "undefined length" from the reader.
 // "readEmbeddedElements" specifies whether the method should parse embedded datas as "elements",
@@ -826,6 +2662,12 @@ func (elr *ElementReader) readItemUndefLength(readEmbeddedElements bool, dst *It
 			break
 		}
 		if readEmbeddedElements {
+			// stray bytes may precede the item delimitation tag
+			if elr._bool, elr.err = elr.resync(itemDelimTag); elr.err != nil {
+				return elr.err
+			} else if elr._bool {
+				continue
+			}
 			// initialise empty element
 			e := NewElement()
 			if !elr.IsLittleEndian() {
@@ -835,8 +2677,10 @@ func (elr *ElementReader) readItemUndefLength(readEmbeddedElements bool, dst *It
 			if elr.err = elr.ReadElement(&e); elr.err != nil {
 				return elr.err
 			}
-			// add element to item.dataset
-			dst.dataset.addElement(e)
+			// add element to item.dataset, unless its value was skipped
+			if !e.skipped {
+				dst.dataset.addElement(e)
+			}
 			continue
 		}
 		// we are not reading embedded elemebts, instead extend "fragment" by four bytes
@@ -854,8 +2698,11 @@ func (elr *ElementReader) readItemUndefLength(readEmbeddedElements bool, dst *It
 // readItem attempts to read an item from the reader.
 // "readEmbeddedElements" specifies whether the method should parse embedded datas as "elements",
 // or "data fragments" (i.e. as would be the case with PixelData).
+// "deferElements" specifies whether the parsing of embedded elements should be deferred (see `DeferItems`),
+// which is only possible for items of defined length.
 // This method handles both undefined length and defined length items.
-func (elr *ElementReader) readItem(readEmbeddedElements bool, dst *Item) error {
+func (elr *ElementReader) readItem(readEmbeddedElements bool, deferElements bool, dst *Item) error {
+	deferElements = deferElements && readEmbeddedElements
 	// read item-tag
 	if elr.err = elr.readTag(&elr.ui32); elr.err != nil {
 		return elr.err
@@ -871,15 +2718,23 @@ func (elr *ElementReader) readItem(readEmbeddedElements bool, dst *Item) error {
 	if elr.err = elr.br.ReadUint32(&elr.ui32); elr.err != nil {
 		return elr.err
 	}
+	startPos := elr.br.GetPosition()
+	if !elr.inflated {
+		dst.offset = startPos
+	}
 	// is item of undef. length?
 	if elr.ui32 == 0xFFFFFFFF {
 		// yes:
 		// read_item_undefined_length(input)
+		// its extent is only known by parsing it, so parsing cannot be deferred
 		if elr.err = elr.readItemUndefLength(readEmbeddedElements, dst); elr.err != nil {
 			return elr.err
 		}
+		// exclude the item delimitation tag
+		dst.length = elr.br.GetPosition() - startPos - 8
 		return nil
 	}
+	dst.length = int64(elr.ui32)
 
 	if elr.ui32 == 0 {
 		return nil
@@ -891,6 +2746,15 @@ func (elr *ElementReader) readItem(readEmbeddedElements bool, dst *Item) error {
 		*/
 	}
 
+	// retain the encoded elements, to be parsed on request
+	if deferElements {
+		if dst.raw, elr.err = elr.readValue(int64(elr.ui32)); elr.err != nil {
+			return elr.err
+		}
+		dst.deferred = true
+		return nil
+	}
+
 	// if "read_elements":
 	if readEmbeddedElements {
 		// end_pos = cur_pos + item.length
@@ -906,23 +2770,62 @@ func (elr *ElementReader) readItem(readEmbeddedElements bool, dst *Item) error {
 			if elr.err = elr.ReadElement(&e); elr.err != nil {
 				return elr.err
 			}
-			// 	add element to "dest".dataset
-			dst.dataset.addElement(e)
+			// 	add element to "dest".dataset, unless its value was skipped
+			if !e.skipped {
+				dst.dataset.addElement(e)
+			}
 			// 	continue
 		}
 		return nil
 	}
 
 	// # not reading elements - read bytes and store
-	// initialise "dest".fragment to length of element
-	dst.fragment = make([]byte, elr.ui32)
+	if (elr.lazy || elr.compact) && elr.ui32 > lazyValueThreshold {
+		return elr.br.Discard(int64(elr.ui32))
+	}
 	// "dest".fragment <- read len X bytes
-	return elr.br.ReadBytes(dst.fragment)
+	dst.fragment, elr.err = elr.readValue(int64(elr.ui32))
+	return elr.err
+}
+
+// shouldDeferItems returns whether parsing of the elements within the items of sequence `e`
+// is to be deferred, as requested by `DeferItems`.
+func shouldDeferItems(e Element) bool {
+	return config.DeferItems != nil && shouldReadEmbeddedElements(e) && config.DeferItems(e.GetTag())
+}
+
+// canHaveUndefinedLength returns whether elements of `vr` may declare undefined length.
+// Aside from encapsulated PixelData, only sequences may; UN may also, as it can hold a sequence.
+func canHaveUndefinedLength(vr string) bool {
+	return vr == "SQ" || vr == "UN"
+}
+
+// recoverUndefLength handles an element which declares undefined length despite its VR not
+// permitting it. In `StrictMode`, this is rejected. Otherwise, if items follow, the element
+// is read as a sequence, as it was most likely mislabelled; if not, it is treated as empty.
+func (elr *ElementReader) recoverUndefLength(dst *Element) error {
+	msg := fmt.Sprintf("%s has undefined length, which is not permitted for VR %s", dst.dictEntry, dst.GetVR())
+	if config.StrictMode {
+		return CorruptElement{errors.New(msg)}
+	}
+	for _, tag := range []uint32{itemTag, seqDelimTag} {
+		if elr._bool, elr.err = elr.hasReachedTag(tag); elr.err != nil {
+			return elr.err
+		}
+		if elr._bool {
+			elr.addWarning("%s; reading as a sequence", msg)
+			return elr.readElementDataUndefLength(dst)
+		}
+	}
+	elr.addWarning("%s; treating as empty", msg)
+	dst.datalen = 0
+	return nil
 }
 
 // readElementDataUndefLength attempts to read the "data" component of
 // an element that is of "undefined length" from the reader.
 func (elr *ElementReader) readElementDataUndefLength(dst *Element) error {
+	deferItems := shouldDeferItems(*dst)
 	// for
 	for {
 		// if has_reached_tag(SeqDelimTag), break.
@@ -932,10 +2835,23 @@ func (elr *ElementReader) readElementDataUndefLength(dst *Element) error {
 		if elr._bool {
 			break
 		}
+		// stray bytes may precede the next item or the sequence delimitation tag
+		if elr._bool, elr.err = elr.hasReachedTag(itemTag); elr.err != nil {
+			return elr.err
+		} else if !elr._bool {
+			if elr._bool, elr.err = elr.resync(itemTag, seqDelimTag); elr.err != nil {
+				return elr.err
+			} else if elr._bool {
+				continue
+			}
+		}
 		// initialise empty_item
 		item := NewItem()
 		// read_item(should_read_embedded_elements("dest"), empty_item)
-		elr.readItem(shouldReadEmbeddedElements(*dst), &item)
+		// errors are tolerated, unless validating framing during a lazy parse
+		if elr.err = elr.readItem(shouldReadEmbeddedElements(*dst), deferItems, &item); elr.err != nil && elr.lazy {
+			return elr.err
+		}
 		// add empty_item to "dest".items
 		dst.items = append(dst.items, item)
 	}
@@ -946,6 +2862,40 @@ func (elr *ElementReader) readElementDataUndefLength(dst *Element) error {
 	return nil
 }
 
+// maxValueChunk is the largest allocation made ahead of reading a value; larger values
+// are read in chunks of increasing size.
+const maxValueChunk = 1 << 20
+
+// readValue reads a value of `n` bytes. As `n` is read from the input, which may be corrupt
+// or malicious, memory is only allocated as the value is read, such that a bogus length
+// results in an error rather than the allocation of up to 4GB. Should the length of the input
+// be known, values which lie within it are allocated in full.
+func (elr *ElementReader) readValue(n int64) ([]byte, error) {
+	if n <= maxValueChunk || n <= elr.inputLength-elr.br.GetPosition() {
+		data := make([]byte, n)
+		return data, elr.br.ReadBytes(data)
+	}
+	data := make([]byte, 0, maxValueChunk)
+	for int64(len(data)) < n {
+		if len(data) == cap(data) {
+			size := 2 * int64(cap(data))
+			if size > n {
+				size = n
+			}
+			grown := make([]byte, len(data), size)
+			copy(grown, data)
+			data = grown
+		}
+		// the capacity never exceeds `n`, so the remainder of it is to be read
+		chunk := data[len(data):cap(data)]
+		if err := elr.br.ReadBytes(chunk); err != nil {
+			return nil, err
+		}
+		data = data[:cap(data)]
+	}
+	return data, nil
+}
+
 // readElementData attempts to read/decode the "Data" component of an Element
 // into `dst`.
 // In the event that the length is 0xFFFFFFFF (undefined), embedded contents will
@@ -967,12 +2917,13 @@ func (elr *ElementReader) readElementData(dst *Element) error {
 	}
 	// is "dest" instead a SQ with defined length?
 	if dst.GetVR() == "SQ" {
+		deferItems := shouldDeferItems(*dst)
 		endPos := elr.br.GetPosition() + int64(dst.datalen)
 		for elr.br.GetPosition() < endPos {
 			// initialise empty_item
 			item := NewItem()
 			// read_item(should_read_embedded_elements("dest"), empty_item)
-			if elr.err = elr.readItem(shouldReadEmbeddedElements(*dst), &item); elr.err != nil {
+			if elr.err = elr.readItem(shouldReadEmbeddedElements(*dst), deferItems, &item); elr.err != nil {
 				return elr.err
 			}
 			// add empty_item to "dest".items
@@ -981,26 +2932,51 @@ func (elr *ElementReader) readElementData(dst *Element) error {
 		return nil
 	}
 	// otherwise, its "defined length, non-SQ", read as arbitrary bytes
-	// initialise dest to length of element
-	dst.data = make([]byte, dst.datalen)
-
+	if elr.shouldSkipValue(dst) {
+		return elr.skipValue(dst)
+	}
 	// "dest" <- read len X bytes
-	if elr.err = elr.br.ReadBytes(dst.data); elr.err != nil {
+	if dst.data, elr.err = elr.readValue(int64(dst.datalen)); elr.err != nil {
 		return elr.err
 	}
+	return elr.onValueRead(dst)
+}
 
-	padchars := []byte{0x00, 0x20}
-	switch dst.GetVR() {
-	case "UI", "OB", "CS", "DS", "IS", "AE", "AS", "DA", "DT", "LO", "LT", "OD", "OF", "OW", "PN", "SH", "ST", "TM", "UT":
-		for _, chr := range padchars {
-			if dst.data[len(dst.data)-1] == chr {
-				dst.data = dst.data[:len(dst.data)-1]
-				dst.datalen--
-			} else if dst.data[0] == chr { // NOTE: assumes padding will only take place on one side. Should be fine.
-				dst.data = dst.data[1:]
-				dst.datalen--
-			}
+// onValueRead checks and tidies the value just read into `dst`: odd lengths are reported (and
+// repaired, in `RepairMode`), padding is stripped, and UIDs are validated if so configured.
+func (elr *ElementReader) onValueRead(dst *Element) error {
+	// values should always be of even length, but some writers get this wrong
+	oddLength := dst.datalen%2 != 0
+	if oddLength {
+		elr.addWarning("%s has odd length %d at offset %d", dst.dictEntry, dst.datalen, elr.br.GetPosition()-int64(dst.datalen))
+	}
+
+	stripPadding(dst)
+
+	// the repair follows the stripping of padding, which would otherwise remove it again.
+	// as for `Dicom.Normalize`, the padding remains part of the value
+	if oddLength && config.RepairMode && len(dst.data)%2 != 0 {
+		dst.data = append(append(make([]byte, 0, len(dst.data)+1), dst.data...), paddingForVR(dst.GetVR()))
+		dst.datalen = uint32(len(dst.data))
+	}
+
+	if config.ValidateUIDs && dst.GetVR() == "UI" {
+		return elr.validateUIDs(dst)
+	}
+	return nil
+}
+
+// validateUIDs checks that each value of UI element `e` contains only the permitted characters.
+// In strict mode, a violation results in a `CorruptElement` error; otherwise a warning is recorded.
+func (elr *ElementReader) validateUIDs(e *Element) error {
+	for _, uid := range splitCharacterStringVM(e.data) {
+		if IsValidUID(string(uid)) {
+			continue
 		}
+		if config.StrictMode {
+			return CorruptElement{fmt.Errorf("%s contains invalid UID %q", e.dictEntry, uid)}
+		}
+		elr.addWarning("%s contains invalid UID %q", e.dictEntry, uid)
 	}
 	return nil
 }
@@ -1012,37 +2988,81 @@ func (elr *ElementReader) readPixelData(dst *Element) error {
 	Errorf("PixelData VR: %s", dst.GetVR())
 	Errorf("PixelData Length: %X", dst.datalen)
 	if dst.datalen == 0xFFFFFFFF {
-		elr.readElementDataUndefLength(dst)
+		return elr.readElementDataUndefLength(dst)
 	}
-	return nil
+	if elr.shouldSkipValue(dst) {
+		return elr.skipValue(dst)
+	}
+	// native (unencapsulated) pixel data is read as-is; it is not subject to padding removal
+	dst.data, elr.err = elr.readValue(int64(dst.datalen))
+	return elr.err
 }
 
 // ReadElement attempts to completely read an element into `dst`.
 //
 // All types of elements are expected to be compatible.
 func (elr *ElementReader) ReadElement(dst *Element) error {
+	// only the element read immediately before this one may be re-read
+	empty := elr.empty
+	elr.empty = emptyElement{}
+	start := elr.br.GetPosition()
 	// read tag
 	if elr.err = elr.readTag(&elr.ui32); elr.err != nil {
 		return elr.err
 	}
+	tag := elr.ui32
 	// set element.dictentry to an entry in dictionary
-	dst.dictEntry, elr._bool = lookupTag(elr.ui32)
+	dst.dictEntry, elr._bool = lookupTag(tag)
+	dst.isLittleEndian = elr.IsLittleEndian()
+	dst.implicitVR = elr.IsImplicitVR()
 
 	// read vr
 	if elr.err = elr.readElementVR(dst); elr.err != nil {
 		return elr.err
 	}
+	sourceVR := elr.sourceVR
+	if !elr.IsImplicitVR() && !isRecognisedVR(sourceVR) {
+		// the standard reading of the preceding element may have failed, rather than this one
+		if empty.dictEntry != nil && empty.end == start {
+			if reread, err := elr.rereadEmptyElement(empty, tag, dst); reread {
+				return err
+			}
+		}
+		if elr.lazy {
+			return CorruptElement{fmt.Errorf("%s has unrecognised VR %q", dst.dictEntry, sourceVR)}
+		}
+	}
 
 	// read length
 	if elr.err = elr.readElementLength(dst); elr.err != nil {
 		return elr.err
 	}
+	dst.valueLength = dst.datalen
+
+	if config.SkipElement != nil {
+		vr := sourceVR
+		if vr == "" {
+			vr = dst.GetVR()
+		}
+		if config.SkipElement(dst.GetTag(), vr, dst.datalen) {
+			dst.skipped = true
+			if dst.datalen != 0xFFFFFFFF {
+				return elr.br.Discard(int64(dst.datalen))
+			}
+			// a value of undefined length can only be skipped by reading it
+			defer func() { dst.data, dst.items = nil, nil }()
+		}
+	}
 
 	// handle PixelData
 	if dst.GetTag() == pixelDataTag {
 		return elr.readPixelData(dst)
 	}
 
+	if dst.datalen == 0xFFFFFFFF && !canHaveUndefinedLength(dst.GetVR()) && !canHaveUndefinedLength(sourceVR) {
+		return elr.recoverUndefLength(dst)
+	}
+
 	// read contents
 	return elr.readElementData(dst)
 }
@@ -1057,6 +3077,27 @@ func (elr *ElementReader) readTag(dst *uint32) error {
 	return elr.tagFromBytes(elr._1kb[:4], dst)
 }
 
+// internVR returns `vr` as one of `RecognisedVRs`, such that reading the VR of each element
+// does not allocate a string. Unrecognised VRs are copied.
+func internVR(vr []byte) string {
+	for _, recognised := range RecognisedVRs {
+		if string(vr) == recognised {
+			return recognised
+		}
+	}
+	return string(vr)
+}
+
+// isRecognisedVR returns whether `vr` is listed in `RecognisedVRs`.
+func isRecognisedVR(vr string) bool {
+	for _, recognised := range RecognisedVRs {
+		if vr == recognised {
+			return true
+		}
+	}
+	return false
+}
+
 // determineEncoding attempts to determine the current encoding
 // (Implicit/Explicit VR, Big/Little Endian)
 // `buf` should be of length six.
@@ -1073,19 +3114,8 @@ func (elr *ElementReader) determineEncoding(buf []byte) error {
 	elr.SetLittleEndian(elr.ui16 < 2000 || elr.ui16 == 0x7FE0)
 
 	// to determine implicit / explicit VR, check the next two
-	// bytes against known VRs
-	vrfrombytes := string(buf[4:6])
-	elr.SetImplicitVR(true)
-	elr._bool = true
-	for _, vr := range RecognisedVRs {
-		if vr == vrfrombytes {
-			// VR found in `buf` matches a known VR -- is likely explicit
-			elr._bool = false
-			break
-		}
-	}
-	// encoding should have been determined by this stage
-	elr.SetImplicitVR(elr._bool)
+	// bytes against known VRs. if they match a known VR, the file is likely explicit
+	elr.SetImplicitVR(!isRecognisedVR(string(buf[4:6])))
 	//Debugf("Determined Encoding: ImplicitVR: %v, LittleEndian: %v", elr.IsImplicitVR(), elr.IsLittleEndian())
 	return nil
 }
@@ -2,13 +2,23 @@ package opendcm
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 
 	"github.com/b71729/opendcm/dictionary"
 
@@ -186,6 +196,53 @@ func TestGetElementValue(t *testing.T) {
 	assert.False(t, found)
 }
 
+func TestGetElementByName(t *testing.T) {
+	// ensures that `GetElementByName` resolves dictionary
+	// names to the contained elements.
+	t.Parallel()
+	ds := make(DataSet, 0)
+	ds.addElement(NewElementWithTag(0x00100010))
+	e := Element{}
+	assert.True(t, ds.GetElementByName("PatientName", &e))
+	assert.Equal(t, uint32(0x00100010), e.GetTag())
+
+	// recognised, but not in the dataset
+	assert.False(t, ds.GetElementByName("PatientID", &e))
+	// not recognised
+	assert.False(t, ds.GetElementByName("NotAnElement", &e))
+}
+
+func TestGetReferencedTags(t *testing.T) {
+	// ensures that AT values are decoded as (group, element) pairs
+	// in both byte orders, and resolved to their dictionary names.
+	t.Parallel()
+	ds := make(DataSet, 0)
+	e := NewElementWithTag(0x00280009) // FrameIncrementPointer
+	e.data = []byte{0x18, 0x00, 0x63, 0x10, 0x18, 0x00, 0x65, 0x10}
+	ds.addElement(e)
+	tags, err := ds.GetReferencedTags(0x00280009)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0x00181063, 0x00181065}, tags)
+	names, err := ds.GetReferencedTagNames(0x00280009)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"FrameTime", "FrameTimeVector"}, names)
+
+	e.data = []byte{0x00, 0x18, 0x10, 0x63}
+	e.isLittleEndian = false
+	ds.addElement(e)
+	tags, err = ds.GetReferencedTags(0x00280009)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0x00181063}, tags)
+
+	// not present
+	_, err = ds.GetReferencedTags(0x00209165)
+	assert.Error(t, err)
+	// not AT
+	ds.addElement(NewElementWithTag(0x00100010))
+	_, err = ds.GetReferencedTagNames(0x00100010)
+	assert.Error(t, err)
+}
+
 func TestAddElement(t *testing.T) {
 	// ensures that `addElement` does not panic.
 	t.Parallel()
@@ -226,6 +283,30 @@ func TestGetCharacterSet(t *testing.T) {
 	e.data = []byte("ISO_IR 192")
 	ds.addElement(e)
 	assert.Equal(t, "ISO_IR 192", ds.GetCharacterSet().Name)
+
+	// present, but empty
+	ds.addElement(NewElementWithTag(0x00080005))
+	assert.Equal(t, "Default", ds.GetCharacterSet().Name)
+	input := append(make([]byte, 128), "DICM"...)
+	input = append(input, 0x08, 0x00, 0x05, 0x00, 'C', 'S', 0x00, 0x00)
+	input = append(input, 0x10, 0x00, 0x10, 0x00, 'P', 'N', 0x04, 0x00, 'D', 'O', 'E', '^')
+	dcm, err := FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, "Default", dcm.GetCharacterSet().Name)
+}
+
+func TestCharacterSetMapNames(t *testing.T) {
+	// ensures that each character set is named as per the key by which it is found,
+	// such that `GetCharacterSet().Name` reports the character set actually in use.
+	t.Parallel()
+	for name, cs := range CharacterSetMap {
+		assert.Equal(t, name, cs.Name)
+		assert.NotNil(t, cs.Encoding, name)
+	}
+	for _, name := range []string{"ISO_IR 126", "ISO_IR 127"} {
+		ds := newDataSet(newStringElement(0x00080005, name))
+		assert.Equal(t, name, ds.GetCharacterSet().Name)
+	}
 }
 
 func TestSplitCharacterStringVM(t *testing.T) {
@@ -269,6 +350,131 @@ func TestElementGetters(t *testing.T) {
 	assert.Equal(t, int(e.datalen), e.Len())
 }
 
+func TestValueLength(t *testing.T) {
+	// ensures that the declared value length is retained once padding has been stripped.
+	t.Parallel()
+	buf := []byte{0x08, 0x00, 0x60, 0x00, 'C', 'S', 0x04, 0x00, 'C', 'T', ' ', ' '}
+	e, err := ParseElement(buf, ExplicitVRLittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(4), e.ValueLength())
+	assert.Equal(t, 3, e.Len())
+	assert.Equal(t, []byte("CT "), e.data)
+
+	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "UndefinedLengthNonSQ.dcm"))
+	assert.NoError(t, err)
+	assert.True(t, dcm.GetElement(0x00280010, &e))
+	assert.Equal(t, uint32(0xFFFFFFFF), e.ValueLength())
+	assert.Equal(t, 0, e.Len())
+
+	e = NewElementWithTag(0x00080060)
+	assert.Equal(t, uint32(0), e.ValueLength())
+}
+
+func TestSupportsMultiVM(t *testing.T) {
+	t.Parallel()
+	for tag, expected := range map[uint32]bool{
+		0x00080005: true,  // CS
+		0x00280030: true,  // DS
+		0x00280010: true,  // US
+		0x00081030: true,  // LO
+		0x00204000: false, // LT
+		0x7FE00010: false, // OB
+		0x00081140: false, // SQ
+	} {
+		e := NewElementWithTag(tag)
+		assert.Equal(t, expected, e.SupportsMultiVM(), e.GetName())
+	}
+}
+
+func TestConformsToVRLength(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		e        Element
+		expected bool
+	}{
+		{newStringElement(0x00080060, "CT"), true},                                      // CS
+		{newStringElement(0x00080060, "SEVENTEEN_BYTES_X"), false},                      // CS
+		{newStringElement(0x00080008, `ORIGINAL\PRIMARY\SIXTEEN_BYTES_X`), true},        // CS, per value
+		{newStringElement(0x00280030, `0.12345678901234\0.5`), true},                    // DS
+		{newStringElement(0x00280030, `0.123456789012345\0.5`), false},                  // DS
+		{newStringElement(0x00100030, "19700101"), true},                                // DA
+		{newStringElement(0x00100030, "1970011"), false},                                // DA
+		{newStringElement(0x00100030, ""), true},                                        // DA
+		{newStringElement(0x00100010, strings.Repeat("\u00e9", 64)), true},              // PN, in characters
+		{newStringElement(0x00100010, strings.Repeat("A", 64)+"="+"B"), true},           // PN, per group
+		{newStringElement(0x00100010, strings.Repeat("A", 65)), false},                  // PN
+		{newUSElement(0x00280010, 512), true},                                           // US
+		{newStringElement(0x00280010, "abc"), false},                                    // US
+		{newStringElement(0x7FE00010, strings.Repeat("A", 100)), true},                  // OB
+		{newSequence(0x00081140, newDataSet(newStringElement(0x00080060, "CT"))), true}, // SQ
+	} {
+		assert.Equal(t, tc.expected, tc.e.ConformsToVRLength(), "%s %q", tc.e.GetName(), tc.e.data)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+	e := newStringElement(0x00080008, `ORIGINAL\PRIMARY`)
+	assert.Equal(t, `(0008,0008): ImageType [CS] ORIGINAL\PRIMARY`, e.Describe())
+	e = newUSElement(0x00280010, 512)
+	assert.Equal(t, "(0028,0010): Rows [US] 512", e.Describe())
+	assert.Equal(t, "512", e.DescribeValue())
+	e = newSequence(0x00081140, newDataSet(), newDataSet())
+	assert.Equal(t, "(0008,1140): ReferencedImageSequence [SQ] [2 items]", e.Describe())
+	e = NewElementWithTag(0x7FE00010)
+	e.data = make([]byte, 16)
+	assert.Equal(t, "(7FE0,0010): PixelData [OB] [16 bytes]", e.Describe())
+}
+
+func TestDescribeJSON(t *testing.T) {
+	// ensures that the tree preserves the nesting of sequences and their items.
+	t.Parallel()
+	dcm := newDicom()
+	dcm.addElement(newUSElement(0x00280010, 512))
+	dcm.addElement(newStringElement(0x00080008, `ORIGINAL\PRIMARY`))
+	dcm.addElement(newSequence(0x00081140, newDataSet(newStringElement(0x00081155, "1.2.3.4")), newDataSet()))
+	dcm.addElement(newSequence(0x00082112))
+	out, err := dcm.DescribeJSON()
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "\n  {\n    \"tag\"")
+
+	var tree []describedElement
+	assert.NoError(t, json.Unmarshal(out, &tree))
+	if assert.Len(t, tree, 4) {
+		assert.Equal(t, describedElement{Tag: "(0008,0008)", Name: "ImageType", VR: "CS", Value: `ORIGINAL\PRIMARY`}, tree[0])
+		assert.Equal(t, "(0008,1140)", tree[1].Tag)
+		assert.Empty(t, tree[1].Value)
+		if assert.Len(t, tree[1].Items, 2) {
+			assert.Equal(t, []describedElement{{Tag: "(0008,1155)", Name: "ReferencedSOPInstanceUID", VR: "UI", Value: "1.2.3.4"}}, tree[1].Items[0])
+			assert.Empty(t, tree[1].Items[1])
+		}
+		assert.Equal(t, "SourceImageSequence", tree[2].Name)
+		assert.Empty(t, tree[2].Items)
+		assert.Equal(t, "512", tree[3].Value)
+	}
+}
+
+func TestTagCategory(t *testing.T) {
+	t.Parallel()
+	for tag, category := range map[uint32]string{
+		0x00000000: "Command",
+		0x00000100: "Command",
+		0x00020000: "FileMeta",
+		0x00020010: "FileMeta",
+		0x00090000: "Private",
+		0x00090010: "Private",
+		0x00291010: "Private",
+		0x00080000: "Group Length",
+		0x7FE00000: "Group Length",
+		0x00080005: "Standard",
+		0x00100010: "Standard",
+		0x7FE00010: "Standard",
+		0xFFFEE000: "Standard",
+	} {
+		assert.Equal(t, category, TagCategory(tag), "%08X", tag)
+	}
+}
+
 func TestSupportsType(t *testing.T) {
 	// ensures that `supportsType` correctly identifies which
 	// types are supported for the various VRs.
@@ -281,6 +487,9 @@ func TestSupportsType(t *testing.T) {
 		"SL": int32(0),
 		"US": uint16(0),
 		"UL": uint32(0),
+		"SV": int64(0),
+		"UV": uint64(0),
+		"OV": uint64(0),
 	} {
 		e := NewElement()
 		e.dictEntry.VR = vr
@@ -336,6 +545,19 @@ func TestGetValue(t *testing.T) {
 				assert.NoError(t, e.GetValue(&dst))
 				dst2 := []int32{}
 				assert.NoError(t, e.GetValue(&dst2))
+			case "SV":
+				e.data = make([]byte, 8)
+				dst := int64(0)
+				assert.NoError(t, e.GetValue(&dst))
+				dst2 := []int64{}
+				assert.NoError(t, e.GetValue(&dst2))
+			case "UV", "OV":
+				e.data = make([]byte, 16)
+				dst := uint64(0)
+				assert.NoError(t, e.GetValue(&dst))
+				dst2 := []uint64{}
+				assert.NoError(t, e.GetValue(&dst2))
+				assert.Len(t, dst2, 2)
 			case "UN":
 				e.data = make([]byte, 4)
 				dst := make([]byte, 4)
@@ -346,6 +568,25 @@ func TestGetValue(t *testing.T) {
 	}
 }
 
+func TestGetValueMultiValuedUID(t *testing.T) {
+	// ensures that multi-valued UIDs are split, and that no value retains a NULL pad.
+	t.Parallel()
+	buf := []byte{0x08, 0x00, 0x1A, 0x00, 'U', 'I', 0x0C, 0x00}
+	buf = append(buf, "1.2.3\\1.2.4\x00"...)
+	e, err := ParseElement(buf, ExplicitVRLittleEndian)
+	assert.NoError(t, err)
+	assert.True(t, e.SupportsMultiVM())
+	uids := []string{}
+	assert.NoError(t, e.GetValue(&uids))
+	assert.Equal(t, []string{"1.2.3", "1.2.4"}, uids)
+
+	// each value padded
+	e = newStringElement(0x0008001A, "1.2.3\x00\\1.2.4\x00")
+	uids = []string{}
+	assert.NoError(t, e.GetValue(&uids))
+	assert.Equal(t, []string{"1.2.3", "1.2.4"}, uids)
+}
+
 func TestGetValueError(t *testing.T) {
 	// ensures that the error condition of `GetValue`
 	// responds correctly.
@@ -360,6 +601,58 @@ func TestGetValueError(t *testing.T) {
 	assert.Error(t, e.GetValue(int32(0)))
 	// returns error if writing to destination is unimplemented
 	assert.Error(t, e.GetValue(struct{}{}))
+
+	// returns ErrValueTooShort if the value is truncated
+	e.data = []byte{0x01}
+	for _, dst := range []interface{}{
+		new(float32), new(float64), new(int16), new(int32), new(uint16), new(uint32), new(int64), new(uint64),
+	} {
+		assert.Equal(t, ErrValueTooShort, e.GetValue(dst))
+	}
+}
+
+func TestStringValueNoAlloc(t *testing.T) {
+	// ensures that the value of textual elements is returned unsplit.
+	// that it does not allocate is shown by BenchmarkStringValueNoAlloc.
+	t.Parallel()
+	e := newStringElement(0x00080060, "CT")
+	val, ok := e.StringValueNoAlloc()
+	assert.True(t, ok)
+	assert.Equal(t, "CT", val)
+	e = newStringElement(0x00080008, `ORIGINAL\PRIMARY`)
+	val, ok = e.StringValueNoAlloc()
+	assert.True(t, ok)
+	assert.Equal(t, `ORIGINAL\PRIMARY`, val)
+
+	// empty and non-textual
+	e = newStringElement(0x00080060, "")
+	val, ok = e.StringValueNoAlloc()
+	assert.True(t, ok)
+	assert.Equal(t, "", val)
+	e = newUSElement(0x00280010, 512)
+	_, ok = e.StringValueNoAlloc()
+	assert.False(t, ok)
+}
+
+func BenchmarkGetValueString(b *testing.B) {
+	e := newStringElement(0x00080060, "MR")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		modality := make([]string, 0)
+		if err := e.GetValue(&modality); err != nil || modality[0] != "MR" {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStringValueNoAlloc(b *testing.B) {
+	e := newStringElement(0x00080060, "MR")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if modality, ok := e.StringValueNoAlloc(); !ok || modality != "MR" {
+			b.Fatal(modality)
+		}
+	}
 }
 
 func TestNewElement(t *testing.T) {
@@ -380,6 +673,39 @@ func TestNewElementWithTag(t *testing.T) {
 	assert.Equal(t, "SpecificCharacterSet", e.GetName())
 }
 
+func TestKeyword(t *testing.T) {
+	// ensures that `Keyword` distinguishes dictionary keywords from synthesised names.
+	t.Parallel()
+	e := NewElementWithTag(0x00100010)
+	keyword, found := e.Keyword()
+	assert.True(t, found)
+	assert.Equal(t, "PatientName", keyword)
+	e = NewElementWithTag(0x00090010)
+	keyword, found = e.Keyword()
+	assert.False(t, found)
+	assert.Equal(t, "Unknown(0009,0010)", keyword)
+}
+
+func TestCollectItemValues(t *testing.T) {
+	// ensures that the value of a sub-element is collected from each item, in order.
+	t.Parallel()
+	seq := newSequence(referencedImageSequenceTag,
+		newDataSet(newStringElement(referencedSOPInstanceUIDTag, "1.2.3"), newStringElement(referencedFrameNumberTag, `1\2`)),
+		newDataSet(newStringElement(referencedSOPInstanceUIDTag, "1.2.4"), newStringElement(referencedFrameNumberTag, "3")),
+	)
+	uids := []string{}
+	assert.NoError(t, seq.CollectItemValues(referencedSOPInstanceUIDTag, &uids))
+	assert.Equal(t, []string{"1.2.3", "1.2.4"}, uids)
+	frames := [][]string{}
+	assert.NoError(t, seq.CollectItemValues(referencedFrameNumberTag, &frames))
+	assert.Equal(t, [][]string{{"1", "2"}, {"3"}}, frames)
+
+	// a destination which is not a pointer to a slice, a type not supported by the VR, and an absent element
+	assert.Error(t, seq.CollectItemValues(referencedSOPInstanceUIDTag, uids))
+	assert.Error(t, seq.CollectItemValues(referencedSOPInstanceUIDTag, &[]uint16{}))
+	assert.Error(t, seq.CollectItemValues(referencedSOPClassUIDTag, &uids))
+}
+
 /*
 ===============================================================================
     ElementReader
@@ -400,6 +726,13 @@ func TestShouldReadEmbeddedElements(t *testing.T) {
 	assert.True(t, shouldReadEmbeddedElements(NewElementWithTag(0xDEADBEEF)))
 }
 
+func TestShouldReadEmbeddedElementsPixelDataAsElements(t *testing.T) {
+	// ensures that PixelData is parsed as elements when configured to.
+	defer func(asElements bool) { config.ParsePixelDataAsElements = asElements }(config.ParsePixelDataAsElements)
+	config.ParsePixelDataAsElements = true
+	assert.True(t, shouldReadEmbeddedElements(NewElementWithTag(pixelDataTag)))
+}
+
 func TestLookupTag(t *testing.T) {
 	// ensure that, for recognised and unrecognised tags,
 	// `lookupTag` will correctly respond.
@@ -413,6 +746,15 @@ func TestLookupTag(t *testing.T) {
 	assert.Equal(t, "PixelData", de.Name)
 }
 
+func TestLookupTagByName(t *testing.T) {
+	t.Parallel()
+	tag, found := LookupTagByName("PixelData")
+	assert.True(t, found)
+	assert.Equal(t, pixelDataTag, tag)
+	_, found = LookupTagByName("NotAnElement")
+	assert.False(t, found)
+}
+
 func TestNewElementReader(t *testing.T) {
 	t.Parallel()
 	src := bin.NewReader(bytes.NewReader(make([]byte, 64)), binary.LittleEndian)
@@ -515,6 +857,31 @@ func TestReadElementLength(t *testing.T) {
 	assert.Equal(t, uint32(0xFFFF), e.datalen)
 }
 
+func TestReadElementSourceVRLength(t *testing.T) {
+	// ensures that, in explicit VR, the size of the length field is decided by the VR read from
+	// the source, rather than that of the dictionary, such that the next element is read intact.
+	t.Parallel()
+	buf := []byte{
+		0x28, 0x00, 0x10, 0x00, // (0028,0010) Rows, US in the dictionary
+		'O', 'W', 0x00, 0x00, // VR: OW, Reserved
+		0x04, 0x00, 0x00, 0x00, // Length: 4 bytes
+		0x01, 0x00, 0x02, 0x00, // Data
+		0x28, 0x00, 0x11, 0x00, // (0028,0011) Columns
+		'U', 'S', 0x02, 0x00, // VR: US, Length: 2 bytes
+		0x03, 0x00, // Data: 3
+	}
+	reader := NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.LittleEndian))
+	reader.SetImplicitVR(false)
+	e := NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Equal(t, "US", e.GetVR())
+	assert.Equal(t, []byte{0x01, 0x00, 0x02, 0x00}, e.data)
+	e = NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Equal(t, uint32(0x00280011), e.GetTag())
+	assert.Equal(t, []byte{0x03, 0x00}, e.data)
+}
+
 func TestReadElementLengthError(t *testing.T) {
 	// ensures that the error condition of
 	// `readElementLength` responds correctly.
@@ -544,6 +911,115 @@ func TestReadElementLengthError(t *testing.T) {
 	assert.Error(t, reader.readElementLength(&e))
 }
 
+func TestReadElementOddLength(t *testing.T) {
+	// ensures that odd-length values are reported as warnings,
+	// and padded to an even length in repair mode.
+	buf := []byte{
+		0x10, 0x00, 0x10, 0x00, // (0010,0010) Tag
+		0x03, 0x00, 0x00, 0x00, // Length: 3 bytes
+		0x4C, 0x65, 0x6F, // Data: "Leo"
+	}
+	reader := NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.LittleEndian))
+	e := NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Len(t, reader.Warnings(), 1)
+	assert.Equal(t, 3, e.Len())
+
+	defer func(repair bool) { config.RepairMode = repair }(config.RepairMode)
+	config.RepairMode = true
+	reader = NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.LittleEndian))
+	e = NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Len(t, reader.Warnings(), 1)
+	// the padding remains part of the value, which is written as read
+	assert.Equal(t, 4, e.Len())
+	assert.Equal(t, []byte("Leo "), e.data)
+	var written bytes.Buffer
+	elw := NewElementWriter(&written)
+	elw.SetTransferSyntax(ImplicitVRLittleEndian)
+	assert.NoError(t, elw.WriteElement(e))
+	assert.Equal(t, append([]byte{0x10, 0x00, 0x10, 0x00, 0x04, 0x00, 0x00, 0x00}, []byte("Leo ")...), written.Bytes())
+}
+
+func TestReadElementBinaryPadding(t *testing.T) {
+	// ensures that binary values beginning or ending with a NULL byte are held as read,
+	// rather than having the byte stripped as if it were padding.
+	t.Parallel()
+	buf := []byte{
+		0x28, 0x00, 0x01, 0x12, // (0028,1201) RedPaletteColorLookupTableData, OW
+		0x04, 0x00, 0x00, 0x00, // Length: 4 bytes
+		0x01, 0x00, 0x02, 0x00, // Data: 1, 2
+		0xE0, 0x7F, 0x08, 0x00, // (7FE0,0008) FloatPixelData, OF
+		0x04, 0x00, 0x00, 0x00, // Length: 4 bytes
+		0x00, 0x00, 0x80, 0x3F, // Data: 1.0
+	}
+	reader := NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.LittleEndian))
+	e := NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Equal(t, "OW", e.GetVR())
+	assert.Equal(t, []byte{0x01, 0x00, 0x02, 0x00}, e.data)
+
+	e = NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Equal(t, "OF", e.GetVR())
+	assert.Equal(t, []byte{0x00, 0x00, 0x80, 0x3F}, e.data)
+}
+
+func TestReadElementLongLengthVRs(t *testing.T) {
+	// ensures that OD, OF, OL and UC, which PS3.5 Table 7.1-1 encodes with two reserved bytes and a
+	// 32-bit length in explicit VR, are read and written as such.
+	t.Parallel()
+	for _, vr := range []string{"OD", "OF", "OL", "UC"} {
+		buf := []byte{
+			0x09, 0x00, 0x01, 0x10, // (0009,1001) private tag
+			vr[0], vr[1], // VR
+			0x00, 0x00, // Reserved
+			0x08, 0x00, 0x00, 0x00, // Length: 8 bytes
+			'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', // Data
+		}
+		e, err := ParseElement(buf, ExplicitVRLittleEndian)
+		assert.NoError(t, err)
+		assert.Equal(t, vr, e.GetVR())
+		assert.Equal(t, 8, e.Len())
+		assert.Equal(t, []byte("ABCDEFGH"), e.data)
+		var written bytes.Buffer
+		elw := NewElementWriter(&written)
+		assert.NoError(t, elw.WriteElement(e))
+		assert.Equal(t, buf, written.Bytes(), vr)
+	}
+}
+
+func TestReadElementInvalidUID(t *testing.T) {
+	// ensures that invalid UIDs are reported as warnings, or
+	// rejected in strict mode, when UID validation is enabled.
+	buf := []byte{
+		0x08, 0x00, 0x18, 0x00, // (0008,0018) Tag
+		0x06, 0x00, 0x00, 0x00, // Length: 6 bytes
+		0x31, 0x2E, 0x32, 0x2E, 0x41, 0x00, // Data: "1.2.A"+NULL
+	}
+	defer OverrideConfig(config)
+	cfg := GetConfig()
+	cfg.ValidateUIDs = false
+	OverrideConfig(cfg)
+	reader := NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.LittleEndian))
+	e := NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Len(t, reader.Warnings(), 0)
+
+	cfg.ValidateUIDs = true
+	OverrideConfig(cfg)
+	reader = NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.LittleEndian))
+	e = NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Len(t, reader.Warnings(), 1)
+
+	cfg.StrictMode = true
+	OverrideConfig(cfg)
+	reader = NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.LittleEndian))
+	e = NewElement()
+	assert.IsType(t, CorruptElement{}, reader.ReadElement(&e))
+}
+
 func TestTagFromBytes(t *testing.T) {
 	// ensures that `tagFromBytes` correctly parses a
 	// dicom tag from sequences of bytes.
@@ -612,6 +1088,143 @@ func TestReadItemUndefLength(t *testing.T) {
 	r.readItemUndefLength(true, &itm)
 }
 
+func TestParseElement(t *testing.T) {
+	// ensures that `ParseElement` decodes an element according
+	// to the encoding of the given transfer syntax.
+	t.Parallel()
+	for _, testCase := range []struct {
+		ts  string
+		buf []byte
+	}{
+		{ts: ImplicitVRLittleEndian, buf: []byte{0x28, 0x00, 0x10, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x02}},
+		{ts: ExplicitVRLittleEndian, buf: []byte{0x28, 0x00, 0x10, 0x00, 0x55, 0x53, 0x02, 0x00, 0x00, 0x02}},
+		{ts: ExplicitVRBigEndian, buf: []byte{0x00, 0x28, 0x00, 0x10, 0x55, 0x53, 0x00, 0x02, 0x02, 0x00}},
+	} {
+		e, err := ParseElement(testCase.buf, testCase.ts)
+		assert.NoError(t, err, testCase.ts)
+		assert.Equal(t, uint32(0x00280010), e.GetTag(), testCase.ts)
+		rows := uint16(0)
+		assert.NoError(t, e.GetValue(&rows), testCase.ts)
+		assert.Equal(t, uint16(512), rows, testCase.ts)
+	}
+
+	_, err := ParseElement([]byte{0x28, 0x00}, ExplicitVRLittleEndian)
+	assert.Error(t, err)
+}
+
+func TestParseCommandAndDataset(t *testing.T) {
+	// ensures that an implicit VR command set is read, followed by a
+	// data set in the given transfer syntax.
+	t.Parallel()
+	msg := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x0A, 0x00, 0x00, 0x00, // CommandGroupLength: 10
+		0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, // CommandField: C-STORE-RQ
+	}
+	dataset := []byte{0x10, 0x00, 0x10, 0x00, 'P', 'N', 0x08, 0x00}
+	dataset = append(dataset, "DOE^JOHN"...)
+	command, ds, err := ParseCommandAndDataset(bytes.NewReader(append(msg, dataset...)), ExplicitVRLittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, command.Len())
+	field := uint16(0)
+	_, err = command.GetElementValue(0x00000100, &field)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x0001), field)
+	name := ""
+	found, err := ds.GetElementValue(0x00100010, &name)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "DOE^JOHN", name)
+
+	// without a data set
+	command, ds, err = ParseCommandAndDataset(bytes.NewReader(msg), ExplicitVRLittleEndian)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, command.Len())
+	assert.Equal(t, 0, ds.Len())
+
+	// not beginning with the group length, or truncated
+	_, _, err = ParseCommandAndDataset(bytes.NewReader(dataset), ImplicitVRLittleEndian)
+	assert.Error(t, err)
+	_, _, err = ParseCommandAndDataset(bytes.NewReader(msg[:16]), ExplicitVRLittleEndian)
+	assert.Error(t, err)
+}
+
+func TestReadElement64BitVRs(t *testing.T) {
+	// ensures that 64-bit VRs are read with a 32-bit length
+	// in explicit vr, and that their values are decoded.
+	// private tags are used, such that the source VR is retained.
+	t.Parallel()
+	buf := []byte{
+		0x11, 0x00, 0x01, 0x10, 0x53, 0x56, 0x00, 0x00, // (0011,1001) SV
+		0x08, 0x00, 0x00, 0x00, // Length: 8 bytes
+		0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // Data: -2
+		0x11, 0x00, 0x02, 0x10, 0x55, 0x56, 0x00, 0x00, // (0011,1002) UV
+		0x08, 0x00, 0x00, 0x00, // Length: 8 bytes
+		0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, // Data: 1<<32
+		0x11, 0x00, 0x03, 0x10, 0x4F, 0x56, 0x00, 0x00, // (0011,1003) OV
+		0x10, 0x00, 0x00, 0x00, // Length: 16 bytes
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Data: 1
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Data: 2
+	}
+	reader := NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.LittleEndian))
+	reader.SetImplicitVR(false)
+
+	e := NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	sv := int64(0)
+	assert.NoError(t, e.GetValue(&sv))
+	assert.Equal(t, int64(-2), sv)
+
+	e = NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	uv := uint64(0)
+	assert.NoError(t, e.GetValue(&uv))
+	assert.Equal(t, uint64(1<<32), uv)
+
+	e = NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	ov := []uint64{}
+	assert.NoError(t, e.GetValue(&ov))
+	assert.Equal(t, []uint64{1, 2}, ov)
+}
+
+func TestReadSequenceBigEndian(t *testing.T) {
+	// ensures that items and delimiters within a big endian sequence
+	// are correctly detected, for both defined and undefined length items.
+	t.Parallel()
+	buf := []byte{
+		0x00, 0x08, 0x11, 0x40, 0x53, 0x51, 0x00, 0x00, // (0008,1140) SQ
+		0xFF, 0xFF, 0xFF, 0xFF, // Length: undefined
+		0xFF, 0xFE, 0xE0, 0x00, 0x00, 0x00, 0x00, 0x0A, // Item, Length: 10 bytes
+		0x00, 0x28, 0x00, 0x10, 0x55, 0x53, 0x00, 0x02, 0x01, 0x00, // (0028,0010) US 256
+		0xFF, 0xFE, 0xE0, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, // Item, Length: undefined
+		0x00, 0x28, 0x00, 0x11, 0x55, 0x53, 0x00, 0x02, 0x02, 0x00, // (0028,0011) US 512
+		0xFF, 0xFE, 0xE0, 0x0D, 0x00, 0x00, 0x00, 0x00, // ItemDelimitationItem
+		0xFF, 0xFE, 0xE0, 0xDD, 0x00, 0x00, 0x00, 0x00, // SequenceDelimitationItem
+		0x00, 0x28, 0x00, 0x02, 0x55, 0x53, 0x00, 0x02, 0x00, 0x01, // (0028,0002) US 1
+	}
+	reader := NewElementReader(bin.NewReader(bytes.NewReader(buf), binary.BigEndian))
+	reader.SetImplicitVR(false)
+	e := NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Equal(t, uint32(0x00081140), e.GetTag())
+	assert.Len(t, e.GetItems(), 2)
+	for i, expected := range []struct {
+		tag   uint32
+		value uint16
+	}{{0x00280010, 256}, {0x00280011, 512}} {
+		val := uint16(0)
+		found, err := e.items[i].dataset.GetElementValue(expected.tag, &val)
+		assert.True(t, found)
+		assert.NoError(t, err)
+		assert.Equal(t, expected.value, val)
+	}
+
+	// reader should be positioned at the element following the sequence
+	e = NewElement()
+	assert.NoError(t, reader.ReadElement(&e))
+	assert.Equal(t, uint32(0x00280002), e.GetTag())
+}
+
 /*
 ===============================================================================
     Dicom
@@ -631,6 +1244,23 @@ func TestGetPreamble(t *testing.T) {
 	assert.Equal(t, preamble, dcm.GetPreamble())
 }
 
+func TestFrameTrimmed(t *testing.T) {
+	// ensures that only frames to which padding was applied are trimmed.
+	t.Parallel()
+	pd := newPixelData()
+	pd.addFrame([]byte{0xFF, 0xD8, 0xFF, 0xD9, 0x00, 0x00}, false) // native
+	pd.addFrame([]byte{0xFF, 0xD8, 0x01, 0xFF, 0xD9, 0x00}, true)  // padded codestream
+	pd.addFrame([]byte{0xFF, 0xD8, 0x01, 0x00, 0xFF, 0xD9}, true)  // even codestream
+	pd.addFrame([]byte{0xFF, 0xD8, 0x01, 0x02, 0x03, 0x00}, true)  // no EOI marker
+	assert.Len(t, pd.FrameTrimmed(0), 6)
+	assert.Equal(t, []byte{0xFF, 0xD8, 0x01, 0xFF, 0xD9}, pd.FrameTrimmed(1))
+	assert.Len(t, pd.FrameTrimmed(2), 6)
+	assert.Len(t, pd.FrameTrimmed(3), 6)
+	for i := 0; i < pd.NumFrames(); i++ {
+		assert.Len(t, pd.GetFrame(i), 6)
+	}
+}
+
 func TestNewDicom(t *testing.T) {
 	t.Parallel()
 	assert.IsType(t, Dicom{}, newDicom())
@@ -697,6 +1327,35 @@ func TestFromReader(t *testing.T) {
 	f.Close()
 }
 
+func TestFromStream(t *testing.T) {
+	// ensures that a dicom can be read from a stream of unknown length, delivered in small pieces.
+	t.Parallel()
+	path := filepath.Join("testdata", "synthetic", "VRTest.dcm")
+	expected, err := FromFile(path)
+	assert.NoError(t, err)
+	raw, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < len(raw); i += 7 {
+			end := i + 7
+			if end > len(raw) {
+				end = len(raw)
+			}
+			pw.Write(raw[i:end])
+		}
+		pw.Close()
+	}()
+	dcm, err := FromStream(pr)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.Len(), dcm.Len())
+	assertEquivalentDataSets(t, expected.DataSet, dcm.DataSet)
+
+	// truncated stream
+	dcm, err = FromStream(iotest.OneByteReader(bytes.NewReader(raw[:len(raw)/2])))
+	assert.True(t, err != nil || dcm.Len() < expected.Len())
+}
+
 func TestFromReaderError(t *testing.T) {
 	t.Parallel()
 
@@ -746,11 +1405,716 @@ func TestFromReaderError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestFromReaderPixelData(t *testing.T) {
+	// ensures that PixelData is kept in the data set once its frames have been extracted: native
+	// values are read in full, such that subsequent elements are read intact, and encapsulated
+	// frames are located by a valid offset table, or are otherwise one per fragment.
+	t.Parallel()
+	input := append(make([]byte, 128), "DICM"...)
+	input = append(input,
+		0xE0, 0x7F, 0x10, 0x00, 'O', 'W', 0x00, 0x00, // (7FE0,0010) OW
+		0x04, 0x00, 0x00, 0x00, // Length: 4 bytes
+		0x01, 0x00, 0x02, 0x00, // Data
+		0xFC, 0xFF, 0xFC, 0xFF, 'O', 'B', 0x00, 0x00, // (FFFC,FFFC) DataSetTrailingPadding
+		0x02, 0x00, 0x00, 0x00, // Length: 2 bytes
+		0x00, 0x00, // Data
+	)
+	dcm, err := FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	e := NewElement()
+	if assert.True(t, dcm.GetElement(pixelDataTag, &e)) {
+		assert.Equal(t, []byte{0x01, 0x00, 0x02, 0x00}, e.data)
+	}
+	assert.Equal(t, 1, dcm.GetPixelData().NumFrames())
+	assert.True(t, dcm.HasElement(0xFFFCFFFC))
+
+	for _, offsetTable := range [][]byte{
+		{}, // empty
+		{0x0A, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // descending, so invalid
+	} {
+		input = append(make([]byte, 128), "DICM"...)
+		input = append(input, 0xE0, 0x7F, 0x10, 0x00, 'O', 'B', 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF) // (7FE0,0010) OB, undefined length
+		input = append(input, 0xFE, 0xFF, 0x00, 0xE0, byte(len(offsetTable)), 0x00, 0x00, 0x00)     // offset table item
+		input = append(input, offsetTable...)
+		input = append(input, 0xFE, 0xFF, 0x00, 0xE0, 0x02, 0x00, 0x00, 0x00, 0x01, 0x02) // fragment #1
+		input = append(input, 0xFE, 0xFF, 0x00, 0xE0, 0x02, 0x00, 0x00, 0x00, 0x03, 0x04) // fragment #2
+		input = append(input, 0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00)             // sequence delimitation
+		dcm, err = FromReader(bytes.NewReader(input))
+		assert.NoError(t, err)
+		assert.True(t, dcm.HasElement(pixelDataTag))
+		if assert.Equal(t, 2, dcm.GetPixelData().NumFrames()) {
+			assert.Equal(t, []byte{0x01, 0x02}, dcm.GetPixelData().GetFrame(0))
+			assert.Equal(t, []byte{0x03, 0x04}, dcm.GetPixelData().GetFrame(1))
+		}
+	}
+}
+
 func TestFromFile(t *testing.T) {
 	t.Parallel()
 	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
 	assert.NoError(t, err)
-	assert.Equal(t, 27, dcm.Len())
+	// (0072,006E) is encoded with VR UT within a sequence, whereas the dictionary gives ST
+	assert.Equal(t, 37, dcm.Len())
+}
+
+func TestFromReaderDeflatedEmptyLastMetaElement(t *testing.T) {
+	// ensures that the bytes peeked beyond an empty element at the end of the meta group are
+	// inflated as part of the data set, rather than lost.
+	t.Parallel()
+	f, err := os.Open(filepath.Join("testdata", "synthetic", "DeflatedEmptyLastMetaElement.dcm"))
+	assert.NoError(t, err)
+	defer f.Close()
+	dcm, err := FromReader(f)
+	assert.NoError(t, err)
+	assert.Equal(t, DeflatedExplicitVRLittleEndian, dcm.GetTransferSyntax())
+	e := NewElement()
+	assert.True(t, dcm.GetElement(0x00020100, &e))
+	assert.Zero(t, e.Len())
+	assert.True(t, dcm.GetElement(0x00100010, &e))
+	assert.Equal(t, "Doe^John", e.DescribeValue())
+	assert.True(t, dcm.GetElement(0x00100020, &e))
+	assert.Equal(t, "12345", e.DescribeValue())
+}
+
+func TestStats(t *testing.T) {
+	// ensures that the structure of parsed dicoms is summarised, and that an
+	// absent transfer syntax is reported as the encoding having been guessed.
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
+	assert.NoError(t, err)
+	assert.Equal(t, ParseStats{
+		Elements:       37,
+		NestedElements: 8,
+		BytesParsed:    1169,
+		Sequences:      7,
+		MaxDepth:       6,
+	}, dcm.Stats())
+	dcm, err = FromFile(filepath.Join("testdata", "synthetic", "MissingTransferSyntax.dcm"))
+	assert.NoError(t, err)
+	stats := dcm.Stats()
+	assert.True(t, stats.EncodingGuessed)
+	assert.Zero(t, stats.Sequences)
+	assert.Zero(t, stats.MaxDepth)
+}
+
+func TestParseDicomWithHash(t *testing.T) {
+	t.Parallel()
+	for _, path := range []string{
+		filepath.Join("testdata", "synthetic", "VRTest.dcm"),
+		filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"),
+	} {
+		raw, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		expected, err := FromFile(path)
+		assert.NoError(t, err)
+		dcm, digest, err := ParseDicomWithHash(path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, sha256.Sum256(raw), digest, path)
+		assert.Equal(t, expected.Len(), dcm.Len(), path)
+	}
+	_, _, err := ParseDicomWithHash(filepath.Join("testdata", "missing.dcm"))
+	assert.Error(t, err)
+}
+
+func TestRawMetaBytes(t *testing.T) {
+	// ensures that the preamble, magic and meta group are captured verbatim
+	// when enabled, and not otherwise.
+	path := filepath.Join("testdata", "synthetic", "VRTest.dcm")
+	raw, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	defer OverrideConfig(config)
+	cfg := GetConfig()
+	cfg.CaptureRawMeta = false
+	OverrideConfig(cfg)
+	dcm, err := FromFile(path)
+	assert.NoError(t, err)
+	assert.Nil(t, dcm.RawMetaBytes())
+
+	cfg.CaptureRawMeta = true
+	OverrideConfig(cfg)
+	dcm, err = FromFile(path)
+	assert.NoError(t, err)
+	meta := dcm.RawMetaBytes()
+	assert.True(t, len(meta) > 132)
+	assert.Equal(t, raw[:len(meta)], meta)
+	assert.Equal(t, []byte("DICM"), meta[128:132])
+	// the meta group ends where the first non-(0002) element begins
+	assert.NotEqual(t, uint16(0x0002), binary.LittleEndian.Uint16(raw[len(meta):]))
+}
+
+func TestValidateMeta(t *testing.T) {
+	// ensures that missing or malformed meta elements are reported.
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
+	assert.NoError(t, err)
+	assert.NoError(t, dcm.ValidateMeta())
+
+	dcm, err = FromFile(filepath.Join("testdata", "synthetic", "MissingTransferSyntax.dcm"))
+	assert.NoError(t, err)
+	err = dcm.ValidateMeta()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing TransferSyntaxUID")
+	}
+
+	dcm, err = FromFile(filepath.Join("testdata", "synthetic", "ImplicitVRMeta.dcm"))
+	assert.NoError(t, err)
+	err = dcm.ValidateMeta()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing FileMetaInformationVersion, MediaStorageSOPClassUID, MediaStorageSOPInstanceUID, ImplementationClassUID")
+	}
+
+	// present, but empty
+	dcm = newDicom()
+	for _, tag := range []uint32{0x00020001, 0x00020002, 0x00020003, 0x00020010} {
+		dcm.addElement(newStringElement(tag, "1.2.3"))
+	}
+	dcm.addElement(newStringElement(0x00020012, ""))
+	err = dcm.ValidateMeta()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "ImplementationClassUID is empty")
+		assert.NotContains(t, err.Error(), "missing")
+	}
+}
+
+func TestCheckSOPConsistency(t *testing.T) {
+	// ensures that differing or unpaired SOP identifiers of the meta group and data set are reported.
+	t.Parallel()
+	dcm := newDicom()
+	assert.NoError(t, dcm.CheckSOPConsistency())
+	dcm.addElement(newStringElement(0x00020002, "1.2.840.10008.5.1.4.1.1.7"))
+	dcm.addElement(newStringElement(sopClassUIDTag, "1.2.840.10008.5.1.4.1.1.7"))
+	dcm.addElement(newStringElement(mediaStorageSOPInstanceUIDTag, "1.2.3.4"))
+	dcm.addElement(newStringElement(sopInstanceUIDTag, "1.2.3.4"))
+	assert.NoError(t, dcm.CheckSOPConsistency())
+
+	dcm.addElement(newStringElement(sopInstanceUIDTag, "1.2.3.5"))
+	err := dcm.CheckSOPConsistency()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `MediaStorageSOPInstanceUID "1.2.3.4" does not match SOPInstanceUID "1.2.3.5"`)
+		assert.NotContains(t, err.Error(), "SOPClassUID")
+	}
+
+	delete(dcm.DataSet, 0x00020002)
+	err = dcm.CheckSOPConsistency()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "SOPClassUID is present, but MediaStorageSOPClassUID is not; ")
+	}
+}
+
+func TestFromFileDefinedLengthSQZeroLengthItem(t *testing.T) {
+	// ensures that a zero length item within a defined length sequence
+	// is read without disturbing the parsing of subsequent items and elements.
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "DefinedLengthSQZeroLengthItem.dcm"))
+	assert.NoError(t, err)
+	e := NewElement()
+	assert.True(t, dcm.GetElement(0x00081140, &e))
+	assert.Len(t, e.GetItems(), 2)
+	assert.Equal(t, 0, e.items[0].dataset.Len())
+	uid := ""
+	found, err := e.items[1].dataset.GetElementValue(0x00081155, &uid)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", uid)
+	name := ""
+	found, err = dcm.GetElementValue(0x00100010, &name)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "DOE^JOHN", name)
+}
+
+func TestDeferItems(t *testing.T) {
+	// ensures that the parsing of defined length items can be deferred, that their position within
+	// the source is recorded, and that they are parsed on request, or when written.
+	defer OverrideConfig(config)
+	path := filepath.Join("testdata", "synthetic", "DefinedLengthSQZeroLengthItem.dcm")
+	cfg := GetConfig()
+	cfg.DeferItems = func(tag uint32) bool { return tag == 0x00081140 }
+	OverrideConfig(cfg)
+	dcm, err := FromFile(path)
+	assert.NoError(t, err)
+	e := NewElement()
+	assert.True(t, dcm.GetElement(0x00081140, &e))
+	if !assert.Len(t, e.Items(), 2) {
+		return
+	}
+	// the zero length item has nothing to defer
+	offset, length, parsed, err := e.ItemRange(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(192), int64(0), true}, []interface{}{offset, length, parsed})
+	offset, length, parsed, err = e.ItemRange(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(200), int64(16), false}, []interface{}{offset, length, parsed})
+	assert.Equal(t, 0, e.items[1].dataset.Len())
+	raw, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, raw[offset:offset+length], e.items[1].raw)
+
+	// when written, deferred items are parsed
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+
+	itm, err := e.ParseItem(1)
+	assert.NoError(t, err)
+	uid := ""
+	_, err = itm.dataset.GetElementValue(0x00081155, &uid)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", uid)
+	_, _, parsed, _ = e.ItemRange(1)
+	assert.True(t, parsed)
+	// the element held by the data set shares the parsed item
+	assert.True(t, dcm.GetElement(0x00081140, &e))
+	assert.Equal(t, 1, e.items[1].dataset.Len())
+	_, err = e.ParseItem(2)
+	assert.Error(t, err)
+	_, _, _, err = e.ItemRange(2)
+	assert.Error(t, err)
+	_, _, _, err = e.ItemRange(-1)
+	assert.Error(t, err)
+
+	assert.True(t, written.GetElement(0x00081140, &e))
+	if assert.Len(t, e.Items(), 2) {
+		_, err = e.items[1].dataset.GetElementValue(0x00081155, &uid)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.3.4", uid)
+	}
+}
+
+func TestFromFileDefinedLengthSQUndefinedLengthItems(t *testing.T) {
+	// ensures that undefined length items within a defined length sequence are each
+	// read up to their delimitation item, and that subsequent elements are unaffected.
+	t.Parallel()
+	path := filepath.Join("testdata", "synthetic", "DefinedLengthSQUndefinedLengthItems.dcm")
+	dcm, err := FromFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, dcm.CheckAlignment())
+	e := NewElement()
+	assert.True(t, dcm.GetElement(0x00081140, &e))
+	uids := []string{}
+	assert.NoError(t, e.CollectItemValues(0x00081155, &uids))
+	assert.Equal(t, []string{"1.2.3.4", "1.2.3.5"}, uids)
+	assert.Equal(t, 3, e.items[1].dataset.Len())
+	name := ""
+	found, err := dcm.GetElementValue(0x00100010, &name)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "DOE^JOHN", name)
+	_, result, err := FromFileLazy(path)
+	assert.NoError(t, err)
+	assert.True(t, result.Valid)
+}
+
+func TestFromFileImplicitVRMeta(t *testing.T) {
+	// ensures that a meta group incorrectly encoded as implicit vr
+	// is recovered, and that a warning is recorded.
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "ImplicitVRMeta.dcm"))
+	assert.NoError(t, err)
+	assert.Equal(t, ExplicitVRLittleEndian, dcm.GetTransferSyntax())
+	assert.Len(t, dcm.Warnings(), 1)
+	modality := ""
+	found, err := dcm.GetElementValue(0x00080060, &modality)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "CT", modality)
+
+	// each element records the encoding with which it was read
+	e := NewElement()
+	assert.True(t, dcm.GetElement(transferSyntaxTag, &e))
+	assert.Equal(t, Encoding{ImplicitVR: true, LittleEndian: true}, e.Encoding())
+	assert.True(t, dcm.GetElement(0x00080060, &e))
+	assert.Equal(t, Encoding{ImplicitVR: false, LittleEndian: true}, e.Encoding())
+}
+
+func TestFromFileUndefinedLengthNonSQ(t *testing.T) {
+	// ensures that undefined length on an element whose VR cannot carry it is
+	// recovered from, or rejected in strict mode.
+	defer OverrideConfig(config)
+	path := filepath.Join("testdata", "synthetic", "UndefinedLengthNonSQ.dcm")
+	dcm, err := FromFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, dcm.Warnings(), 2)
+	// followed by items: read as a sequence
+	e := NewElement()
+	assert.True(t, dcm.GetElement(0x00081030, &e))
+	if assert.Len(t, e.GetItems(), 1) {
+		uid := ""
+		_, err = e.items[0].dataset.GetElementValue(0x00081155, &uid)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.3.4", uid)
+	}
+	// not followed by items: treated as empty
+	assert.True(t, dcm.GetElement(0x00280010, &e))
+	assert.Equal(t, 0, e.Len())
+	name := ""
+	found, err := dcm.GetElementValue(0x00100010, &name)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "DOE^JOHN", name)
+
+	cfg := GetConfig()
+	cfg.StrictMode = true
+	OverrideConfig(cfg)
+	_, err = FromFile(path)
+	assert.IsType(t, CorruptElement{}, err)
+}
+
+func TestFromReaderEmbeddedPreamble(t *testing.T) {
+	// ensures that a second preamble, or magic, within the data set is reported
+	// as corruption at its offset, rather than parsed as elements.
+	t.Parallel()
+	input := append(make([]byte, 128), "DICM"...)
+	input = append(input, 0x10, 0x00, 0x10, 0x00, 'P', 'N', 0x08, 0x00)
+	input = append(input, "DOE^JOHN"...)
+	concatenated := append(append([]byte{}, input...), input...)
+	_, err := FromReader(bytes.NewReader(concatenated))
+	if assert.IsType(t, CorruptDicom{}, err) {
+		assert.Contains(t, err.Error(), "offset 148")
+	}
+
+	// the magic alone
+	_, err = FromReader(bytes.NewReader(append(append([]byte{}, input...), input[128:]...)))
+	assert.IsType(t, CorruptDicom{}, err)
+
+	// trailing zeroes, without magic, are unaffected
+	dcm, err := FromReader(bytes.NewReader(append(append([]byte{}, input...), 0x00, 0x00)))
+	_, corrupt := err.(CorruptDicom)
+	assert.False(t, corrupt)
+	assert.True(t, dcm.HasElement(0x00100010))
+}
+
+func TestErrorWrapping(t *testing.T) {
+	// ensures that the cause of each error type, and the type itself, can be
+	// found with errors.Is and errors.As, even when further wrapped.
+	t.Parallel()
+	err := fmt.Errorf("reading file: %w", CorruptElement{io.ErrUnexpectedEOF})
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.True(t, errors.Is(err, ErrCorruptElement))
+	assert.False(t, errors.Is(err, ErrCorruptDicom))
+	corrupt := CorruptElement{}
+	if assert.True(t, errors.As(err, &corrupt)) {
+		assert.Equal(t, io.ErrUnexpectedEOF, corrupt.Unwrap())
+	}
+
+	magic := append(make([]byte, 128), "DICM"...)
+	_, err = FromReader(bytes.NewReader(append(append([]byte{}, magic...), magic...)))
+	assert.True(t, errors.Is(err, ErrCorruptDicom))
+	assert.False(t, errors.Is(err, ErrCorruptElement))
+	assert.True(t, errors.As(err, &CorruptDicom{}))
+}
+
+func TestFromReaderAlternateLengthField(t *testing.T) {
+	// ensures that an element whose length field is of the wrong size for its VR is diagnosed,
+	// and read as it was written, rather than corrupting the subsequent elements.
+	defer OverrideConfig(config)
+	magic := append(make([]byte, 128), "DICM"...)
+	name := append([]byte{0x10, 0x00, 0x10, 0x00, 'P', 'N', 0x08, 0x00}, "DOE^JOHN"...)
+	for _, tc := range []struct {
+		element []byte
+		tag     uint32
+		bits    string
+	}{
+		// UR, written with a 16-bit length
+		{append([]byte{0x08, 0x00, 0x20, 0x01, 'U', 'R', 0x04, 0x00}, "CODE"...), 0x00080120, "32 bits, but was encoded with 16"},
+		// UC, written with a 16-bit length
+		{append([]byte{0x08, 0x00, 0x19, 0x01, 'U', 'C', 0x04, 0x00}, "CODE"...), 0x00080119, "32 bits, but was encoded with 16"},
+		// LO, written with reserved bytes and a 32-bit length
+		{append([]byte{0x10, 0x00, 0x20, 0x00, 'L', 'O', 0x00, 0x00, 0x04, 0x00, 0x00, 0x00}, "CODE"...), 0x00100020, "16 bits, but was encoded with 32"},
+	} {
+		for _, trailing := range [][]byte{nil, name} {
+			input := append(append(append([]byte{}, magic...), tc.element...), trailing...)
+			cfg := GetConfig()
+			cfg.StrictMode = false
+			OverrideConfig(cfg)
+			dcm, err := FromReader(bytes.NewReader(input))
+			assert.NoError(t, err)
+			value := ""
+			_, err = dcm.GetElementValue(tc.tag, &value)
+			assert.NoError(t, err)
+			assert.Equal(t, "CODE", value)
+			assert.Equal(t, trailing != nil, dcm.HasElement(0x00100010))
+			if assert.Len(t, dcm.Warnings(), 1) {
+				assert.Contains(t, dcm.Warnings()[0], "at offset 132")
+				assert.Contains(t, dcm.Warnings()[0], tc.bits)
+			}
+
+			cfg.StrictMode = true
+			OverrideConfig(cfg)
+			_, err = FromReader(bytes.NewReader(input))
+			if assert.Error(t, err) {
+				assert.IsType(t, CorruptElement{}, err)
+				assert.Contains(t, err.Error(), tc.bits)
+			}
+		}
+	}
+
+	// an empty element followed by a valid element is read as per the standard
+	cfg := GetConfig()
+	cfg.StrictMode = true
+	OverrideConfig(cfg)
+	input := append(append(append([]byte{}, magic...), 0x10, 0x00, 0x20, 0x00, 'L', 'O', 0x00, 0x00), name...)
+	dcm, err := FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	assert.True(t, dcm.HasElement(0x00100010))
+	assert.Empty(t, dcm.Warnings())
+
+	// an empty element ending the meta group is not re-read from the implicit VR data set which
+	// follows it, despite the data set reading as a 32-bit length in explicit VR
+	meta := []byte{0x02, 0x00, 0x00, 0x00, 'U', 'L', 0x04, 0x00, 0x22, 0x00, 0x00, 0x00}
+	meta = append(meta, 0x02, 0x00, 0x10, 0x00, 'U', 'I', 0x12, 0x00)
+	meta = append(meta, "1.2.840.10008.1.2\x00"...)
+	meta = append(meta, 0x02, 0x00, 0x16, 0x00, 'A', 'E', 0x00, 0x00)
+	input = append(append(append([]byte{}, magic...), meta...), 0x08, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x2A, 0x00, 0x00, 0x00)
+	dcm, err = FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	assert.True(t, dcm.HasElement(0x00020016))
+	groupLength := uint32(0)
+	_, err = dcm.GetElementValue(0x00080000, &groupLength)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(42), groupLength)
+	assert.Empty(t, dcm.Warnings())
+}
+
+func TestReadValueKnownLength(t *testing.T) {
+	// ensures that a large value lying within an input of known length is allocated once, in full,
+	// rather than grown as it is read. not parallel, as allocations are measured.
+	n := 3 * maxValueChunk
+	buf := append([]byte{0x09, 0x00, 0x10, 0x10, 0x00, 0x00, 0x30, 0x00}, make([]byte, n)...)
+	before := runtime.MemStats{}
+	runtime.ReadMemStats(&before)
+	e, err := ParseElement(buf, ImplicitVRLittleEndian)
+	after := runtime.MemStats{}
+	runtime.ReadMemStats(&after)
+	assert.NoError(t, err)
+	assert.Equal(t, n, e.Len())
+	assert.True(t, after.TotalAlloc-before.TotalAlloc < uint64(n+maxValueChunk/2), "allocated %d bytes", after.TotalAlloc-before.TotalAlloc)
+
+	// of unknown length, the value is still read in full
+	dcm, err := FromReader(io.MultiReader(bytes.NewReader(append(append(make([]byte, 128), "DICM"...), buf...))))
+	assert.NoError(t, err)
+	assert.True(t, dcm.GetElement(0x00091010, &e))
+	assert.Equal(t, n, e.Len())
+}
+
+func TestFromReaderNeverPanics(t *testing.T) {
+	// ensures that malformed input results in an error, rather than a panic or an
+	// allocation of the length declared by a corrupt element.
+	t.Parallel()
+	magic := append(make([]byte, 128), "DICM"...)
+	// a UI value consisting solely of padding
+	input := append(append([]byte{}, magic...), 0x08, 0x00, 0x18, 0x00, 'U', 'I', 0x01, 0x00, 0x00)
+	dcm, err := FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	assert.True(t, dcm.HasElement(0x00080018))
+	// an OB value declaring a length of almost 4GB
+	input = append(append([]byte{}, magic...), 0x09, 0x00, 0x10, 0x10, 'O', 'B', 0x00, 0x00, 0xF0, 0xFF, 0xFF, 0xFF, 0x00)
+	_, err = FromReader(bytes.NewReader(input))
+	assert.Error(t, err)
+
+	// random mutations of valid files, and random bytes
+	rng := rand.New(rand.NewSource(1))
+	fixtures := make([][]byte, 0)
+	for _, name := range []string{"VRTest.dcm", "ExtendedOffsetTable.dcm", "ShiftJIS.dcm"} {
+		raw, err := ioutil.ReadFile(filepath.Join("testdata", "synthetic", name))
+		assert.NoError(t, err)
+		fixtures = append(fixtures, raw)
+	}
+	for i := 0; i < 2000; i++ {
+		input = append([]byte{}, fixtures[rng.Intn(len(fixtures))]...)
+		for n := rng.Intn(8); n >= 0; n-- {
+			input[rng.Intn(len(input))] = byte(rng.Intn(256))
+		}
+		if i%2 == 0 {
+			input = append(append([]byte{}, magic...), make([]byte, rng.Intn(512))...)
+			rng.Read(input[len(magic):])
+		}
+		assert.NotPanics(t, func() {
+			FromReader(bytes.NewReader(input))
+			FromReaderLazy(bytes.NewReader(input))
+			ParseElement(input[len(magic):], ExplicitVRLittleEndian)
+			ParseCommandAndDataset(bytes.NewReader(input[len(magic):]), ImplicitVRLittleEndian)
+		}, "input #%d", i)
+	}
+}
+
+// FuzzFromReader asserts that arbitrary input is parsed without panicking, seeded with the
+// synthetic test data. Run with `go test -fuzz FuzzFromReader`.
+func FuzzFromReader(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "synthetic", "*.dcm"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(raw)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// errors are expected; a panic fails the run
+		FromReader(bytes.NewReader(data))
+		FromReaderLazy(bytes.NewReader(data))
+		for _, ts := range []string{ImplicitVRLittleEndian, ExplicitVRLittleEndian, ExplicitVRBigEndian} {
+			ParseElement(data, ts)
+			ParseCommandAndDataset(bytes.NewReader(data), ts)
+		}
+	})
+}
+
+func TestCheckAlignment(t *testing.T) {
+	// ensures that an implicit VR element whose length is misread, causing the parser to
+	// silently stop at the end of the input, is reported.
+	t.Parallel()
+	newImplicitDicom := func(nameLength byte) []byte {
+		input := append(make([]byte, 128), "DICM"...)
+		input = append(input, 0x08, 0x00, 0x60, 0x00, 0x02, 0x00, 0x00, 0x00, 'C', 'T')
+		input = append(input, 0x10, 0x00, 0x10, 0x00, nameLength, 0x00, 0x00, 0x00)
+		input = append(input, "DOE^JOHN"...)
+		return append(input, 0x10, 0x00, 0x20, 0x00, 0x04, 0x00, 0x00, 0x00, '1', '2', '3', '4')
+	}
+	dcm, err := FromReader(bytes.NewReader(newImplicitDicom(8)))
+	assert.NoError(t, err)
+	assert.NoError(t, dcm.CheckAlignment())
+
+	dcm, err = FromReader(bytes.NewReader(newImplicitDicom(12)))
+	assert.NoError(t, err)
+	err = dcm.CheckAlignment()
+	if assert.IsType(t, CorruptDicom{}, err) {
+		assert.Contains(t, err.Error(), "(0004,0000) at offset 162")
+	}
+
+	// dicoms which were not parsed always pass
+	dcm = newDicom()
+	assert.NoError(t, dcm.CheckAlignment())
+	dcm, err = FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	assert.NoError(t, dcm.CheckAlignment())
+}
+
+func TestFromFileSequenceTrailingGarbage(t *testing.T) {
+	// ensures that, in repair mode, stray bytes preceding an item or delimitation
+	// tag within a sequence are skipped.
+	defer OverrideConfig(config)
+	cfg := GetConfig()
+	cfg.RepairMode = true
+	OverrideConfig(cfg)
+	path := filepath.Join("testdata", "synthetic", "SequenceTrailingGarbage.dcm")
+	dcm, err := FromFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, dcm.Warnings(), 2)
+	e := NewElement()
+	if assert.True(t, dcm.GetElement(0x00400275, &e)) && assert.Len(t, e.GetItems(), 2) {
+		for i, expected := range []string{"SPS1", "SPS2"} {
+			assert.Len(t, e.items[i].dataset, 1)
+			id := ""
+			_, err = e.items[i].dataset.GetElementValue(0x00400009, &id)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, id)
+		}
+	}
+	id := ""
+	found, err := dcm.GetElementValue(0x00401001, &id)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "RP01", id)
+
+	// not attempted in strict mode
+	cfg.StrictMode = true
+	OverrideConfig(cfg)
+	dcm, _ = FromFile(path)
+	assert.Empty(t, dcm.Warnings())
+}
+
+func TestWarningsAreErrors(t *testing.T) {
+	// ensures that, when enabled, warnings encountered whilst parsing are returned as errors.
+	defer OverrideConfig(config)
+	input := append(make([]byte, 128), "DICM"...)
+	input = append(input, 0x08, 0x00, 0x05, 0x00, 'C', 'S', 0x0A, 0x00)
+	input = append(input, "ISO_IR 999"...)
+	dcm, err := FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	if assert.Len(t, dcm.Warnings(), 1) {
+		assert.Contains(t, dcm.Warnings()[0], "ISO_IR 999")
+	}
+
+	cfg := GetConfig()
+	cfg.WarningsAreErrors = true
+	OverrideConfig(cfg)
+	_, err = FromReader(bytes.NewReader(input))
+	assert.IsType(t, CorruptDicom{}, err)
+	assert.True(t, errors.Is(err, ErrCorruptDicom))
+	_, err = FromFile(filepath.Join("testdata", "synthetic", "UndefinedLengthNonSQ.dcm"))
+	assert.IsType(t, CorruptDicom{}, err)
+	_, err = FromFile(filepath.Join("testdata", "synthetic", "ShiftJIS.dcm"))
+	assert.NoError(t, err)
+}
+
+func TestSkipElement(t *testing.T) {
+	// ensures that elements whose values are skipped are not stored, and that the
+	// reader is correctly advanced past values of both defined and undefined length.
+	defer OverrideConfig(config)
+	paths := []string{
+		filepath.Join("testdata", "synthetic", "VRTest.dcm"),
+		filepath.Join("testdata", "synthetic", "UndefinedLengthNonSQ.dcm"),
+		filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"),
+	}
+	expected := make([]Dicom, len(paths))
+	for i, path := range paths {
+		dcm, err := FromFile(path)
+		assert.NoError(t, err)
+		expected[i] = dcm
+	}
+
+	cfg := GetConfig()
+	cfg.SkipElement = func(tag uint32, vr string, length uint32) bool {
+		return vr == "SQ" || length == 0xFFFFFFFF || (vr == "OB" || vr == "OW") && length > 8
+	}
+	OverrideConfig(cfg)
+	for i, path := range paths {
+		dcm, err := FromFile(path)
+		assert.NoError(t, err, path)
+		assert.True(t, dcm.Len() < expected[i].Len(), path)
+		for tag, e := range expected[i].DataSet {
+			skipped := e.GetVR() == "SQ" || e.ValueLength() == 0xFFFFFFFF || (e.GetVR() == "OB" || e.GetVR() == "OW") && e.ValueLength() > 8
+			assert.Equal(t, !skipped, dcm.HasElement(tag), "%s: %s", path, e.GetName())
+		}
+	}
+}
+
+func TestFixMetaLength(t *testing.T) {
+	// ensures that an incorrect meta group length is patched in-place,
+	// and that a correct one is left untouched.
+	t.Parallel()
+	original, err := ioutil.ReadFile(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
+	assert.NoError(t, err)
+	f, err := ioutil.TempFile("", "opendcm")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	corrupted := append([]byte{}, original...)
+	binary.LittleEndian.PutUint32(corrupted[140:144], 0xFFFF)
+	_, err = f.Write(corrupted)
+	assert.NoError(t, err)
+	f.Close()
+
+	assert.NoError(t, FixMetaLength(f.Name()))
+	fixed, err := ioutil.ReadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, original, fixed)
+
+	// already correct
+	assert.NoError(t, FixMetaLength(f.Name()))
+	fixed, err = ioutil.ReadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, original, fixed)
+
+	// no group length element to patch
+	assert.Error(t, FixMetaLength(filepath.Join("testdata", "synthetic", "MissingMetaLength.dcm")))
+	// does not exist
+	assert.Error(t, FixMetaLength("__.__0000"))
 }
 
 func TestFromFileError(t *testing.T) {
@@ -864,6 +2228,26 @@ func TestCharsetDecode(t *testing.T) {
 	}
 }
 
+func TestCharsetDecodeConcurrent(t *testing.T) {
+	// ensures that concurrently decoding files of the same character set
+	// produces correct output. run with `-race` to detect shared decoder state.
+	t.Parallel()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dcm, err := FromFile(filepath.Join("testdata", "synthetic", "ShiftJIS.dcm"))
+			assert.NoError(t, err)
+			name := ""
+			_, err = dcm.GetElementValue(0x00100010, &name)
+			assert.NoError(t, err)
+			assert.Equal(t, "エンコードされたメッセージ", name)
+		}()
+	}
+	wg.Wait()
+}
+
 func BenchmarkFromReader(b *testing.B) {
 	// from byte reader
 	f, err := os.Open(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
@@ -891,3 +2275,317 @@ func BenchmarkFromReader(b *testing.B) {
 		r.Reset(buf)
 	}
 }
+
+func TestSetValueDS(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		value    float64
+		expected string
+	}{
+		{0, "0"},
+		{1.5, "1.5"},
+		{-0.25, "-0.25"},
+		{1.0 / 3.0, "0.33333333333333"},
+		{123456789012345678, "1.2345678901e+17"},
+		{-1.2345678901234567e-300, "-1.23456789e-300"},
+		{1e-7, "1e-07"},
+		{1.2345678901234567e+300, "1.23456789e+300"},
+		{6.02214076e23, "6.02214076e+23"},
+	} {
+		e := NewElementWithTag(0x00180050) // SliceThickness
+		assert.NoError(t, e.SetValue(tc.value))
+		str := ""
+		assert.NoError(t, e.GetValue(&str))
+		assert.Equal(t, tc.expected, str)
+		assert.True(t, len(str) <= 16, str)
+		parsed, err := strconv.ParseFloat(str, 64)
+		assert.NoError(t, err)
+		if tc.value != 0 {
+			assert.InEpsilon(t, tc.value, parsed, 1e-8)
+		}
+	}
+
+	// multiple values
+	e := NewElementWithTag(0x00280030) // PixelSpacing
+	assert.NoError(t, e.SetValue([]float64{0.5, 0.25, 10}))
+	strs := []string{}
+	assert.NoError(t, e.GetValue(&strs))
+	assert.Equal(t, []string{"0.5", "0.25", "10"}, strs)
+
+	// odd length values are padded upon write
+	buf := bytes.NewBuffer(nil)
+	elw := NewElementWriter(buf)
+	assert.NoError(t, elw.WriteElement(e))
+	assert.Equal(t, []byte("DS\x0c\x000.5\\0.25\\10 "), buf.Bytes()[4:])
+
+	// unrepresentable values, unsupported types and non-DS elements
+	assert.Error(t, e.SetValue(math.NaN()))
+	assert.Error(t, e.SetValue(math.Inf(-1)))
+	assert.Error(t, e.SetValue(int64(1)))
+	e = NewElementWithTag(0x00280010) // Rows
+	assert.Error(t, e.SetValue(0.5))
+}
+
+func TestSetValueRaw(t *testing.T) {
+	// ensures that text, raw values and encapsulated frames can be set,
+	// and are written as if they had been read.
+	t.Parallel()
+	ds := make(DataSet, 0)
+	name := NewElementWithTag(0x00100010) // PatientName
+	assert.NoError(t, name.SetValue("Doe^John"))
+	ds.addElement(name)
+	rows := NewElementWithTag(0x00280010) // Rows
+	assert.NoError(t, rows.SetValue([]byte{0x00, 0x02}))
+	ds.addElement(rows)
+	pixelData := NewElementWithTag(pixelDataTag)
+	assert.NoError(t, pixelData.SetValue([][]byte{{0x01, 0x02, 0x03}, {0x04, 0x05}}))
+	ds.addElement(pixelData)
+
+	// text may only be set for textual VRs, and frames only for PixelData
+	assert.Error(t, rows.SetValue("512"))
+	assert.Error(t, name.SetValue([][]byte{{0x01}}))
+
+	buf := bytes.NewBuffer(nil)
+	_, err := ds.WriteTo(buf)
+	assert.NoError(t, err)
+	dcm, err := FromReader(buf)
+	assert.NoError(t, err)
+	str := ""
+	found, err := dcm.GetElementValue(0x00100010, &str)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, "Doe^John", str)
+	value := uint16(0)
+	found, err = dcm.GetElementValue(0x00280010, &value)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(512), value)
+	e := NewElement()
+	assert.True(t, dcm.GetElement(pixelDataTag, &e))
+	items := e.GetItems()
+	assert.Len(t, items, 3)
+	assert.Empty(t, items[0].fragment)
+	assert.Equal(t, []byte{0x01, 0x02, 0x03, 0x00}, items[1].fragment)
+	assert.Equal(t, []byte{0x04, 0x05}, items[2].fragment)
+}
+
+func TestFromReaderLazy(t *testing.T) {
+	// ensures that a lazy parse skips large binary values, which can be loaded
+	// afterwards, and that framing problems are reported.
+	t.Parallel()
+	path := filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm")
+	buf, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	expected, err := FromReader(bytes.NewReader(buf))
+	assert.NoError(t, err)
+
+	dcm, result := FromReaderLazy(bytes.NewReader(buf))
+	assert.True(t, result.Valid)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, expected.Len(), dcm.Len())
+	e := NewElement()
+	assert.True(t, dcm.GetElement(pixelDataTag, &e))
+	assert.True(t, e.IsLazy())
+	assert.Nil(t, e.data)
+	assert.NoError(t, e.LoadValue(bytes.NewReader(buf)))
+	assert.False(t, e.IsLazy())
+	assert.Equal(t, expected.GetPixelData().GetFrame(0), e.data)
+	// small values are read as usual
+	assert.True(t, dcm.GetElement(0x00100010, &e))
+	assert.False(t, e.IsLazy())
+
+	_, result, err = FromFileLazy(path)
+	assert.NoError(t, err)
+	assert.True(t, result.Valid)
+	_, _, err = FromFileLazy("__.__0000")
+	assert.Error(t, err)
+
+	// truncated within, and immediately before, the pixel data value
+	pixelDataOffset := bytes.Index(buf, []byte{0xE0, 0x7F, 0x10, 0x00})
+	for _, truncated := range [][]byte{buf[:len(buf)-10], buf[:pixelDataOffset+12]} {
+		_, result = FromReaderLazy(bytes.NewReader(truncated))
+		assert.False(t, result.Valid)
+		assert.IsType(t, CorruptElement{}, result.Err)
+	}
+
+	// unrecognised VR
+	corrupt := append([]byte{}, buf...)
+	copy(corrupt[pixelDataOffset+4:], "ZZ")
+	_, result = FromReaderLazy(bytes.NewReader(corrupt))
+	assert.False(t, result.Valid)
+	assert.Error(t, result.Err)
+}
+
+func TestElementsMatching(t *testing.T) {
+	// ensures that elements are selected by tag, and returned in ascending order.
+	t.Parallel()
+	ds := newDataSet(
+		newStringElement(0x00100010, "DOE^JOHN"),
+		newUSElement(0x60020010, 512),
+		newUSElement(0x60000011, 512),
+		newUSElement(0x60000010, 512),
+		newStringElement(0x00091010, "PRIVATE"),
+	)
+	assert.Equal(t, []uint32{0x60000010, 0x60000011}, ds.TagsInGroup(0x6000))
+	assert.Equal(t, []uint32{}, ds.TagsInGroup(0x0008))
+
+	overlays := ds.ElementsMatching(func(tag uint32) bool { return tag&0xFF000000 == 0x60000000 })
+	if assert.Len(t, overlays, 3) {
+		assert.Equal(t, uint32(0x60000010), overlays[0].GetTag())
+		assert.Equal(t, uint32(0x60000011), overlays[1].GetTag())
+		assert.Equal(t, uint32(0x60020010), overlays[2].GetTag())
+	}
+	private := ds.ElementsMatching(func(tag uint32) bool { return (tag>>16)%2 == 1 })
+	if assert.Len(t, private, 1) {
+		assert.Equal(t, uint32(0x00091010), private[0].GetTag())
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	// ensures that insignificant spaces are removed from each value, including within
+	// sequences, and that values are only converted to NFC when requested.
+	t.Parallel()
+	getString := func(ds DataSet, tag uint32) string {
+		e := NewElement()
+		ds.GetElement(tag, &e)
+		return string(e.data)
+	}
+	decomposed := "Jose\u0301 "
+	ds := newDataSet(
+		newStringElement(0x00100010, "SMITH "),
+		newStringElement(0x00080060, ` CT\MR `),
+		newStringElement(0x00204000, "  indented text  "),
+		newStringElement(0x00100020, decomposed),
+		newUSElement(0x00280010, 0x2020),
+		newSequence(0x00081140, newDataSet(newStringElement(0x00081155, "1.2.3.4 "))),
+	)
+	ds.NormalizeText(false)
+	assert.Equal(t, "SMITH", getString(ds, 0x00100010))
+	assert.Equal(t, `CT\MR`, getString(ds, 0x00080060))
+	assert.Equal(t, "  indented text", getString(ds, 0x00204000))
+	assert.Equal(t, "Jose\u0301", getString(ds, 0x00100020))
+	assert.Equal(t, []byte{0x20, 0x20}, ds[0x00280010].data)
+	seq := NewElement()
+	ds.GetElement(0x00081140, &seq)
+	assert.Equal(t, "1.2.3.4", getString(seq.items[0].dataset, 0x00081155))
+
+	ds.NormalizeText(true)
+	assert.Equal(t, "Jos\u00e9", getString(ds, 0x00100020))
+	e := NewElement()
+	ds.GetElement(0x00100020, &e)
+	value := ""
+	assert.NoError(t, e.GetValue(&value))
+	assert.Equal(t, "Jos\u00e9", value)
+}
+
+func TestTextContent(t *testing.T) {
+	t.Parallel()
+	ds := newDataSet(
+		newStringElement(0x00100010, "DOE^JOHN"),
+		newStringElement(0x00080060, "SR"),
+		newStringElement(0x00080020, "20180101"),
+		newStringElement(0x00081030, `HEAD\NECK `),
+		newUSElement(0x00280010, 512),
+		newSequence(0x0040A730, newDataSet(
+			newStringElement(0x0040A040, "TEXT"),
+			newStringElement(0x0040A160, "No abnormality detected."),
+		)),
+		newStringElement(0x00081040, ""),
+	)
+	assert.Equal(t, "HEAD\nNECK\nDOE^JOHN\nNo abnormality detected.", ds.TextContent(false))
+	assert.Equal(t, "20180101\nSR\nHEAD\nNECK\nDOE^JOHN\nTEXT\nNo abnormality detected.", ds.TextContent(true))
+}
+
+func TestNonRetired(t *testing.T) {
+	t.Parallel()
+	lengthToEnd := NewElementWithTag(0x00080001)
+	assert.True(t, lengthToEnd.IsRetired())
+	name := newStringElement(0x00100010, "DOE^JOHN")
+	assert.False(t, name.IsRetired())
+	ds := newDataSet(lengthToEnd, name, newSequence(0x00081140, newDataSet(lengthToEnd, name)))
+
+	filtered := ds.NonRetired()
+	assert.Equal(t, 2, filtered.Len())
+	assert.False(t, filtered.HasElement(0x00080001))
+	assert.True(t, filtered.HasElement(0x00100010))
+	seq := NewElement()
+	if assert.True(t, filtered.GetElement(0x00081140, &seq)) && assert.Len(t, seq.items, 1) {
+		assert.Equal(t, 1, seq.items[0].dataset.Len())
+		assert.True(t, seq.items[0].dataset.HasElement(0x00100010))
+	}
+	// the original is unaffected
+	assert.Equal(t, 3, ds.Len())
+	ds.GetElement(0x00081140, &seq)
+	assert.Equal(t, 2, seq.items[0].dataset.Len())
+}
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dcm.addElement(newSequence(0x00081140, newDataSet(newStringElement(0x00100010, `DOE^JOHN\DOE^JANE`))))
+	dcm.addElement(newElementWithData(0x00420011, []byte("%PDF-1.4"))) // EncapsulatedDocument, OB
+	redacted := dcm.Redact()
+	assert.Equal(t, dcm.Len(), redacted.Len())
+
+	// text values are replaced, retaining their length and multiplicity
+	seq := NewElement()
+	if assert.True(t, redacted.GetElement(0x00081140, &seq)) && assert.Len(t, seq.items, 1) {
+		name := NewElement()
+		assert.True(t, seq.items[0].dataset.GetElement(0x00100010, &name))
+		assert.Equal(t, `XXXXXXXX\XXXXXXXX`, string(name.data))
+	}
+	// UIDs remain valid, meta and coded values are intact
+	uid, original := "", ""
+	dcm.GetElementValue(sopInstanceUIDTag, &original)
+	redacted.GetElementValue(sopInstanceUIDTag, &uid)
+	assert.Len(t, uid, len(original))
+	assert.NotEqual(t, original, uid)
+	assert.Regexp(t, `^[9.]+$`, uid)
+	redacted.GetElementValue(mediaStorageSOPInstanceUIDTag, &uid)
+	assert.Equal(t, original, uid)
+	modality := ""
+	redacted.GetElementValue(0x00080060, &modality)
+	assert.Equal(t, "PT", modality)
+
+	// pixel data is zeroed
+	pixelData := NewElement()
+	if assert.True(t, redacted.GetElement(pixelDataTag, &pixelData)) {
+		assert.Equal(t, make([]byte, len(pixelData.data)), pixelData.data)
+	}
+	if pd := redacted.GetPixelData(); assert.Equal(t, 1, pd.NumFrames()) {
+		assert.Equal(t, make([]byte, len(pd.GetFrame(0))), pd.GetFrame(0))
+	}
+	// as are other binary values
+	document := NewElement()
+	if assert.True(t, redacted.GetElement(0x00420011, &document)) {
+		assert.Equal(t, make([]byte, 8), document.data)
+	}
+	// the original is unaffected
+	dcm.GetElement(0x00081140, &seq)
+	name := NewElement()
+	seq.items[0].dataset.GetElement(0x00100010, &name)
+	assert.Equal(t, `DOE^JOHN\DOE^JANE`, string(name.data))
+	dcm.GetElement(pixelDataTag, &pixelData)
+	assert.NotEqual(t, make([]byte, len(pixelData.data)), pixelData.data)
+
+	// the redacted dicom can be written and parsed
+	var buf bytes.Buffer
+	assert.NoError(t, redacted.Write(&buf))
+	written := buf.Bytes()
+	reparsed, err := FromReader(&buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, redacted.Len(), reparsed.Len())
+	}
+
+	// the elements of a truncated dicom, read before the failure, can be redacted
+	partial, err := FromReader(bytes.NewReader(written[:len(written)-1]))
+	assert.Error(t, err)
+	assert.NotZero(t, partial.Len())
+	assert.True(t, partial.HasElement(sopInstanceUIDTag))
+	redacted = partial.Redact()
+	redacted.GetElementValue(sopInstanceUIDTag, &uid)
+	assert.Regexp(t, `^[9.]+$`, uid)
+}
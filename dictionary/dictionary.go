@@ -4214,6 +4214,8 @@ var DicomDictionary = map[uint32]*DictEntry{
 	0x54001010: {Tag: 0x54001010, Name: "WaveformData", NameHuman: "Waveform Data", VR: "OB", VM: "1", Retired: false},
 	0x56000010: {Tag: 0x56000010, Name: "FirstOrderPhaseCorrectionAngle", NameHuman: "First Order Phase Correction Angle", VR: "OF", VM: "1", Retired: false},
 	0x56000020: {Tag: 0x56000020, Name: "SpectroscopyData", NameHuman: "Spectroscopy Data", VR: "OF", VM: "1", Retired: false},
+	0x7FE00001: {Tag: 0x7FE00001, Name: "ExtendedOffsetTable", NameHuman: "Extended Offset Table", VR: "OV", VM: "1", Retired: false},
+	0x7FE00002: {Tag: 0x7FE00002, Name: "ExtendedOffsetTableLengths", NameHuman: "Extended Offset Table Lengths", VR: "OV", VM: "1", Retired: false},
 	0x7FE00008: {Tag: 0x7FE00008, Name: "FloatPixelData", NameHuman: "Float Pixel Data", VR: "OF", VM: "1", Retired: false},
 	0x7FE00009: {Tag: 0x7FE00009, Name: "DoubleFloatPixelData", NameHuman: "Double Float Pixel Data", VR: "OD", VM: "1", Retired: false},
 	0x7FE00010: {Tag: 0x7FE00010, Name: "PixelData", NameHuman: "Pixel Data", VR: "OB", VM: "1", Retired: false},
@@ -0,0 +1,23 @@
+package dictionary
+
+// VRForTag returns the value representation of `tag`, as given by DicomDictionary,
+// without the need to construct an element.
+// Its return value (bool) indicates whether `tag` is in the dictionary.
+func VRForTag(tag uint32) (string, bool) {
+	entry, found := DicomDictionary[tag]
+	if !found {
+		return "", false
+	}
+	return entry.VR, true
+}
+
+// VMForTag returns the value multiplicity of `tag`, such as "1", "2-n" or "3-3n", as given by
+// DicomDictionary, without the need to construct an element.
+// Its return value (bool) indicates whether `tag` is in the dictionary.
+func VMForTag(tag uint32) (string, bool) {
+	entry, found := DicomDictionary[tag]
+	if !found {
+		return "", false
+	}
+	return entry.VM, true
+}
@@ -0,0 +1,28 @@
+package dictionary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVRForTag(t *testing.T) {
+	t.Parallel()
+	vr, found := VRForTag(0x00100010) // PatientName
+	assert.True(t, found)
+	assert.Equal(t, "PN", vr)
+	_, found = VRForTag(0x00091001) // private
+	assert.False(t, found)
+}
+
+func TestVMForTag(t *testing.T) {
+	t.Parallel()
+	vm, found := VMForTag(0x00280030) // PixelSpacing
+	assert.True(t, found)
+	assert.Equal(t, "2", vm)
+	vm, found = VMForTag(0x00080008) // ImageType
+	assert.True(t, found)
+	assert.Equal(t, "2-n", vm)
+	_, found = VMForTag(0x00091001)
+	assert.False(t, found)
+}
@@ -0,0 +1,1475 @@
+package opendcm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+===============================================================================
+	IOD Helpers
+	---
+	Provides accessors for information which is specific to particular
+	Information Object Definitions (IODs), such as Segmentation.
+===============================================================================
+*/
+
+const (
+	// SharedFunctionalGroupsSequence (5200,9229)
+	sharedFunctionalGroupsTag = uint32(0x52009229)
+
+	// PerFrameFunctionalGroupsSequence (5200,9230)
+	perFrameFunctionalGroupsTag = uint32(0x52009230)
+
+	// SegmentIdentificationSequence (0062,000A)
+	segmentIdentificationTag = uint32(0x0062000A)
+
+	// ReferencedSegmentNumber (0062,000B)
+	referencedSegmentNumberTag = uint32(0x0062000B)
+
+	// DerivationImageSequence (0008,9124)
+	derivationImageTag = uint32(0x00089124)
+
+	// SourceImageSequence (0008,2112)
+	sourceImageTag = uint32(0x00082112)
+
+	// ReferencedFrameNumber (0008,1160)
+	referencedFrameNumberTag = uint32(0x00081160)
+
+	// MediaStorageSOPInstanceUID (0002,0003)
+	mediaStorageSOPInstanceUIDTag = uint32(0x00020003)
+
+	// SOPClassUID (0008,0016)
+	sopClassUIDTag = uint32(0x00080016)
+
+	// SOPInstanceUID (0008,0018)
+	sopInstanceUIDTag = uint32(0x00080018)
+
+	// StudyInstanceUID (0020,000D)
+	studyInstanceUIDTag = uint32(0x0020000D)
+
+	// SeriesInstanceUID (0020,000E)
+	seriesInstanceUIDTag = uint32(0x0020000E)
+
+	// NumberOfFrames (0028,0008)
+	numberOfFramesTag = uint32(0x00280008)
+
+	// PixelMeasuresSequence (0028,9110)
+	pixelMeasuresTag = uint32(0x00289110)
+
+	// PlanePositionSequence (0020,9113)
+	planePositionTag = uint32(0x00209113)
+
+	// PlaneOrientationSequence (0020,9116)
+	planeOrientationTag = uint32(0x00209116)
+
+	// ImagePositionPatient (0020,0032)
+	imagePositionPatientTag = uint32(0x00200032)
+
+	// ImageOrientationPatient (0020,0037)
+	imageOrientationPatientTag = uint32(0x00200037)
+
+	// SliceThickness (0018,0050)
+	sliceThicknessTag = uint32(0x00180050)
+
+	// PixelSpacing (0028,0030)
+	pixelSpacingTag = uint32(0x00280030)
+
+	// FrameIncrementPointer (0028,0009)
+	frameIncrementPointerTag = uint32(0x00280009)
+
+	// FrameTime (0018,1063)
+	frameTimeTag = uint32(0x00181063)
+
+	// FrameTimeVector (0018,1065)
+	frameTimeVectorTag = uint32(0x00181065)
+
+	// FrameContentSequence (0020,9111)
+	frameContentTag = uint32(0x00209111)
+
+	// DimensionIndexValues (0020,9157)
+	dimensionIndexValuesTag = uint32(0x00209157)
+
+	// DimensionIndexSequence (0020,9222)
+	dimensionIndexSequenceTag = uint32(0x00209222)
+)
+
+// firstItem writes the first item of the sequence indexed by `tag` into `dst`.
+// its return value indicates whether the DataSet contains said sequence with at least one item.
+func (ds *DataSet) firstItem(tag uint32, dst *Item) bool {
+	e := NewElement()
+	if !ds.GetElement(tag, &e) || !e.HasItems() {
+		return false
+	}
+	*dst = e.items[0]
+	return true
+}
+
+// getUID returns the value of the UI element indexed by `tag`.
+// An error is returned if the element is missing, empty, or not of VR UI.
+func (ds *DataSet) getUID(tag uint32) (string, error) {
+	e := NewElementWithTag(tag)
+	if !ds.GetElement(tag, &e) {
+		return "", fmt.Errorf("%s is missing", e.dictEntry)
+	}
+	if e.GetVR() != "UI" {
+		return "", fmt.Errorf("%s has VR %s; expected UI", e.dictEntry, e.GetVR())
+	}
+	uid := ""
+	if err := e.GetValue(&uid); err != nil {
+		return "", err
+	}
+	if uid = strings.TrimRight(uid, "\x00 "); uid == "" {
+		return "", fmt.Errorf("%s is empty", e.dictEntry)
+	}
+	return uid, nil
+}
+
+// Identifiers returns the four UIDs which identify the SOP Instance within the
+// Study / Series hierarchy: (0020,000D) StudyInstanceUID, (0020,000E) SeriesInstanceUID,
+// (0008,0018) SOPInstanceUID and (0008,0016) SOPClassUID.
+// An error is returned if any of these are missing or invalid.
+func (dcm *Dicom) Identifiers() (studyUID, seriesUID, sopInstanceUID, sopClassUID string, err error) {
+	if studyUID, err = dcm.getUID(studyInstanceUIDTag); err != nil {
+		return
+	}
+	if seriesUID, err = dcm.getUID(seriesInstanceUIDTag); err != nil {
+		return
+	}
+	if sopInstanceUID, err = dcm.getUID(sopInstanceUIDTag); err != nil {
+		return
+	}
+	sopClassUID, err = dcm.getUID(sopClassUIDTag)
+	return
+}
+
+// parseIntegerStrings parses each value of an IS (integer string) element.
+func parseIntegerStrings(values []string) ([]int, error) {
+	ints := make([]int, 0, len(values))
+	for _, v := range values {
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
+
+// SegmentFrames returns, for a Segmentation object, a mapping between each segment
+// number and the (1-based) source image frame numbers to which it applies.
+//
+// This is determined by walking the Per-frame Functional Groups Sequence. Where a
+// frame does not reference a source frame via (0008,1160) ReferencedFrameNumber,
+// the segmentation's own frame number is used instead.
+func (dcm *Dicom) SegmentFrames() (map[int][]int, error) {
+	perFrame := NewElement()
+	if !dcm.GetElement(perFrameFunctionalGroupsTag, &perFrame) {
+		return nil, errors.New("SegmentFrames(): dicom does not contain a Per-frame Functional Groups Sequence")
+	}
+	segments := make(map[int][]int)
+	for i, frameItem := range perFrame.GetItems() {
+		segID := Item{}
+		if !frameItem.dataset.firstItem(segmentIdentificationTag, &segID) {
+			continue
+		}
+		segNumbers := []uint16{}
+		if _, err := segID.dataset.GetElementValue(referencedSegmentNumberTag, &segNumbers); err != nil {
+			return nil, err
+		}
+		frameNumbers := []int{i + 1}
+		derivation, source := Item{}, Item{}
+		if frameItem.dataset.firstItem(derivationImageTag, &derivation) && derivation.dataset.firstItem(sourceImageTag, &source) {
+			values := []string{}
+			found, err := source.dataset.GetElementValue(referencedFrameNumberTag, &values)
+			if err != nil {
+				return nil, err
+			}
+			if found && len(values) > 0 {
+				if frameNumbers, err = parseIntegerStrings(values); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, segNumber := range segNumbers {
+			segments[int(segNumber)] = append(segments[int(segNumber)], frameNumbers...)
+		}
+	}
+	return segments, nil
+}
+
+// newElementWithData returns an element indexed by `tag` with value `data`,
+// padded to an even length as appropriate for its VR.
+func newElementWithData(tag uint32, data []byte) Element {
+	e := NewElementWithTag(tag)
+	if len(data)%2 != 0 {
+		data = append(data, paddingForVR(e.GetVR()))
+	}
+	e.data = data
+	e.datalen = uint32(len(data))
+	return e
+}
+
+// mergeFunctionalGroups copies the contents of each functional group macro within `groups`
+// (e.g. Plane Position Sequence) into `dst`, such that they become top-level elements.
+func mergeFunctionalGroups(groups DataSet, dst DataSet) {
+	for _, group := range groups {
+		for _, itm := range group.GetItems() {
+			for _, e := range itm.dataset {
+				dst.addElement(e)
+			}
+		}
+	}
+}
+
+// SplitFrames splits a multi-frame dicom into one single-frame dicom per frame.
+//
+// Each resulting dicom has (0028,0008) NumberOfFrames set to 1, a newly generated
+// SOPInstanceUID, and PixelData containing only its frame. The shared and per-frame
+// functional groups (if any) are merged into the top-level data set, with per-frame
+// values taking precedence.
+func (dcm *Dicom) SplitFrames() ([]Dicom, error) {
+	pm, err := dcm.GetPixelModule()
+	if err != nil {
+		return nil, err
+	}
+	native := isNativeTransferSyntax(dcm.GetTransferSyntax())
+	if native {
+		if dcm.pixelData.NumFrames() == 0 || len(dcm.pixelData.GetFrame(0)) < pm.NumberOfFrames*pm.FrameSize() {
+			return nil, errors.New("SplitFrames(): native pixel data is missing or truncated")
+		}
+	} else if dcm.pixelData.NumFrames() != pm.NumberOfFrames {
+		return nil, fmt.Errorf("SplitFrames(): expected %d frames but pixel data contains %d", pm.NumberOfFrames, dcm.pixelData.NumFrames())
+	}
+
+	shared, perFrame := Item{}, NewElement()
+	dcm.firstItem(sharedFunctionalGroupsTag, &shared)
+	dcm.GetElement(perFrameFunctionalGroupsTag, &perFrame)
+
+	dicoms := make([]Dicom, 0, pm.NumberOfFrames)
+	for i := 0; i < pm.NumberOfFrames; i++ {
+		single := newDicom()
+		single.preamble = dcm.preamble
+		for _, e := range dcm.DataSet {
+			switch e.GetTag() {
+			case sharedFunctionalGroupsTag, perFrameFunctionalGroupsTag:
+				continue
+			}
+			single.addElement(e)
+		}
+		mergeFunctionalGroups(shared.dataset, single.DataSet)
+		if i < len(perFrame.items) {
+			mergeFunctionalGroups(perFrame.items[i].dataset, single.DataSet)
+		}
+
+		uid, err := NewRandInstanceUID()
+		if err != nil {
+			return nil, err
+		}
+		single.addElement(newElementWithData(sopInstanceUIDTag, []byte(uid)))
+		if single.HasElement(mediaStorageSOPInstanceUIDTag) {
+			single.addElement(newElementWithData(mediaStorageSOPInstanceUIDTag, []byte(uid)))
+		}
+		single.addElement(newElementWithData(numberOfFramesTag, []byte("1")))
+
+		var frame []byte
+		pixelData := NewElementWithTag(pixelDataTag)
+		dcm.GetElement(pixelDataTag, &pixelData)
+		if native {
+			frame = dcm.pixelData.GetFrame(0)[i*pm.FrameSize() : (i+1)*pm.FrameSize()]
+			pixelData.data, pixelData.datalen, pixelData.items = frame, uint32(len(frame)), nil
+		} else {
+			// encapsulated frames are preceded by an empty basic offset table
+			frame = dcm.pixelData.GetFrame(i)
+			pixelData.data, pixelData.items = nil, []Item{{}, {fragment: frame}}
+		}
+		single.addElement(pixelData)
+		single.pixelData.addFrame(frame, !native)
+		dicoms = append(dicoms, single)
+	}
+	return dicoms, nil
+}
+
+// parseDecimalStrings parses each value of a DS (decimal string) element.
+func parseDecimalStrings(values []string) ([]float64, error) {
+	floats := make([]float64, 0, len(values))
+	for _, v := range values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, err
+		}
+		floats = append(floats, f)
+	}
+	return floats, nil
+}
+
+// getDecimalStrings writes the values of the DS element indexed by `tag` into `dst`.
+// An error is returned if the element does not contain exactly len(dst) values.
+func (ds *DataSet) getDecimalStrings(tag uint32, dst []float64) error {
+	e := NewElementWithTag(tag)
+	ds.GetElement(tag, &e)
+	values := []string{}
+	if err := e.GetValue(&values); err != nil {
+		return err
+	}
+	if len(values) != len(dst) {
+		return fmt.Errorf("%s has %d values; expected %d", e.dictEntry, len(values), len(dst))
+	}
+	floats, err := parseDecimalStrings(values)
+	if err != nil {
+		return err
+	}
+	copy(dst, floats)
+	return nil
+}
+
+// getFrameDecimalStrings writes the values of the DS element indexed by `tag` into `dst`, looking
+// within the functional group macro `macroTag` of each of `groups` in turn, and then the top-level
+// data set. its return value (bool) indicates whether said element was found.
+func (dcm *Dicom) getFrameDecimalStrings(groups []DataSet, macroTag, tag uint32, dst []float64) (bool, error) {
+	for _, group := range groups {
+		macro := Item{}
+		if group.firstItem(macroTag, &macro) && macro.dataset.HasElement(tag) {
+			return true, macro.dataset.getDecimalStrings(tag, dst)
+		}
+	}
+	if dcm.HasElement(tag) {
+		return true, dcm.getDecimalStrings(tag, dst)
+	}
+	return false, nil
+}
+
+// getNumberOfFrames returns the value of (0028,0008) NumberOfFrames, or 1 if absent.
+func (ds *DataSet) getNumberOfFrames() (int, error) {
+	values := []string{}
+	if found, _ := ds.GetElementValue(numberOfFramesTag, &values); !found || len(values) == 0 {
+		return 1, nil
+	}
+	return strconv.Atoi(strings.TrimSpace(values[0]))
+}
+
+// SliceGeometry returns the geometry of frame `frame` (0-based): (0020,0032) ImagePositionPatient,
+// (0020,0037) ImageOrientationPatient, (0018,0050) SliceThickness and (0028,0030) PixelSpacing.
+//
+// For multi-frame dicoms, these are taken from the Plane Position, Plane Orientation and
+// Pixel Measures macros of the per-frame functional groups, then the shared functional groups.
+// Otherwise, the top-level attributes are used. An error is returned if the position or
+// orientation cannot be found; a missing thickness or spacing is left as zero.
+func (dcm *Dicom) SliceGeometry(frame int) (position [3]float64, orientation [6]float64, thickness float64, spacing [2]float64, err error) {
+	groups := make([]DataSet, 0, 2)
+	numFrames := 1
+	perFrame, shared := NewElement(), Item{}
+	if dcm.GetElement(perFrameFunctionalGroupsTag, &perFrame) {
+		numFrames = len(perFrame.items)
+		if frame >= 0 && frame < numFrames {
+			groups = append(groups, perFrame.items[frame].dataset)
+		}
+	} else if numFrames, err = dcm.getNumberOfFrames(); err != nil {
+		return
+	}
+	if frame < 0 || frame >= numFrames {
+		err = fmt.Errorf("SliceGeometry(%d): dicom has %d frames", frame, numFrames)
+		return
+	}
+	if dcm.firstItem(sharedFunctionalGroupsTag, &shared) {
+		groups = append(groups, shared.dataset)
+	}
+
+	found := false
+	if found, err = dcm.getFrameDecimalStrings(groups, planePositionTag, imagePositionPatientTag, position[:]); err != nil {
+		return
+	} else if !found {
+		err = fmt.Errorf("SliceGeometry(%d): ImagePositionPatient is missing", frame)
+		return
+	}
+	if found, err = dcm.getFrameDecimalStrings(groups, planeOrientationTag, imageOrientationPatientTag, orientation[:]); err != nil {
+		return
+	} else if !found {
+		err = fmt.Errorf("SliceGeometry(%d): ImageOrientationPatient is missing", frame)
+		return
+	}
+	thicknesses := []float64{0}
+	if _, err = dcm.getFrameDecimalStrings(groups, pixelMeasuresTag, sliceThicknessTag, thicknesses); err != nil {
+		return
+	}
+	thickness = thicknesses[0]
+	_, err = dcm.getFrameDecimalStrings(groups, pixelMeasuresTag, pixelSpacingTag, spacing[:])
+	return
+}
+
+// FrameTimes returns the time of each frame of a multi-frame (e.g. cine) dicom, in milliseconds
+// relative to the first frame. The timing element is that referenced by (0028,0009)
+// FrameIncrementPointer: either (0018,1063) FrameTime, the fixed interval between frames,
+// or (0018,1065) FrameTimeVector, the interval preceding each frame.
+func (dcm *Dicom) FrameTimes() ([]float64, error) {
+	numFrames, err := dcm.getNumberOfFrames()
+	if err != nil {
+		return nil, err
+	}
+	pointers, err := dcm.GetReferencedTags(frameIncrementPointerTag)
+	if err != nil {
+		return nil, err
+	}
+	for _, pointer := range pointers {
+		if !dcm.HasElement(pointer) {
+			continue
+		}
+		times := make([]float64, numFrames)
+		switch pointer {
+		case frameTimeTag:
+			frameTime := []float64{0}
+			if err = dcm.getDecimalStrings(frameTimeTag, frameTime); err != nil {
+				return nil, err
+			}
+			for i := range times {
+				times[i] = float64(i) * frameTime[0]
+			}
+			return times, nil
+		case frameTimeVectorTag:
+			intervals := make([]float64, numFrames)
+			if err = dcm.getDecimalStrings(frameTimeVectorTag, intervals); err != nil {
+				return nil, err
+			}
+			// the first interval is zero, and each subsequent one is relative to the previous frame
+			for i := 1; i < numFrames; i++ {
+				times[i] = times[i-1] + intervals[i]
+			}
+			return times, nil
+		}
+	}
+	return nil, errors.New("FrameTimes(): FrameIncrementPointer does not reference a present FrameTime or FrameTimeVector")
+}
+
+// FrameDimensionIndex returns the (0020,9157) DimensionIndexValues of frame `frame` (0-based),
+// as found within the Frame Content macro of the per-frame functional groups. There is one
+// value per dimension, in the order defined by (0020,9222) DimensionIndexSequence.
+func (dcm *Dicom) FrameDimensionIndex(frame int) ([]uint32, error) {
+	perFrame := NewElement()
+	if !dcm.GetElement(perFrameFunctionalGroupsTag, &perFrame) {
+		return nil, fmt.Errorf("FrameDimensionIndex(%d): dicom does not contain a Per-frame Functional Groups Sequence", frame)
+	}
+	if frame < 0 || frame >= len(perFrame.items) {
+		return nil, fmt.Errorf("FrameDimensionIndex(%d): dicom has %d frames", frame, len(perFrame.items))
+	}
+	content := Item{}
+	if !perFrame.items[frame].dataset.firstItem(frameContentTag, &content) {
+		return nil, fmt.Errorf("FrameDimensionIndex(%d): frame does not contain a Frame Content Sequence", frame)
+	}
+	values := make([]uint32, 0)
+	if found, err := content.dataset.GetElementValue(dimensionIndexValuesTag, &values); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("FrameDimensionIndex(%d): DimensionIndexValues is missing", frame)
+	}
+	dimensions := NewElement()
+	if dcm.GetElement(dimensionIndexSequenceTag, &dimensions) && len(dimensions.items) != len(values) {
+		return nil, fmt.Errorf("FrameDimensionIndex(%d): frame has %d index values, but %d dimensions are defined", frame, len(values), len(dimensions.items))
+	}
+	return values, nil
+}
+
+// FramesInDimensionOrder returns the (0-based) frame numbers of a multi-frame dicom, sorted by
+// their dimension index values, such that they are in the logical order of the volume (e.g.
+// by stack, then temporal position, then in-stack position) rather than the order of the file.
+func (dcm *Dicom) FramesInDimensionOrder() ([]int, error) {
+	perFrame := NewElement()
+	if !dcm.GetElement(perFrameFunctionalGroupsTag, &perFrame) {
+		return nil, errors.New("FramesInDimensionOrder(): dicom does not contain a Per-frame Functional Groups Sequence")
+	}
+	frames := make([]int, len(perFrame.items))
+	indices := make([][]uint32, len(perFrame.items))
+	for i := range frames {
+		index, err := dcm.FrameDimensionIndex(i)
+		if err != nil {
+			return nil, err
+		}
+		frames[i], indices[i] = i, index
+	}
+	sort.SliceStable(frames, func(a, b int) bool {
+		ia, ib := indices[frames[a]], indices[frames[b]]
+		for d := 0; d < len(ia) && d < len(ib); d++ {
+			if ia[d] != ib[d] {
+				return ia[d] < ib[d]
+			}
+		}
+		return len(ia) < len(ib)
+	})
+	return frames, nil
+}
+
+/*
+===============================================================================
+	Volumes
+	---
+	Provides reconstruction of a 3D volume from the single-frame images of
+	a series, such as a CT or MR acquisition.
+===============================================================================
+*/
+
+// Volume contains the voxels of a series of parallel, equally spaced slices.
+type Volume struct {
+	Columns int
+	Rows    int
+	Slices  int
+	// Origin is the position (in mm) of the centre of the first voxel, in the patient coordinate system
+	Origin [3]float64
+	// Orientation holds the direction cosines of the rows and columns, as per ImageOrientationPatient
+	Orientation [6]float64
+	// Spacing is the distance (in mm) between the centres of adjacent columns, rows and slices
+	Spacing [3]float64
+	// Voxels holds the samples slice by slice, then row by row: []int16 if signed, otherwise []uint16
+	Voxels interface{}
+}
+
+// volumeSlice holds the attributes of one image of a volume, as used to sort and validate them.
+type volumeSlice struct {
+	dcm      *Dicom
+	pm       PixelModule
+	position [3]float64
+	spacing  [2]float64
+	distance float64
+}
+
+// appendSamples widens the first `n` native samples of `dcm` to 16 bits, appending them to `voxels`.
+func appendSamples(dcm *Dicom, pm PixelModule, n int, voxels interface{}) (interface{}, error) {
+	samples, err := dcm.GetPixelData().Samples(pm.BitsAllocated, pm.PixelRepresentation)
+	if err != nil {
+		return nil, err
+	}
+	switch typed := samples.(type) {
+	case []uint8:
+		if len(typed) >= n {
+			for _, v := range typed[:n] {
+				voxels = append(voxels.([]uint16), uint16(v))
+			}
+			return voxels, nil
+		}
+	case []int8:
+		if len(typed) >= n {
+			for _, v := range typed[:n] {
+				voxels = append(voxels.([]int16), int16(v))
+			}
+			return voxels, nil
+		}
+	case []uint16:
+		if len(typed) >= n {
+			return append(voxels.([]uint16), typed[:n]...), nil
+		}
+	case []int16:
+		if len(typed) >= n {
+			return append(voxels.([]int16), typed[:n]...), nil
+		}
+	default:
+		return nil, ErrUnsupportedPixelEncoding
+	}
+	return nil, fmt.Errorf("pixel data contains fewer than %d samples", n)
+}
+
+// BuildVolume reconstructs a volume from `files`, each a single-frame image of the same series.
+// The images are sorted by the projection of (0020,0032) ImagePositionPatient onto the slice
+// normal, which is derived from (0020,0037) ImageOrientationPatient. An error is returned unless
+// the images share their dimensions, orientation, pixel spacing and sample encoding, and are
+// equally spaced. Only native pixel data of 8 or 16 bits allocated is supported.
+func BuildVolume(files []Dicom) (*Volume, error) {
+	if len(files) == 0 {
+		return nil, errors.New("BuildVolume(): no files given")
+	}
+	vol := Volume{}
+	slices := make([]volumeSlice, len(files))
+	for i := range files {
+		slice := volumeSlice{dcm: &files[i]}
+		var err error
+		var orientation [6]float64
+		if slice.pm, err = files[i].GetPixelModule(); err != nil {
+			return nil, fmt.Errorf("BuildVolume(): file %d: %v", i, err)
+		}
+		if slice.pm.NumberOfFrames != 1 {
+			return nil, fmt.Errorf("BuildVolume(): file %d has %d frames; expected 1", i, slice.pm.NumberOfFrames)
+		}
+		if slice.position, orientation, _, slice.spacing, err = files[i].SliceGeometry(0); err != nil {
+			return nil, fmt.Errorf("BuildVolume(): file %d: %v", i, err)
+		}
+		if i == 0 {
+			vol.Orientation = orientation
+		} else if orientation != vol.Orientation {
+			return nil, fmt.Errorf("BuildVolume(): file %d has orientation %v; expected %v", i, orientation, vol.Orientation)
+		}
+		first := slices[0]
+		if i > 0 && (slice.pm.Rows != first.pm.Rows || slice.pm.Columns != first.pm.Columns ||
+			slice.pm.BitsAllocated != first.pm.BitsAllocated || slice.pm.PixelRepresentation != first.pm.PixelRepresentation) {
+			return nil, fmt.Errorf("BuildVolume(): file %d has pixel module %+v; expected %+v", i, slice.pm, first.pm)
+		}
+		if i > 0 && slice.spacing != first.spacing {
+			return nil, fmt.Errorf("BuildVolume(): file %d has pixel spacing %v; expected %v", i, slice.spacing, first.spacing)
+		}
+		slices[i] = slice
+	}
+
+	// the slice normal is the cross product of the row and column direction cosines
+	o := vol.Orientation
+	normal := [3]float64{o[1]*o[5] - o[2]*o[4], o[2]*o[3] - o[0]*o[5], o[0]*o[4] - o[1]*o[3]}
+	for i := range slices {
+		p := slices[i].position
+		slices[i].distance = p[0]*normal[0] + p[1]*normal[1] + p[2]*normal[2]
+	}
+	sort.SliceStable(slices, func(a, b int) bool { return slices[a].distance < slices[b].distance })
+
+	first, last := slices[0], slices[len(slices)-1]
+	if len(slices) > 1 {
+		vol.Spacing[2] = (last.distance - first.distance) / float64(len(slices)-1)
+		if vol.Spacing[2] == 0 {
+			return nil, errors.New("BuildVolume(): images share the same position")
+		}
+		for i := 1; i < len(slices); i++ {
+			if gap := slices[i].distance - slices[i-1].distance; math.Abs(gap-vol.Spacing[2]) > 0.01*vol.Spacing[2] {
+				return nil, fmt.Errorf("BuildVolume(): slices are not equally spaced; found gap of %gmm, expected %gmm", gap, vol.Spacing[2])
+			}
+		}
+	}
+	// PixelSpacing is given as the row spacing (between rows), then the column spacing
+	vol.Spacing[0], vol.Spacing[1] = first.spacing[1], first.spacing[0]
+	vol.Columns, vol.Rows, vol.Slices = first.pm.Columns, first.pm.Rows, len(slices)
+	vol.Origin = first.position
+
+	n := vol.Rows * vol.Columns
+	var voxels interface{} = make([]uint16, 0, n*vol.Slices)
+	if first.pm.PixelRepresentation == 1 {
+		voxels = make([]int16, 0, n*vol.Slices)
+	}
+	for i, slice := range slices {
+		var err error
+		if voxels, err = appendSamples(slice.dcm, slice.pm, n, voxels); err != nil {
+			return nil, fmt.Errorf("BuildVolume(): slice %d: %v", i, err)
+		}
+	}
+	vol.Voxels = voxels
+	return &vol, nil
+}
+
+/*
+===============================================================================
+	Structured Reports
+	---
+	Provides a tree representation of the content of Structured Report (SR)
+	documents, as per http://dicom.nema.org/dicom/2013/output/chtml/part03/sect_C.17.3.html
+===============================================================================
+*/
+
+const (
+	// ContentSequence (0040,A730)
+	contentSequenceTag = uint32(0x0040A730)
+
+	// RelationshipType (0040,A010)
+	relationshipTypeTag = uint32(0x0040A010)
+
+	// ValueType (0040,A040)
+	valueTypeTag = uint32(0x0040A040)
+
+	// ConceptNameCodeSequence (0040,A043)
+	conceptNameCodeSequenceTag = uint32(0x0040A043)
+
+	// ConceptCodeSequence (0040,A168)
+	conceptCodeSequenceTag = uint32(0x0040A168)
+
+	// MeasuredValueSequence (0040,A300)
+	measuredValueSequenceTag = uint32(0x0040A300)
+
+	// MeasurementUnitsCodeSequence (0040,08EA)
+	measurementUnitsCodeSequenceTag = uint32(0x004008EA)
+
+	// NumericValue (0040,A30A)
+	numericValueTag = uint32(0x0040A30A)
+
+	// TextValue (0040,A160)
+	textValueTag = uint32(0x0040A160)
+
+	// DateTime (0040,A120)
+	dateTimeTag = uint32(0x0040A120)
+
+	// Date (0040,A121)
+	dateTag = uint32(0x0040A121)
+
+	// Time (0040,A122)
+	timeTag = uint32(0x0040A122)
+
+	// PersonName (0040,A123)
+	personNameTag = uint32(0x0040A123)
+
+	// UID (0040,A124)
+	uidTag = uint32(0x0040A124)
+
+	// CodeValue (0008,0100)
+	codeValueTag = uint32(0x00080100)
+
+	// CodingSchemeDesignator (0008,0102)
+	codingSchemeDesignatorTag = uint32(0x00080102)
+
+	// CodeMeaning (0008,0104)
+	codeMeaningTag = uint32(0x00080104)
+)
+
+// SRCode represents a coded entry, as found within a code sequence.
+type SRCode struct {
+	CodeValue              string
+	CodingSchemeDesignator string
+	CodeMeaning            string
+}
+
+// SRContentItem represents one node of a structured report's content tree.
+// Which of the value fields are populated depends upon `ValueType`:
+// TEXT populates `TextValue`, NUM populates `NumericValue` and `Units`, CODE populates `Code`,
+// DATETIME, DATE, TIME, PNAME and UIDREF populate `TextValue` with the literal value.
+type SRContentItem struct {
+	RelationshipType string
+	ValueType        string
+	ConceptName      *SRCode
+	TextValue        string
+	NumericValue     float64
+	Units            *SRCode
+	Code             *SRCode
+	Children         []SRContentItem
+}
+
+// SRDocument represents the content tree of a structured report.
+// The root item is the document's top-level CONTAINER.
+type SRDocument struct {
+	Root SRContentItem
+}
+
+// getString returns the trimmed string value indexed by `tag`, or "" if absent.
+func (ds *DataSet) getString(tag uint32) (string, error) {
+	val := ""
+	if _, err := ds.GetElementValue(tag, &val); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(val), nil
+}
+
+// getCode returns the first coded entry of the code sequence indexed by `tag`, or nil if absent.
+func (ds *DataSet) getCode(tag uint32) (*SRCode, error) {
+	itm := Item{}
+	if !ds.firstItem(tag, &itm) {
+		return nil, nil
+	}
+	code := SRCode{}
+	var err error
+	for dst, codeTag := range map[*string]uint32{
+		&code.CodeValue:              codeValueTag,
+		&code.CodingSchemeDesignator: codingSchemeDesignatorTag,
+		&code.CodeMeaning:            codeMeaningTag,
+	} {
+		if *dst, err = itm.dataset.getString(codeTag); err != nil {
+			return nil, err
+		}
+	}
+	return &code, nil
+}
+
+// getMeasuredValue returns the numeric value and units contained within the (0040,A300) MeasuredValueSequence.
+func (ds *DataSet) getMeasuredValue() (float64, *SRCode, error) {
+	measured := Item{}
+	if !ds.firstItem(measuredValueSequenceTag, &measured) {
+		return 0, nil, nil
+	}
+	value, err := measured.dataset.getString(numericValueTag)
+	if err != nil {
+		return 0, nil, err
+	}
+	num, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	units, err := measured.dataset.getCode(measurementUnitsCodeSequenceTag)
+	return num, units, err
+}
+
+// newSRContentItem decodes the content item contained within `ds`, along with its children.
+func newSRContentItem(ds DataSet) (item SRContentItem, err error) {
+	if item.RelationshipType, err = ds.getString(relationshipTypeTag); err != nil {
+		return
+	}
+	if item.ValueType, err = ds.getString(valueTypeTag); err != nil {
+		return
+	}
+	if item.ConceptName, err = ds.getCode(conceptNameCodeSequenceTag); err != nil {
+		return
+	}
+	switch item.ValueType {
+	case "TEXT":
+		item.TextValue, err = ds.getString(textValueTag)
+	case "DATETIME":
+		item.TextValue, err = ds.getString(dateTimeTag)
+	case "DATE":
+		item.TextValue, err = ds.getString(dateTag)
+	case "TIME":
+		item.TextValue, err = ds.getString(timeTag)
+	case "PNAME":
+		item.TextValue, err = ds.getString(personNameTag)
+	case "UIDREF":
+		item.TextValue, err = ds.getString(uidTag)
+	case "CODE":
+		item.Code, err = ds.getCode(conceptCodeSequenceTag)
+	case "NUM":
+		item.NumericValue, item.Units, err = ds.getMeasuredValue()
+	}
+	if err != nil {
+		return
+	}
+	content := NewElement()
+	if ds.GetElement(contentSequenceTag, &content) {
+		for _, child := range content.GetItems() {
+			childItem, err := newSRContentItem(child.dataset)
+			if err != nil {
+				return item, err
+			}
+			item.Children = append(item.Children, childItem)
+		}
+	}
+	return item, nil
+}
+
+// StructuredReport decodes the content tree of a Structured Report document,
+// by recursively walking its (0040,A730) ContentSequence.
+func (dcm *Dicom) StructuredReport() (*SRDocument, error) {
+	if !dcm.HasElement(contentSequenceTag) {
+		return nil, errors.New("StructuredReport(): dicom does not contain a Content Sequence")
+	}
+	root, err := newSRContentItem(dcm.DataSet)
+	if err != nil {
+		return nil, err
+	}
+	return &SRDocument{Root: root}, nil
+}
+
+/*
+===============================================================================
+	Presentation States
+	---
+	Provides access to the annotations of Softcopy Presentation State objects,
+	as per http://dicom.nema.org/dicom/2013/output/chtml/part03/sect_C.10.5.html
+===============================================================================
+*/
+
+const (
+	// GraphicAnnotationSequence (0070,0001)
+	graphicAnnotationSequenceTag = uint32(0x00700001)
+
+	// GraphicLayer (0070,0002)
+	graphicLayerTag = uint32(0x00700002)
+
+	// BoundingBoxAnnotationUnits (0070,0003)
+	boundingBoxAnnotationUnitsTag = uint32(0x00700003)
+
+	// AnchorPointAnnotationUnits (0070,0004)
+	anchorPointAnnotationUnitsTag = uint32(0x00700004)
+
+	// GraphicAnnotationUnits (0070,0005)
+	graphicAnnotationUnitsTag = uint32(0x00700005)
+
+	// UnformattedTextValue (0070,0006)
+	unformattedTextValueTag = uint32(0x00700006)
+
+	// TextObjectSequence (0070,0008)
+	textObjectSequenceTag = uint32(0x00700008)
+
+	// GraphicObjectSequence (0070,0009)
+	graphicObjectSequenceTag = uint32(0x00700009)
+
+	// BoundingBoxTopLeftHandCorner (0070,0010)
+	boundingBoxTopLeftHandCornerTag = uint32(0x00700010)
+
+	// BoundingBoxBottomRightHandCorner (0070,0011)
+	boundingBoxBottomRightHandCornerTag = uint32(0x00700011)
+
+	// AnchorPoint (0070,0014)
+	anchorPointTag = uint32(0x00700014)
+
+	// GraphicData (0070,0022)
+	graphicDataTag = uint32(0x00700022)
+
+	// GraphicType (0070,0023)
+	graphicTypeTag = uint32(0x00700023)
+
+	// GraphicFilled (0070,0024)
+	graphicFilledTag = uint32(0x00700024)
+
+	// ReferencedImageSequence (0008,1140)
+	referencedImageSequenceTag = uint32(0x00081140)
+
+	// ReferencedSOPClassUID (0008,1150)
+	referencedSOPClassUIDTag = uint32(0x00081150)
+
+	// ReferencedSOPInstanceUID (0008,1155)
+	referencedSOPInstanceUIDTag = uint32(0x00081155)
+)
+
+// ReferencedImage represents one item of a (0008,1140) ReferencedImageSequence.
+// `FrameNumbers` lists the (0008,1160) ReferencedFrameNumber of a multi-frame image, numbered
+// from 1; if empty, all frames are referenced.
+type ReferencedImage struct {
+	SOPClassUID    string
+	SOPInstanceUID string
+	FrameNumbers   []int
+}
+
+// Annotation represents one text or graphic object of a presentation state's
+// (0070,0001) GraphicAnnotationSequence.
+//
+// `Type` is "TEXT" for text objects, otherwise the (0070,0023) GraphicType of the graphic
+// object (e.g. "POLYLINE"). For graphic objects, `Points` holds the (0070,0022) GraphicData.
+// For text objects, it holds the bounding box's top left and bottom right corners, if present,
+// followed by the anchor point, if present. `Units` is either "PIXEL" (image relative) or
+// "DISPLAY" (fractions of the displayed area).
+type Annotation struct {
+	Type   string
+	Layer  string
+	Units  string
+	Points [][2]float32
+	Text   string
+	Filled bool
+	// ReferencedSOPInstanceUIDs lists the images to which the annotation applies;
+	// if empty, it applies to all images referenced by the presentation state.
+	ReferencedSOPInstanceUIDs []string
+}
+
+// getPoints appends the (column, row) pairs of the FL element indexed by `tag` to `dst`.
+func (ds *DataSet) getPoints(tag uint32, dst [][2]float32) ([][2]float32, error) {
+	values := make([]float32, 0)
+	if _, err := ds.GetElementValue(tag, &values); err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("(%04X,%04X) contains %d values; expected pairs of coordinates", tag>>16, tag&0xFFFF, len(values))
+	}
+	for i := 0; i < len(values); i += 2 {
+		dst = append(dst, [2]float32{values[i], values[i+1]})
+	}
+	return dst, nil
+}
+
+// newTextAnnotation decodes the text object contained within `ds`.
+func newTextAnnotation(ds DataSet) (a Annotation, err error) {
+	a.Type = "TEXT"
+	if a.Text, err = ds.getString(unformattedTextValueTag); err != nil {
+		return
+	}
+	for _, tag := range []uint32{boundingBoxTopLeftHandCornerTag, boundingBoxBottomRightHandCornerTag} {
+		if a.Points, err = ds.getPoints(tag, a.Points); err != nil {
+			return
+		}
+	}
+	unitsTag := boundingBoxAnnotationUnitsTag
+	if len(a.Points) == 0 {
+		unitsTag = anchorPointAnnotationUnitsTag
+	}
+	if a.Units, err = ds.getString(unitsTag); err != nil {
+		return
+	}
+	a.Points, err = ds.getPoints(anchorPointTag, a.Points)
+	return
+}
+
+// newGraphicAnnotation decodes the graphic object contained within `ds`.
+func newGraphicAnnotation(ds DataSet) (a Annotation, err error) {
+	if a.Type, err = ds.getString(graphicTypeTag); err != nil {
+		return
+	}
+	if a.Units, err = ds.getString(graphicAnnotationUnitsTag); err != nil {
+		return
+	}
+	filled := ""
+	if filled, err = ds.getString(graphicFilledTag); err != nil {
+		return
+	}
+	a.Filled = filled == "Y"
+	a.Points, err = ds.getPoints(graphicDataTag, nil)
+	return
+}
+
+// GraphicAnnotations returns the text and graphic objects of a presentation state's
+// (0070,0001) GraphicAnnotationSequence, in the order in which they appear.
+func (dcm *Dicom) GraphicAnnotations() ([]Annotation, error) {
+	seq := NewElement()
+	if !dcm.GetElement(graphicAnnotationSequenceTag, &seq) {
+		return nil, errors.New("GraphicAnnotations(): dicom does not contain a Graphic Annotation Sequence")
+	}
+	annotations := make([]Annotation, 0)
+	for _, itm := range seq.GetItems() {
+		layer, err := itm.dataset.getString(graphicLayerTag)
+		if err != nil {
+			return nil, err
+		}
+		referenced := make([]string, 0)
+		images := NewElement()
+		if itm.dataset.GetElement(referencedImageSequenceTag, &images) {
+			for _, ref := range images.GetItems() {
+				uid, err := ref.dataset.getUID(referencedSOPInstanceUIDTag)
+				if err != nil {
+					return nil, err
+				}
+				referenced = append(referenced, uid)
+			}
+		}
+		for _, objects := range []struct {
+			tag    uint32
+			decode func(DataSet) (Annotation, error)
+		}{
+			{textObjectSequenceTag, newTextAnnotation},
+			{graphicObjectSequenceTag, newGraphicAnnotation},
+		} {
+			e := NewElement()
+			if !itm.dataset.GetElement(objects.tag, &e) {
+				continue
+			}
+			for _, object := range e.GetItems() {
+				a, err := objects.decode(object.dataset)
+				if err != nil {
+					return nil, err
+				}
+				a.Layer = layer
+				a.ReferencedSOPInstanceUIDs = referenced
+				annotations = append(annotations, a)
+			}
+		}
+	}
+	return annotations, nil
+}
+
+// ReferencedImages returns the images referenced by the dicom's (0008,1140) ReferencedImageSequence,
+// as is present in presentation states and key object selection documents, in the order in which
+// they appear.
+func (dcm *Dicom) ReferencedImages() ([]ReferencedImage, error) {
+	seq := NewElement()
+	if !dcm.GetElement(referencedImageSequenceTag, &seq) {
+		return nil, errors.New("ReferencedImages(): dicom does not contain a Referenced Image Sequence")
+	}
+	images := make([]ReferencedImage, 0)
+	for _, itm := range seq.GetItems() {
+		ref := ReferencedImage{}
+		var err error
+		if ref.SOPClassUID, err = itm.dataset.getUID(referencedSOPClassUIDTag); err != nil {
+			return nil, err
+		}
+		if ref.SOPInstanceUID, err = itm.dataset.getUID(referencedSOPInstanceUIDTag); err != nil {
+			return nil, err
+		}
+		values := []string{}
+		if _, err = itm.dataset.GetElementValue(referencedFrameNumberTag, &values); err != nil {
+			return nil, err
+		}
+		if ref.FrameNumbers, err = parseIntegerStrings(values); err != nil {
+			return nil, err
+		}
+		images = append(images, ref)
+	}
+	return images, nil
+}
+
+/*
+===============================================================================
+	Dates and Times
+	---
+	Provides the interpretation of DA, TM and DT values as absolute times,
+	taking into account (0008,0201) TimezoneOffsetFromUTC.
+===============================================================================
+*/
+
+const (
+	// TimezoneOffsetFromUTC (0008,0201)
+	timezoneOffsetFromUTCTag = uint32(0x00080201)
+)
+
+// parseUTCOffset parses an offset from UTC of the form "&ZZXX", e.g. "-0500", into a location.
+func parseUTCOffset(s string) (*time.Location, error) {
+	if len(s) != 5 || (s[0] != '+' && s[0] != '-') {
+		return nil, fmt.Errorf("offset from UTC %q is not of the form &ZZXX", s)
+	}
+	hours, err := strconv.Atoi(s[1:3])
+	if err != nil {
+		return nil, fmt.Errorf("offset from UTC %q is not of the form &ZZXX", s)
+	}
+	minutes, err := strconv.Atoi(s[3:5])
+	if err != nil || hours > 14 || minutes >= 60 {
+		return nil, fmt.Errorf("offset from UTC %q is not of the form &ZZXX", s)
+	}
+	offset := (hours*60 + minutes) * 60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone(s, offset), nil
+}
+
+// parseDigits parses `s`, which consists of `n`-digit components, into `dst`. Components absent
+// from the end of `s` are left unchanged.
+func parseDigits(s string, n int, dst ...*int) error {
+	if len(s)%n != 0 || len(s)/n > len(dst) {
+		return fmt.Errorf("%q has an unexpected number of digits", s)
+	}
+	for i := 0; i < len(s)/n; i++ {
+		v, err := strconv.ParseUint(s[i*n:(i+1)*n], 10, 32)
+		if err != nil {
+			return fmt.Errorf("%q contains a non-digit", s)
+		}
+		*dst[i] = int(v)
+	}
+	return nil
+}
+
+// parseTime parses a TM value of the form "HH[MM[SS[.F{1-6}]]]", or the retired "HH:MM:SS.F",
+// into a time of day on `date`, in `loc`.
+func parseTime(s string, date time.Time, loc *time.Location) (time.Time, error) {
+	s = strings.Replace(s, ":", "", -1)
+	fraction := ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, fraction = s[:i], s[i+1:]
+	}
+	hour, minute, sec, nsec := 0, 0, 0, 0
+	if len(s) < 2 {
+		return time.Time{}, fmt.Errorf("time %q must specify at least the hour", s)
+	}
+	if err := parseDigits(s, 2, &hour, &minute, &sec); err != nil {
+		return time.Time{}, err
+	}
+	if len(fraction) > 0 {
+		if len(fraction) > 6 || len(s) != 6 {
+			return time.Time{}, fmt.Errorf("time %q has an invalid fraction", s+"."+fraction)
+		}
+		micro := 0
+		if err := parseDigits(fraction+strings.Repeat("0", 6-len(fraction)), 6, &micro); err != nil {
+			return time.Time{}, err
+		}
+		nsec = micro * 1000
+	}
+	// a second of 60 accommodates leap seconds
+	if hour > 23 || minute > 59 || sec > 60 {
+		return time.Time{}, fmt.Errorf("time %q is out of range", s)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, sec, nsec, loc), nil
+}
+
+// parseDate parses the "YYYY[MM[DD]]" date component of a DA or DT value.
+func parseDate(s string, loc *time.Location) (time.Time, error) {
+	year, month, day := 0, 1, 1
+	if len(s) < 4 {
+		return time.Time{}, fmt.Errorf("date %q must specify at least the year", s)
+	}
+	if err := parseDigits(s[:4], 4, &year); err != nil {
+		return time.Time{}, err
+	}
+	if err := parseDigits(s[4:], 2, &month, &day); err != nil {
+		return time.Time{}, err
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+	if date.Month() != time.Month(month) || date.Day() != day {
+		return time.Time{}, fmt.Errorf("date %q is out of range", s)
+	}
+	return date, nil
+}
+
+// TimezoneOffset returns the location described by (0008,0201) TimezoneOffsetFromUTC, e.g. "+0100",
+// which applies to each DA, TM and DT value of the dicom that lacks its own offset. If the
+// offset is absent, nil is returned.
+func (dcm *Dicom) TimezoneOffset() (*time.Location, error) {
+	offset, err := dcm.getString(timezoneOffsetFromUTCTag)
+	if err != nil || offset == "" {
+		return nil, err
+	}
+	loc, err := parseUTCOffset(offset)
+	if err != nil {
+		return nil, fmt.Errorf("TimezoneOffset(): %v", err)
+	}
+	return loc, nil
+}
+
+// GetTime returns the value of the DA, TM or DT element indexed by `tag` as a time. DA values are
+// at midnight, and TM values on January 1 of year 0; components omitted from the end of a
+// value take their minimum. Values are in the location of `TimezoneOffset`, unless a DT value
+// specifies its own offset from UTC; UTC is assumed if neither is present.
+func (dcm *Dicom) GetTime(tag uint32) (time.Time, error) {
+	e := NewElement()
+	if !dcm.GetElement(tag, &e) {
+		return time.Time{}, fmt.Errorf("GetTime(): %s is not present", NewElementWithTag(tag).dictEntry)
+	}
+	value, err := dcm.getString(tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := dcm.TimezoneOffset()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	switch e.GetVR() {
+	case "DA", "TM", "DT":
+	default:
+		return time.Time{}, fmt.Errorf("GetTime(): %s is not a DA, TM or DT element", e.dictEntry)
+	}
+	t, err := parseTemporal(e.GetVR(), value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("GetTime(): %s: %v", e.dictEntry, err)
+	}
+	return t, nil
+}
+
+// parseTemporal parses `value`, of VR DA, TM or DT, as per `GetTime`.
+func parseTemporal(vr string, value string, loc *time.Location) (t time.Time, err error) {
+	switch vr {
+	case "DA":
+		// the retired form separates components with periods
+		return parseDate(strings.Replace(value, ".", "", -1), loc)
+	case "TM":
+		return parseTime(value, time.Date(0, 1, 1, 0, 0, 0, 0, loc), loc)
+	case "DT":
+		if i := strings.IndexAny(value, "+-"); i >= 0 {
+			if loc, err = parseUTCOffset(value[i:]); err != nil {
+				return
+			}
+			value = value[:i]
+		}
+		date, rest := value, ""
+		if len(value) > 8 {
+			date, rest = value[:8], value[8:]
+		}
+		if t, err = parseDate(date, loc); err == nil && rest != "" {
+			t, err = parseTime(rest, t, loc)
+		}
+		return
+	}
+	return t, fmt.Errorf("VR %s is not a date or time", vr)
+}
+
+/*
+===============================================================================
+	Media Storage Directories
+	---
+	Provides access to the directory records of a DICOMDIR, which indexes
+	the files of a media storage file-set (e.g. a CD), as per
+	http://dicom.nema.org/dicom/2013/output/chtml/part03/sect_F.5.html
+===============================================================================
+*/
+
+const (
+	// DirectoryRecordSequence (0004,1220)
+	directoryRecordSequenceTag = uint32(0x00041220)
+
+	// DirectoryRecordType (0004,1430)
+	directoryRecordTypeTag = uint32(0x00041430)
+
+	// ReferencedFileID (0004,1500)
+	referencedFileIDTag = uint32(0x00041500)
+)
+
+// DirectoryRecord is one record of a DICOMDIR, such as a PATIENT, STUDY, SERIES or IMAGE.
+type DirectoryRecord struct {
+	// Type is (0004,1430) DirectoryRecordType
+	Type string
+	// ReferencedFileID is (0004,1500) ReferencedFileID: the components of the path of the
+	// referenced file, relative to the DICOMDIR. It is empty if the record references no file.
+	ReferencedFileID []string
+	// DataSet holds each element of the record
+	DataSet DataSet
+}
+
+// DicomDir is a parsed DICOMDIR, holding the records of its (0004,1220) DirectoryRecordSequence
+// in the order in which they appear.
+type DicomDir struct {
+	Dicom
+	Records []DirectoryRecord
+}
+
+// NewDicomDir returns the DICOMDIR represented by `dcm`. An error is returned if it does not
+// contain a Directory Record Sequence.
+func NewDicomDir(dcm Dicom) (*DicomDir, error) {
+	seq := NewElement()
+	if !dcm.GetElement(directoryRecordSequenceTag, &seq) {
+		return nil, errors.New("NewDicomDir(): dicom does not contain a Directory Record Sequence")
+	}
+	dd := DicomDir{Dicom: dcm, Records: make([]DirectoryRecord, 0, len(seq.GetItems()))}
+	for _, itm := range seq.GetItems() {
+		record := DirectoryRecord{DataSet: itm.dataset}
+		var err error
+		if record.Type, err = itm.dataset.getString(directoryRecordTypeTag); err != nil {
+			return nil, err
+		}
+		fileID := []string{}
+		if _, err = itm.dataset.GetElementValue(referencedFileIDTag, &fileID); err != nil {
+			return nil, err
+		}
+		for _, component := range fileID {
+			if component = strings.TrimSpace(component); component != "" {
+				record.ReferencedFileID = append(record.ReferencedFileID, component)
+			}
+		}
+		dd.Records = append(dd.Records, record)
+	}
+	return &dd, nil
+}
+
+// ReferencedFiles returns the path of each file referenced by the records of the DICOMDIR, in the
+// order in which they appear, resolved relative to `basePath`: the directory containing the DICOMDIR.
+// The components of each ReferencedFileID are joined with the separator of the operating system.
+// File IDs which would resolve outside of `basePath`, e.g. by a ".." component, are omitted.
+func (dd *DicomDir) ReferencedFiles(basePath string) []string {
+	paths := make([]string, 0)
+	for _, record := range dd.Records {
+		if len(record.ReferencedFileID) == 0 {
+			continue
+		}
+		valid := true
+		for _, component := range record.ReferencedFileID {
+			if component == ".." || strings.ContainsAny(component, `/\:`) {
+				valid = false
+			}
+		}
+		if valid {
+			paths = append(paths, filepath.Join(append([]string{basePath}, record.ReferencedFileID...)...))
+		}
+	}
+	return paths
+}
+
+/*
+===============================================================================
+	Attribute Matching
+	---
+	Provides the matching of data sets against the keys of a query, as per
+	the C-FIND service, such that a query (e.g. Modality Worklist) server
+	can filter the data sets it holds:
+	http://dicom.nema.org/medical/dicom/current/output/chtml/part04/sect_C.2.2.2.html
+===============================================================================
+*/
+
+// Matches returns whether the data set satisfies each matching key (element) of `query`:
+//   - an empty key matches any value, or its absence (universal matching)
+//   - a string key containing "*" or "?" matches any run of characters, or any one character,
+//     respectively (wildcard matching); this does not apply to DA, DT, TM or UI keys
+//   - a DA, TM or DT key of the form "a-b", "a-" or "-b" matches values within the inclusive
+//     range (range matching); DT values lacking an offset from UTC are compared as UTC
+//   - a UI key listing several UIDs matches any one of them (list of UID matching)
+//   - a sequence key holding one item matches if any item of the sequence matches it
+//     (sequence matching); an empty item is a universal match
+//   - otherwise, the value must be equal (single value matching), disregarding insignificant spaces
+//
+// A multi-valued element matches if any of its values do. (0008,0005) SpecificCharacterSet, group
+// lengths and the (0002) meta group are not matching keys.
+func (ds *DataSet) Matches(query DataSet) bool {
+	for tag, key := range query {
+		if tag>>16 == 0x0002 || tag&0xFFFF == 0 || tag == 0x00080005 || key.IsEmpty() {
+			continue
+		}
+		if key.HasItems() && key.items[0].dataset.Len() == 0 {
+			continue
+		}
+		e := NewElement()
+		if !ds.GetElement(tag, &e) || !matchesKey(e, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesKey returns whether `e` satisfies the non-empty matching `key`, as per `Matches`.
+func matchesKey(e Element, key Element) bool {
+	if key.HasItems() {
+		for i := range e.items {
+			if itm, err := e.parseItem(i); err == nil && itm.dataset.Matches(key.items[0].dataset) {
+				return true
+			}
+		}
+		return false
+	}
+	vr := key.GetVR()
+	switch vr {
+	case "AE", "AS", "CS", "DA", "DS", "DT", "IS", "LO", "LT", "PN", "SH", "ST", "TM", "UC", "UI", "UR", "UT":
+	case "OB", "OD", "OF", "OL", "OV", "OW", "UN":
+		return bytes.Equal(e.data, key.data)
+	default:
+		// binary values are compared as decoded, as the byte order of each may differ
+		return e.DescribeValue() == key.DescribeValue()
+	}
+	pattern := strings.TrimSpace(strings.TrimRight(string(key.data), "\x00"))
+	values := [][]byte{e.data}
+	if e.SupportsMultiVM() {
+		values = splitCharacterStringVM(e.data)
+	}
+	for _, v := range values {
+		value := strings.TrimSpace(strings.TrimRight(string(v), "\x00"))
+		switch {
+		case vr == "UI":
+			for _, uid := range strings.Split(pattern, `\`) {
+				if value == uid {
+					return true
+				}
+			}
+		case vr == "DA" || vr == "TM" || vr == "DT":
+			if value == pattern || matchesRange(vr, value, pattern) {
+				return true
+			}
+		case strings.ContainsAny(pattern, "*?"):
+			if matchesWildcard([]rune(value), []rune(pattern)) {
+				return true
+			}
+		case value == pattern:
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRange returns whether the DA, TM or DT `value` is within `rng`, of the form "a-b", "a-" or
+// "-b". As the offset from UTC of a DT value is itself introduced by "-" or "+", the range is split
+// at the first "-" which leaves a valid value (or nothing) either side.
+func matchesRange(vr string, value string, rng string) bool {
+	t, err := parseTemporal(vr, value, time.UTC)
+	if err != nil {
+		return false
+	}
+	for i := 0; i < len(rng); i++ {
+		lower, upper := rng[:i], rng[i+1:]
+		if rng[i] != '-' || (lower == "" && upper == "") {
+			continue
+		}
+		lowerT, lowerErr := parseTemporal(vr, lower, time.UTC)
+		upperT, upperErr := parseTemporal(vr, upper, time.UTC)
+		if (lower == "" || lowerErr == nil) && (upper == "" || upperErr == nil) {
+			return (lower == "" || !t.Before(lowerT)) && (upper == "" || !t.After(upperT))
+		}
+	}
+	return false
+}
+
+// matchesWildcard returns whether `value` matches `pattern`, in which "*" matches
+// any run of characters (including none), and "?" matches any single character.
+func matchesWildcard(value []rune, pattern []rune) bool {
+	// the position following the most recent "*", and that of the value it was matched to
+	star, starValue := -1, 0
+	v, p := 0, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			v, p = v+1, p+1
+		case p < len(pattern) && pattern[p] == '*':
+			p++
+			star, starValue = p, v
+		case star >= 0:
+			// extend the run matched by the most recent "*" by one character
+			starValue++
+			v, p = starValue, star
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
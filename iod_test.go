@@ -0,0 +1,636 @@
+package opendcm
+
+import (
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/b71729/opendcm/dictionary"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSequence returns an element indexed by `tag` containing one item per data set.
+func newSequence(tag uint32, datasets ...DataSet) Element {
+	e := NewElementWithTag(tag)
+	for _, ds := range datasets {
+		itm := NewItem()
+		itm.dataset = ds
+		e.items = append(e.items, itm)
+	}
+	return e
+}
+
+// newDataSet returns a data set containing `elements`.
+func newDataSet(elements ...Element) DataSet {
+	ds := make(DataSet, 0)
+	for _, e := range elements {
+		ds.addElement(e)
+	}
+	return ds
+}
+
+func TestSegmentFrames(t *testing.T) {
+	// ensures that segment numbers are correctly mapped to
+	// referenced source frames.
+	t.Parallel()
+	segNumber := func(n byte) Element {
+		e := NewElementWithTag(referencedSegmentNumberTag)
+		e.data = []byte{n, 0x00}
+		return e
+	}
+	refFrames := func(frames string) Element {
+		e := NewElementWithTag(referencedFrameNumberTag)
+		e.data = []byte(frames)
+		return e
+	}
+	frame := func(n byte, frames string) DataSet {
+		ds := newDataSet(newSequence(segmentIdentificationTag, newDataSet(segNumber(n))))
+		if frames != "" {
+			source := newSequence(sourceImageTag, newDataSet(refFrames(frames)))
+			ds.addElement(newSequence(derivationImageTag, newDataSet(source)))
+		}
+		return ds
+	}
+	dcm := newDicom()
+	dcm.addElement(newSequence(perFrameFunctionalGroupsTag,
+		frame(1, "3"),
+		frame(1, `4\5`),
+		frame(2, "3"),
+		frame(3, ""),
+	))
+	segments, err := dcm.SegmentFrames()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 4, 5}, segments[1])
+	assert.Equal(t, []int{3}, segments[2])
+	// falls back to the segmentation's own frame number
+	assert.Equal(t, []int{4}, segments[3])
+
+	// not a multi-frame object
+	dcm = newDicom()
+	_, err = dcm.SegmentFrames()
+	assert.Error(t, err)
+}
+
+func TestSplitFrames(t *testing.T) {
+	// ensures that each frame of a multi-frame dicom is split into its own
+	// dicom, with functional groups merged into the top-level data set.
+	t.Parallel()
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 1, 2, 1, 8, "MONOCHROME2")
+	dcm.addElement(newStringElement(numberOfFramesTag, "3 "))
+	dcm.addElement(newStringElement(sopInstanceUIDTag, "1.2.3.4"))
+	dcm.addElement(newSequence(sharedFunctionalGroupsTag, newDataSet(
+		newSequence(0x00289110, newDataSet(newStringElement(0x00280030, `1\1`))), // PixelMeasuresSequence
+	)))
+	position := func(z string) DataSet {
+		return newDataSet(newSequence(0x00209113, newDataSet(newStringElement(0x00200032, `0\0\`+z)))) // PlanePositionSequence
+	}
+	dcm.addElement(newSequence(perFrameFunctionalGroupsTag, position("0"), position("1"), position("2")))
+	dcm.pixelData.frames = [][]byte{{0, 1, 2, 3, 4, 5}}
+
+	frames, err := dcm.SplitFrames()
+	assert.NoError(t, err)
+	assert.Len(t, frames, 3)
+	uids := make(map[string]bool)
+	for i, frame := range frames {
+		assert.False(t, frame.HasElement(perFrameFunctionalGroupsTag))
+		assert.False(t, frame.HasElement(sharedFunctionalGroupsTag))
+		pm, err := frame.GetPixelModule()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, pm.NumberOfFrames)
+		assert.Equal(t, []byte{byte(i * 2), byte(i*2 + 1)}, frame.GetPixelData().GetFrame(0))
+
+		position := []string{}
+		_, err = frame.GetElementValue(0x00200032, &position)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0", "0", strconv.Itoa(i)}, position)
+		assert.True(t, frame.HasElement(0x00280030))
+
+		uid := ""
+		_, err = frame.GetElementValue(sopInstanceUIDTag, &uid)
+		assert.NoError(t, err)
+		assert.NotEqual(t, "1.2.3.4", uid)
+		uids[uid] = true
+	}
+	assert.Len(t, uids, 3)
+
+	// pixel data is too short for the number of frames
+	dcm.pixelData.frames = [][]byte{{0, 1}}
+	_, err = dcm.SplitFrames()
+	assert.Error(t, err)
+}
+
+func TestStructuredReport(t *testing.T) {
+	// ensures that the content tree of a structured report
+	// is decoded, including typed values of nested items.
+	t.Parallel()
+	code := func(tag uint32, value, scheme, meaning string) Element {
+		return newSequence(tag, newDataSet(
+			newStringElement(codeValueTag, value),
+			newStringElement(codingSchemeDesignatorTag, scheme),
+			newStringElement(codeMeaningTag, meaning),
+		))
+	}
+	text := newDataSet(
+		newStringElement(relationshipTypeTag, "CONTAINS"),
+		newStringElement(valueTypeTag, "TEXT"),
+		code(conceptNameCodeSequenceTag, "121071", "DCM", "Finding"),
+		newStringElement(textValueTag, "No abnormality detected"),
+	)
+	num := newDataSet(
+		newStringElement(relationshipTypeTag, "CONTAINS"),
+		newStringElement(valueTypeTag, "NUM"),
+		code(conceptNameCodeSequenceTag, "410668003", "SCT", "Length"),
+		newSequence(measuredValueSequenceTag, newDataSet(
+			newStringElement(numericValueTag, "12.5"),
+			code(measurementUnitsCodeSequenceTag, "mm", "UCUM", "millimeter"),
+		)),
+	)
+	coded := newDataSet(
+		newStringElement(relationshipTypeTag, "HAS CONCEPT MOD"),
+		newStringElement(valueTypeTag, "CODE"),
+		code(conceptCodeSequenceTag, "24028007", "SCT", "Right"),
+	)
+	container := newDataSet(
+		newStringElement(relationshipTypeTag, "CONTAINS"),
+		newStringElement(valueTypeTag, "CONTAINER"),
+		newSequence(contentSequenceTag, num, coded),
+	)
+	dcm := newDicom()
+	dcm.addElement(newStringElement(valueTypeTag, "CONTAINER"))
+	dcm.addElement(code(conceptNameCodeSequenceTag, "18748-4", "LN", "Diagnostic Imaging Report"))
+	dcm.addElement(newSequence(contentSequenceTag, text, container))
+
+	sr, err := dcm.StructuredReport()
+	assert.NoError(t, err)
+	assert.Equal(t, "CONTAINER", sr.Root.ValueType)
+	assert.Equal(t, "Diagnostic Imaging Report", sr.Root.ConceptName.CodeMeaning)
+	assert.Len(t, sr.Root.Children, 2)
+	assert.Equal(t, "No abnormality detected", sr.Root.Children[0].TextValue)
+	assert.Equal(t, "121071", sr.Root.Children[0].ConceptName.CodeValue)
+
+	nested := sr.Root.Children[1].Children
+	assert.Len(t, nested, 2)
+	assert.Equal(t, 12.5, nested[0].NumericValue)
+	assert.Equal(t, "UCUM", nested[0].Units.CodingSchemeDesignator)
+	assert.Equal(t, "HAS CONCEPT MOD", nested[1].RelationshipType)
+	assert.Equal(t, "Right", nested[1].Code.CodeMeaning)
+
+	// not a structured report
+	dcm = newDicom()
+	_, err = dcm.StructuredReport()
+	assert.Error(t, err)
+}
+
+func TestIdentifiers(t *testing.T) {
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	study, series, instance, class, err := dcm.Identifiers()
+	assert.NoError(t, err)
+	for _, uid := range []string{study, series, instance, class} {
+		assert.True(t, IsValidUID(uid), uid)
+	}
+	assert.Equal(t, "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613", series)
+
+	// missing
+	dcm = newDicom()
+	_, _, _, _, err = dcm.Identifiers()
+	assert.Error(t, err)
+
+	// mistyped
+	e := NewElementWithTag(studyInstanceUIDTag)
+	e.dictEntry = &dictionary.DictEntry{Tag: studyInstanceUIDTag, VR: "LO"}
+	e.data = []byte("1.2.3")
+	dcm.addElement(e)
+	_, _, _, _, err = dcm.Identifiers()
+	assert.Error(t, err)
+}
+
+func TestSliceGeometry(t *testing.T) {
+	// ensures that per-frame geometry takes precedence over shared geometry,
+	// and that single-frame dicoms use their top-level attributes.
+	t.Parallel()
+	orientation := newStringElement(imageOrientationPatientTag, `1\0\0\0\1\0`)
+	dcm := newDicom()
+	dcm.addElement(newSequence(sharedFunctionalGroupsTag, newDataSet(
+		newSequence(planeOrientationTag, newDataSet(orientation)),
+		newSequence(pixelMeasuresTag, newDataSet(
+			newStringElement(sliceThicknessTag, "2.5"),
+			newStringElement(pixelSpacingTag, `0.5\0.75`),
+		)),
+	)))
+	dcm.addElement(newSequence(perFrameFunctionalGroupsTag,
+		newDataSet(newSequence(planePositionTag, newDataSet(newStringElement(imagePositionPatientTag, `-10\-20\0`)))),
+		newDataSet(
+			newSequence(planePositionTag, newDataSet(newStringElement(imagePositionPatientTag, `-10\-20\2.5`))),
+			newSequence(pixelMeasuresTag, newDataSet(newStringElement(sliceThicknessTag, "3"))),
+		),
+	))
+	position, orient, thickness, spacing, err := dcm.SliceGeometry(0)
+	assert.NoError(t, err)
+	assert.Equal(t, [3]float64{-10, -20, 0}, position)
+	assert.Equal(t, [6]float64{1, 0, 0, 0, 1, 0}, orient)
+	assert.Equal(t, 2.5, thickness)
+	assert.Equal(t, [2]float64{0.5, 0.75}, spacing)
+	position, _, thickness, spacing, err = dcm.SliceGeometry(1)
+	assert.NoError(t, err)
+	assert.Equal(t, [3]float64{-10, -20, 2.5}, position)
+	assert.Equal(t, 3.0, thickness)
+	// spacing is taken from the shared group, as the per-frame pixel measures do not contain it
+	assert.Equal(t, [2]float64{0.5, 0.75}, spacing)
+	_, _, _, _, err = dcm.SliceGeometry(2)
+	assert.Error(t, err)
+
+	// single-frame
+	dcm, err = FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	_, orient, thickness, _, err = dcm.SliceGeometry(0)
+	assert.NoError(t, err)
+	assert.NotEqual(t, [6]float64{}, orient)
+	assert.NotZero(t, thickness)
+	_, _, _, _, err = dcm.SliceGeometry(1)
+	assert.Error(t, err)
+
+	// missing position
+	dcm = newDicom()
+	dcm.addElement(orientation)
+	_, _, _, _, err = dcm.SliceGeometry(0)
+	assert.Error(t, err)
+
+	// wrong number of values
+	dcm.addElement(newStringElement(imagePositionPatientTag, `1\2`))
+	_, _, _, _, err = dcm.SliceGeometry(0)
+	assert.Error(t, err)
+}
+
+func TestFrameTimes(t *testing.T) {
+	t.Parallel()
+	pointer := func(tag uint32) Element {
+		e := NewElementWithTag(frameIncrementPointerTag)
+		e.data = make([]byte, 4)
+		binary.LittleEndian.PutUint16(e.data[0:], uint16(tag>>16))
+		binary.LittleEndian.PutUint16(e.data[2:], uint16(tag))
+		return e
+	}
+	dcm := newDicom()
+	dcm.addElement(newStringElement(numberOfFramesTag, "4"))
+	dcm.addElement(pointer(frameTimeTag))
+	dcm.addElement(newStringElement(frameTimeTag, "33.3"))
+	times, err := dcm.FrameTimes()
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{0, 33.3, 66.6, 99.9}, times, 1e-9)
+
+	dcm.addElement(pointer(frameTimeVectorTag))
+	dcm.addElement(newStringElement(frameTimeVectorTag, `0\30\40\30`))
+	times, err = dcm.FrameTimes()
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0, 30, 70, 100}, times)
+
+	// vector does not match the number of frames
+	dcm.addElement(newStringElement(frameTimeVectorTag, `0\30`))
+	_, err = dcm.FrameTimes()
+	assert.Error(t, err)
+
+	// pointer to an absent element, and no pointer at all
+	dcm.addElement(pointer(0x00181066))
+	_, err = dcm.FrameTimes()
+	assert.Error(t, err)
+	dcm = newPixelDicom(ExplicitVRLittleEndian, 1, 1, 1, 8, "MONOCHROME2")
+	_, err = dcm.FrameTimes()
+	assert.Error(t, err)
+}
+
+func TestGraphicAnnotations(t *testing.T) {
+	// ensures that text and graphic objects are decoded, along with their layer and referenced images.
+	t.Parallel()
+	points := func(tag uint32, values ...float32) Element {
+		e := NewElementWithTag(tag)
+		e.data = make([]byte, 4*len(values))
+		for i, v := range values {
+			binary.LittleEndian.PutUint32(e.data[4*i:], math.Float32bits(v))
+		}
+		return e
+	}
+	text := newDataSet(
+		newStringElement(boundingBoxAnnotationUnitsTag, "PIXEL "),
+		newStringElement(unformattedTextValueTag, "lesion"),
+		points(boundingBoxTopLeftHandCornerTag, 10, 20),
+		points(boundingBoxBottomRightHandCornerTag, 50, 30),
+	)
+	graphic := newDataSet(
+		newStringElement(graphicAnnotationUnitsTag, "DISPLAY "),
+		newStringElement(graphicTypeTag, "POLYLINE"),
+		newStringElement(graphicFilledTag, "Y "),
+		points(graphicDataTag, 0.25, 0.5, 0.75, 0.5),
+	)
+	dcm := newDicom()
+	dcm.addElement(newSequence(graphicAnnotationSequenceTag,
+		newDataSet(
+			newStringElement(graphicLayerTag, "LAYER1"),
+			newSequence(referencedImageSequenceTag, newDataSet(newStringElement(referencedSOPInstanceUIDTag, "1.2.3.4"))),
+			newSequence(textObjectSequenceTag, text),
+			newSequence(graphicObjectSequenceTag, graphic),
+		),
+	))
+	annotations, err := dcm.GraphicAnnotations()
+	assert.NoError(t, err)
+	assert.Equal(t, []Annotation{
+		{
+			Type:                      "TEXT",
+			Layer:                     "LAYER1",
+			Units:                     "PIXEL",
+			Points:                    [][2]float32{{10, 20}, {50, 30}},
+			Text:                      "lesion",
+			ReferencedSOPInstanceUIDs: []string{"1.2.3.4"},
+		},
+		{
+			Type:                      "POLYLINE",
+			Layer:                     "LAYER1",
+			Units:                     "DISPLAY",
+			Points:                    [][2]float32{{0.25, 0.5}, {0.75, 0.5}},
+			Filled:                    true,
+			ReferencedSOPInstanceUIDs: []string{"1.2.3.4"},
+		},
+	}, annotations)
+
+	// an odd number of coordinates is an error
+	graphic.addElement(points(graphicDataTag, 0.25, 0.5, 0.75))
+	_, err = dcm.GraphicAnnotations()
+	assert.Error(t, err)
+
+	// not a presentation state
+	dcm = newDicom()
+	_, err = dcm.GraphicAnnotations()
+	assert.Error(t, err)
+}
+
+func TestFrameDimensionIndex(t *testing.T) {
+	// ensures that frames are ordered according to their dimension index values.
+	t.Parallel()
+	frame := func(values ...uint32) DataSet {
+		e := NewElementWithTag(dimensionIndexValuesTag)
+		e.data = make([]byte, 4*len(values))
+		for i, v := range values {
+			binary.LittleEndian.PutUint32(e.data[4*i:], v)
+		}
+		return newDataSet(newSequence(frameContentTag, newDataSet(e)))
+	}
+	dcm := newDicom()
+	dcm.addElement(newSequence(dimensionIndexSequenceTag, newDataSet(), newDataSet()))
+	dcm.addElement(newSequence(perFrameFunctionalGroupsTag, frame(2, 1), frame(1, 2), frame(1, 1), frame(2, 2)))
+	index, err := dcm.FrameDimensionIndex(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2}, index)
+	_, err = dcm.FrameDimensionIndex(4)
+	assert.Error(t, err)
+	order, err := dcm.FramesInDimensionOrder()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1, 0, 3}, order)
+
+	// number of values does not match the number of dimensions
+	dcm.addElement(newSequence(perFrameFunctionalGroupsTag, frame(1), frame(2)))
+	_, err = dcm.FrameDimensionIndex(0)
+	assert.Error(t, err)
+	_, err = dcm.FramesInDimensionOrder()
+	assert.Error(t, err)
+
+	// frame content absent, and not multi-frame
+	dcm.addElement(newSequence(perFrameFunctionalGroupsTag, newDataSet()))
+	_, err = dcm.FrameDimensionIndex(0)
+	assert.Error(t, err)
+	dcm = newDicom()
+	_, err = dcm.FramesInDimensionOrder()
+	assert.Error(t, err)
+}
+
+// newVolumeSlice returns a single-frame 1x2 image positioned at `z` along the patient axis.
+func newVolumeSlice(z string, pixelRepresentation uint16, samples ...byte) Dicom {
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 1, 2, 1, 16, "MONOCHROME2")
+	dcm.addElement(newUSElement(0x00280103, pixelRepresentation))
+	dcm.addElement(newStringElement(imagePositionPatientTag, `-10\-20\`+z))
+	dcm.addElement(newStringElement(imageOrientationPatientTag, `1\0\0\0\1\0`))
+	dcm.addElement(newStringElement(pixelSpacingTag, `0.5\0.75`))
+	dcm.pixelData.isLittleEndian = true
+	dcm.pixelData.frames = [][]byte{samples}
+	return dcm
+}
+
+func TestBuildVolume(t *testing.T) {
+	// ensures that images are sorted along the slice normal, and that
+	// inconsistent or unequally spaced series are rejected.
+	t.Parallel()
+	vol, err := BuildVolume([]Dicom{
+		newVolumeSlice("5", 0, 0x05, 0x00, 0x06, 0x00),
+		newVolumeSlice("0", 0, 0x01, 0x00, 0x02, 0x00),
+		newVolumeSlice("2.5", 0, 0x03, 0x00, 0x04, 0x00),
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, vol.Columns)
+		assert.Equal(t, 1, vol.Rows)
+		assert.Equal(t, 3, vol.Slices)
+		assert.Equal(t, [3]float64{-10, -20, 0}, vol.Origin)
+		assert.Equal(t, [6]float64{1, 0, 0, 0, 1, 0}, vol.Orientation)
+		assert.Equal(t, [3]float64{0.75, 0.5, 2.5}, vol.Spacing)
+		assert.Equal(t, []uint16{1, 2, 3, 4, 5, 6}, vol.Voxels)
+	}
+
+	// signed samples
+	vol, err = BuildVolume([]Dicom{
+		newVolumeSlice("1", 1, 0xFF, 0xFF, 0x00, 0x80),
+		newVolumeSlice("0", 1, 0x01, 0x00, 0x02, 0x00),
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int16{1, 2, -1, -32768}, vol.Voxels)
+	}
+
+	// no files, duplicate positions, unequal spacing, and mismatched dimensions
+	_, err = BuildVolume(nil)
+	assert.Error(t, err)
+	_, err = BuildVolume([]Dicom{newVolumeSlice("0", 0, 0, 0, 0, 0), newVolumeSlice("0", 0, 0, 0, 0, 0)})
+	assert.Error(t, err)
+	_, err = BuildVolume([]Dicom{
+		newVolumeSlice("0", 0, 0, 0, 0, 0),
+		newVolumeSlice("1", 0, 0, 0, 0, 0),
+		newVolumeSlice("3", 0, 0, 0, 0, 0),
+	})
+	assert.Error(t, err)
+	mismatched := newVolumeSlice("1", 0, 0, 0, 0, 0)
+	mismatched.addElement(newUSElement(0x00280010, 2))
+	_, err = BuildVolume([]Dicom{newVolumeSlice("0", 0, 0, 0, 0, 0), mismatched})
+	assert.Error(t, err)
+}
+
+func TestReferencedImages(t *testing.T) {
+	t.Parallel()
+	dcm := newDicom()
+	dcm.addElement(newSequence(referencedImageSequenceTag,
+		newDataSet(
+			newStringElement(referencedSOPClassUIDTag, "1.2.840.10008.5.1.4.1.1.2"),
+			newStringElement(referencedSOPInstanceUIDTag, "1.2.3.4"),
+		),
+		newDataSet(
+			newStringElement(referencedSOPClassUIDTag, "1.2.840.10008.5.1.4.1.1.4.1"),
+			newStringElement(referencedSOPInstanceUIDTag, "1.2.3.5"),
+			newStringElement(referencedFrameNumberTag, `1\3`),
+		),
+	))
+	images, err := dcm.ReferencedImages()
+	assert.NoError(t, err)
+	assert.Equal(t, []ReferencedImage{
+		{SOPClassUID: "1.2.840.10008.5.1.4.1.1.2", SOPInstanceUID: "1.2.3.4", FrameNumbers: []int{}},
+		{SOPClassUID: "1.2.840.10008.5.1.4.1.1.4.1", SOPInstanceUID: "1.2.3.5", FrameNumbers: []int{1, 3}},
+	}, images)
+
+	// missing instance UID, and no sequence at all
+	dcm.addElement(newSequence(referencedImageSequenceTag, newDataSet(
+		newStringElement(referencedSOPClassUIDTag, "1.2.840.10008.5.1.4.1.1.2"),
+	)))
+	_, err = dcm.ReferencedImages()
+	assert.Error(t, err)
+	dcm = newDicom()
+	_, err = dcm.ReferencedImages()
+	assert.Error(t, err)
+}
+
+func TestGetTime(t *testing.T) {
+	// ensures that DA, TM and DT values are interpreted in the location of TimezoneOffsetFromUTC,
+	// unless a DT value specifies its own offset.
+	t.Parallel()
+	dcm := newDicom()
+	loc, err := dcm.TimezoneOffset()
+	assert.NoError(t, err)
+	assert.Nil(t, loc)
+	dcm.addElement(newStringElement(0x00080020, "20180512"))
+	dcm.addElement(newStringElement(0x00080030, "1430"))
+	dcm.addElement(newStringElement(0x0008002A, "20180512143000.5"))
+	date, err := dcm.GetTime(0x00080020)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 5, 12, 0, 0, 0, 0, time.UTC), date)
+
+	dcm.addElement(newStringElement(timezoneOffsetFromUTCTag, "-0500 "))
+	loc, err = dcm.TimezoneOffset()
+	assert.NoError(t, err)
+	_, offset := time.Date(2018, 5, 12, 0, 0, 0, 0, loc).Zone()
+	assert.Equal(t, -5*60*60, offset)
+	tm, err := dcm.GetTime(0x00080030)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(0, 1, 1, 19, 30, 0, 0, time.UTC), tm.UTC())
+	dt, err := dcm.GetTime(0x0008002A)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 5, 12, 19, 30, 0, 500000000, time.UTC), dt.UTC())
+
+	// a DT value's own offset takes precedence
+	dcm.addElement(newStringElement(0x0008002A, "201805121430+0100"))
+	dt, err = dcm.GetTime(0x0008002A)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 5, 12, 13, 30, 0, 0, time.UTC), dt.UTC())
+
+	// retired formats, and invalid values
+	dcm.addElement(newStringElement(0x00080020, "2018.05.12"))
+	date, err = dcm.GetTime(0x00080020)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, date.Day())
+	dcm.addElement(newStringElement(0x00080030, "14:30:00.25"))
+	tm, err = dcm.GetTime(0x00080030)
+	assert.NoError(t, err)
+	assert.Equal(t, 250000000, tm.Nanosecond())
+	for tag, value := range map[uint32]string{0x00080020: "20180230", 0x00080030: "2530", 0x0008002A: "2018051214+01"} {
+		dcm.addElement(newStringElement(tag, value))
+		_, err = dcm.GetTime(tag)
+		assert.Error(t, err, value)
+	}
+	_, err = dcm.GetTime(timezoneOffsetFromUTCTag)
+	assert.Error(t, err)
+	dcm.addElement(newStringElement(timezoneOffsetFromUTCTag, "EST"))
+	_, err = dcm.TimezoneOffset()
+	assert.Error(t, err)
+}
+
+func TestDicomDirReferencedFiles(t *testing.T) {
+	// ensures that the ReferencedFileID of each record is resolved relative to the DICOMDIR,
+	// and that records referencing no file are skipped.
+	t.Parallel()
+	dcm := newDicom()
+	_, err := NewDicomDir(dcm)
+	assert.Error(t, err)
+	dcm.addElement(newSequence(directoryRecordSequenceTag,
+		newDataSet(newStringElement(directoryRecordTypeTag, "PATIENT ")),
+		newDataSet(newStringElement(directoryRecordTypeTag, "IMAGE"), newStringElement(referencedFileIDTag, `DICOM\ST000\IM000 `)),
+		newDataSet(newStringElement(directoryRecordTypeTag, "IMAGE"), newStringElement(referencedFileIDTag, `DICOM\ST000\IM001`)),
+		newDataSet(newStringElement(directoryRecordTypeTag, "IMAGE"), newStringElement(referencedFileIDTag, `..\..\ETC`)),
+	))
+	dd, err := NewDicomDir(dcm)
+	assert.NoError(t, err)
+	if assert.Len(t, dd.Records, 4) {
+		assert.Equal(t, "PATIENT", dd.Records[0].Type)
+		assert.Empty(t, dd.Records[0].ReferencedFileID)
+		assert.Equal(t, []string{"DICOM", "ST000", "IM000"}, dd.Records[1].ReferencedFileID)
+	}
+	base := filepath.Join("media", "cdrom")
+	assert.Equal(t, []string{
+		filepath.Join(base, "DICOM", "ST000", "IM000"),
+		filepath.Join(base, "DICOM", "ST000", "IM001"),
+	}, dd.ReferencedFiles(base))
+}
+
+func TestMatches(t *testing.T) {
+	// ensures that each form of attribute matching is applied according to the VR and value of the key.
+	t.Parallel()
+	ds := newDataSet(
+		newStringElement(0x00100010, "DOE^JOHN"),
+		newStringElement(0x00080060, "CT"),
+		newStringElement(0x00080008, `ORIGINAL\PRIMARY\AXIAL`),
+		newStringElement(0x00080020, "20060610"),
+		newStringElement(0x00080030, "120000"),
+		newStringElement(0x0008002A, "20060610120000-0500"),
+		newStringElement(sopInstanceUIDTag, "1.2.3.4\x00"),
+		newUSElement(0x00280010, 512),
+		newSequence(0x00400100, newDataSet(newStringElement(0x00080060, "MR"), newStringElement(0x00400001, "STN1"))),
+	)
+	for _, tc := range []struct {
+		key     Element
+		matches bool
+	}{
+		{newStringElement(0x00100010, ""), true},
+		{newStringElement(0x00100020, ""), true}, // absent, but universal
+		{newStringElement(0x00100020, "123"), false},
+		{newStringElement(0x00100010, "DOE^JOHN "), true},
+		{newStringElement(0x00100010, "DOE^JANE"), false},
+		{newStringElement(0x00100010, "DOE*"), true},
+		{newStringElement(0x00100010, "D?E^J*N"), true},
+		{newStringElement(0x00100010, "*JANE"), false},
+		{newStringElement(0x00100010, "*"), true},
+		{newStringElement(0x00080008, "AXIAL"), true},
+		{newStringElement(0x00080008, "LOCALIZER"), false},
+		{newStringElement(0x00080020, "20060610"), true},
+		{newStringElement(0x00080020, "20060601-20060630"), true},
+		{newStringElement(0x00080020, "20060611-"), false},
+		{newStringElement(0x00080020, "-20060610"), true},
+		{newStringElement(0x00080020, "2006*"), false}, // no wildcards for dates
+		{newStringElement(0x00080030, "1100-1300"), true},
+		{newStringElement(0x00080030, "1300-"), false},
+		{newStringElement(0x0008002A, "20060610170000-20060610170000"), true},
+		{newStringElement(0x0008002A, "20060610120000-0500-"), true},
+		{newStringElement(0x0008002A, "-20060610120000"), false},
+		{newStringElement(sopInstanceUIDTag, `1.2.3.5\1.2.3.4`), true},
+		{newStringElement(sopInstanceUIDTag, "1.2.3.*"), false},
+		{newUSElement(0x00280010, 512), true},
+		{newUSElement(0x00280010, 256), false},
+		{newSequence(0x00400100, newDataSet()), true},
+		{newSequence(0x00400100, newDataSet(newStringElement(0x00080060, "MR"))), true},
+		{newSequence(0x00400100, newDataSet(newStringElement(0x00080060, "CT"))), false},
+		{newSequence(0x00400100, newDataSet(newStringElement(0x00080060, "MR"), newStringElement(0x00400001, "STN*"))), true},
+		{newSequence(0x00400200, newDataSet(newStringElement(0x00080060, "MR"))), false},
+	} {
+		assert.Equal(t, tc.matches, ds.Matches(newDataSet(tc.key)), tc.key.Describe())
+	}
+	// every key must match
+	query := newDataSet(newStringElement(0x00080060, "CT"), newStringElement(0x00100010, "DOE^JANE"))
+	assert.False(t, ds.Matches(query))
+	query.addElement(newStringElement(0x00100010, "DOE^JOHN"))
+	query.addElement(newStringElement(0x00080005, "ISO_IR 100"))
+	assert.True(t, ds.Matches(query))
+}
@@ -2,6 +2,9 @@ package opendcm
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -13,6 +16,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 /*
@@ -43,10 +48,57 @@ type Config struct {
 	/* By enabling `StrictMode`, the parser will reject DICOM inputs which either:
 	   - TODO: Contain an element with a value length exceeding the maximum allowed for its VR
 	   - Contain an element with a value length exceeding the remaining file size. For example incomplete Pixel Data.
+	   Likewise, the writer will reject elements whose values exceed the maximum length allowed for their VR.
 	*/
 	StrictMode bool
 
-	// DicomReadBufferSize is the number of bytes to be buffered from disk when parsing dicoms
+	// RepairMode allows the parser to adjust common spec violations that it encounters,
+	// such as padding odd-length values to an even length, or skipping stray bytes within sequences.
+	RepairMode bool
+
+	// ValidateUIDs enables checking that UI elements contain only digits and dots. Invalid UIDs are
+	// rejected in `StrictMode`, and otherwise reported as warnings.
+	ValidateUIDs bool
+
+	// WarningsAreErrors causes any non-fatal problem encountered whilst parsing, which would otherwise be
+	// reported through `Dicom.Warnings`, to instead be returned as a `CorruptDicom` error.
+	WarningsAreErrors bool
+
+	// CaptureRawMeta retains the exact bytes of the preamble, magic and (0002) meta group as read,
+	// such that they are available through `Dicom.RawMetaBytes`.
+	CaptureRawMeta bool
+
+	// ForcePixelByteSwap causes native 16-bit pixel data to be decoded using the opposite byte order
+	// to that of the transfer syntax, for files whose pixel data has been incorrectly encoded.
+	ForcePixelByteSwap bool
+
+	// DeflateOnWrite causes dicoms with uncompressed pixel data to be written using the
+	// Deflated Explicit VR Little Endian transfer syntax, reducing the size of the data set.
+	DeflateOnWrite bool
+
+	// SyncSOPUIDsOnWrite causes (0002,0002) MediaStorageSOPClassUID and (0002,0003) MediaStorageSOPInstanceUID
+	// to be written from the data set's (0008,0016) SOPClassUID and (0008,0018) SOPInstanceUID, even if
+	// already present, such that they are consistent. See: `Dicom.CheckSOPConsistency`.
+	SyncSOPUIDsOnWrite bool
+
+	// ParsePixelDataAsElements forces the items of an undefined length (7FE0,0010) PixelData element to be
+	// parsed as embedded elements, rather than as data fragments. This should only be enabled for SOP Classes
+	// where PixelData genuinely contains a sequence: encapsulated image data will fail to parse, or be
+	// misinterpreted, if treated as elements.
+	ParsePixelDataAsElements bool
+
+	// SkipElement, if set, is called before the value of each element is read. Should it return true,
+	// the value is skipped and the element is not stored. `vr` is that of the source where explicit,
+	// and `length` may be 0xFFFFFFFF (undefined), in which case the value is read in order to skip it.
+	SkipElement func(tag uint32, vr string, length uint32) bool
+
+	// DeferItems, if set, is called for each sequence. Should it return true, the elements of its defined
+	// length items are not parsed, but retained in encoded form; each such item holds an empty data set until
+	// parsed with `Element.ParseItem`. This allows the items of very large sequences, such as (5200,9230)
+	// PerFrameFunctionalGroupsSequence, to be parsed only as needed. See: `Element.ItemRange`.
+	DeferItems func(tag uint32) bool
+
+	// DicomReadBufferSize is the number of bytes to be buffered when parsing dicoms with `FromStream`
 	DicomReadBufferSize int
 
 	// AET
@@ -54,6 +106,11 @@ type Config struct {
 	AEBindIP   string
 	AEBindPort int
 
+	// ImplementationClassUID and ImplementationVersionName are written to (0002,0012) and (0002,0013)
+	// of generated files. If empty, OpenDCM's own implementation identity is used.
+	ImplementationClassUID    string
+	ImplementationVersionName string
+
 	// do not access / write `_set`. It is used internally.
 	_set bool
 }
@@ -121,11 +178,21 @@ func initialiseConfig() {
 	if !config._set {
 		config.OpenFileLimit = intFromEnvDefault("OPENDCM_OPENFILELIMIT", 64)
 		config.StrictMode = boolFromEnvDefault("OPENDCM_STRICTMODE", false)
+		config.RepairMode = boolFromEnvDefault("OPENDCM_REPAIRMODE", false)
+		config.ValidateUIDs = boolFromEnvDefault("OPENDCM_VALIDATEUIDS", false)
+		config.WarningsAreErrors = boolFromEnvDefault("OPENDCM_WARNINGSAREERRORS", false)
+		config.CaptureRawMeta = boolFromEnvDefault("OPENDCM_CAPTURERAWMETA", false)
+		config.ForcePixelByteSwap = boolFromEnvDefault("OPENDCM_FORCEPIXELBYTESWAP", false)
+		config.DeflateOnWrite = boolFromEnvDefault("OPENDCM_DEFLATEONWRITE", false)
+		config.SyncSOPUIDsOnWrite = boolFromEnvDefault("OPENDCM_SYNCSOPUIDSONWRITE", false)
+		config.ParsePixelDataAsElements = boolFromEnvDefault("OPENDCM_PIXELDATAASELEMENTS", false)
 		config.DicomReadBufferSize = intFromEnvDefault("OPENDCM_BUFFERSIZE", 2*1024*1024)
 		config.LogLevel = strings.ToLower(strFromEnvDefault("OPENDCM_LOGLEVEL", "info"))
 		config.AET = strFromEnvDefault("OPENDCM_AET", "OPENDCM")
 		config.AEBindIP = strFromEnvDefault("OPENDCM_AEIP", "0.0.0.0")
 		config.AEBindPort = intFromEnvDefault("OPENDCM_AEPORT", 6789)
+		config.ImplementationClassUID = strFromEnvDefault("OPENDCM_IMPLEMENTATIONUID", "")
+		config.ImplementationVersionName = strFromEnvDefault("OPENDCM_IMPLEMENTATIONVERSION", "")
 		switch config.LogLevel {
 		case "debug", "info", "warn", "error", "fatal", "none", "disabled", "0", "1", "2", "3", "4", "5":
 			SetLoggingLevel(config.LogLevel)
@@ -141,6 +208,12 @@ func init() {
 	initialiseConfig()
 }
 
+// GetConfig returns the current configuration, initialising it from environment if not already set.
+func GetConfig() Config {
+	initialiseConfig()
+	return config
+}
+
 // OverrideConfig overrides the configuration parsed from environment with the one provided
 func OverrideConfig(newconfig Config) {
 	if !newconfig._set { // to prevent being reverted with subsequent calls to `GetConfig`
@@ -407,10 +480,23 @@ func SetLoggingLevel(level string) {
 
 // ConcurrentlyWalkDir recursively traverses a directory and calls `onFile` for each found file inside a goroutine.
 func ConcurrentlyWalkDir(dirPath string, onFile func(file string)) error {
-	guard := make(chan bool, config.OpenFileLimit) // limits number of concurrently open files
-	var files []string
-	wg := sync.WaitGroup{}
+	files, err := findFiles(dirPath)
+	if err != nil {
+		return err
+	}
+	concurrentlyVisit(files, func(file foundFile) { onFile(file.path) })
+	return nil
+}
 
+// foundFile is a file found by `findFiles`, along with its size in bytes.
+type foundFile struct {
+	path string
+	size int64
+}
+
+// findFiles recursively traverses a directory, and returns each file found within it.
+func findFiles(dirPath string) ([]foundFile, error) {
+	var files []foundFile
 	err := filepath.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -418,27 +504,139 @@ func ConcurrentlyWalkDir(dirPath string, onFile func(file string)) error {
 		if info.IsDir() {
 			return nil
 		}
-		files = append(files, filePath)
+		files = append(files, foundFile{path: filePath, size: info.Size()})
 		return nil
 	})
-	if err != nil {
-		return err
-	}
+	return files, err
+}
+
+// concurrentlyVisit calls `onFile` for each of `files` inside a goroutine, one at a time.
+func concurrentlyVisit(files []foundFile, onFile func(file foundFile)) {
+	guard := make(chan bool, config.OpenFileLimit) // limits number of concurrently open files
+	wg := sync.WaitGroup{}
 
 	// now goroutine each file
 	wg.Add(len(files))
 	m := sync.Mutex{}
-	for _, filePath := range files {
+	for _, file := range files {
 		guard <- true // would block if guard channel is already filled
-		go func(path string) {
+		go func(file foundFile) {
 			defer wg.Done()
 			m.Lock()
-			onFile(path)
+			onFile(file)
 			m.Unlock()
 			<-guard
-		}(filePath)
+		}(file)
 	}
 	wg.Wait()
+}
+
+// WalkProgress describes how far a directory walk has progressed.
+type WalkProgress struct {
+	FilesProcessed int64
+	FilesTotal     int64
+	BytesProcessed int64
+	BytesTotal     int64
+}
+
+// ProgressReporter periodically samples a `WalkProgress` and passes it to a callback.
+// Counters are updated atomically, so `FileDone` is safe to call from multiple goroutines.
+type ProgressReporter struct {
+	progress   WalkProgress
+	onProgress func(WalkProgress)
+	done       chan bool
+	stopped    chan bool
+	// started is set by the first call to `Start`, unless `Stop` was called first
+	started   bool
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewProgressReporter returns a fresh ProgressReporter which will call `onProgress`
+// with the current progress every `interval`, once started.
+func NewProgressReporter(filesTotal int64, bytesTotal int64, onProgress func(WalkProgress)) *ProgressReporter {
+	return &ProgressReporter{
+		progress:   WalkProgress{FilesTotal: filesTotal, BytesTotal: bytesTotal},
+		onProgress: onProgress,
+		done:       make(chan bool),
+		stopped:    make(chan bool),
+	}
+}
+
+// FileDone records that a file of `size` bytes has been processed.
+func (pr *ProgressReporter) FileDone(size int64) {
+	atomic.AddInt64(&pr.progress.FilesProcessed, 1)
+	atomic.AddInt64(&pr.progress.BytesProcessed, size)
+}
+
+// Progress returns a snapshot of the current progress.
+func (pr *ProgressReporter) Progress() WalkProgress {
+	return WalkProgress{
+		FilesProcessed: atomic.LoadInt64(&pr.progress.FilesProcessed),
+		FilesTotal:     pr.progress.FilesTotal,
+		BytesProcessed: atomic.LoadInt64(&pr.progress.BytesProcessed),
+		BytesTotal:     pr.progress.BytesTotal,
+	}
+}
+
+// Start begins reporting progress every `interval` inside a goroutine.
+// Reporting continues until `Stop` is called. Subsequent calls, or calls after `Stop`, have no effect.
+func (pr *ProgressReporter) Start(interval time.Duration) {
+	pr.startOnce.Do(func() {
+		pr.started = true
+		go func() {
+			defer close(pr.stopped)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					pr.onProgress(pr.Progress())
+				case <-pr.done:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Stop halts periodic reporting, and reports the final progress once.
+// It may be called without `Start` having been called, and subsequent calls have no effect.
+func (pr *ProgressReporter) Stop() {
+	pr.stopOnce.Do(func() {
+		// prevents a later `Start`, and orders the read of `started` after any write
+		pr.startOnce.Do(func() {})
+		if pr.started {
+			close(pr.done)
+			<-pr.stopped
+		}
+		pr.onProgress(pr.Progress())
+	})
+}
+
+// ConcurrentlyWalkDirWithProgress behaves as `ConcurrentlyWalkDir`, but additionally calls `onProgress`
+// every `interval` with the number of files and bytes processed so far, and once more upon completion.
+// The totals are gathered by the same traversal which finds the files.
+// If `onProgress` is nil or `interval` is not positive, no progress is reported.
+func ConcurrentlyWalkDirWithProgress(dirPath string, onFile func(file string), interval time.Duration, onProgress func(WalkProgress)) error {
+	if onProgress == nil || interval <= 0 {
+		return ConcurrentlyWalkDir(dirPath, onFile)
+	}
+	files, err := findFiles(dirPath)
+	if err != nil {
+		return err
+	}
+	var bytesTotal int64
+	for _, file := range files {
+		bytesTotal += file.size
+	}
+	reporter := NewProgressReporter(int64(len(files)), bytesTotal, onProgress)
+	reporter.Start(interval)
+	defer reporter.Stop()
+	concurrentlyVisit(files, func(file foundFile) {
+		onFile(file.path)
+		reporter.FileDone(file.size)
+	})
 	return nil
 }
 
@@ -454,6 +652,30 @@ func GetImplementationUID(synthetic bool) string {
 	return fmt.Sprintf("%s%s.%s", OpenDCMRootUID, OpenDCMVersion, instanceType)
 }
 
+// ImplementationClassUID returns the UID to be written to (0002,0012) ImplementationClassUID.
+// Defaults to the non-synthetic `GetImplementationUID`, unless overridden by the configuration.
+func ImplementationClassUID() string {
+	if config.ImplementationClassUID != "" {
+		return config.ImplementationClassUID
+	}
+	return GetImplementationUID(false)
+}
+
+// ImplementationVersionName returns the name to be written to (0002,0013) ImplementationVersionName.
+// Defaults to "opendcm-<<VERSION>>", unless overridden by the configuration.
+func ImplementationVersionName() string {
+	if config.ImplementationVersionName != "" {
+		return config.ImplementationVersionName
+	}
+	return fmt.Sprintf("opendcm-%s", OpenDCMVersion)
+}
+
+// IsValidUID returns whether `uid` is a syntactically valid DICOM UID:
+// at most 64 characters, consisting of numeric components separated by dots.
+func IsValidUID(uid string) bool {
+	return len(uid) <= 64 && uniqueIdentifierRe.MatchString(uid)
+}
+
 // NewRandInstanceUID generates a DICOM random instance UID from OpenDCMRootUID
 func NewRandInstanceUID() (string, error) {
 	prefix := OpenDCMRootUID
@@ -465,3 +687,152 @@ func NewRandInstanceUID() (string, error) {
 	}
 	return fmt.Sprintf("%s%d", prefix, randval), nil
 }
+
+// NewDeterministicUID derives a DICOM instance UID from OpenDCMRootUID and `seed`, such that
+// the same seed always produces the same UID. This allows reproducible output, such as test
+// fixtures; it should not be used where the seed may be shared between distinct instances.
+func NewDeterministicUID(seed string) string {
+	prefix := OpenDCMRootUID
+	max := big.Int{}
+	max.SetString(strings.Repeat("9", 64-len(prefix)), 10)
+	digest := sha256.Sum256([]byte(seed))
+	val := big.Int{}
+	val.SetBytes(digest[:])
+	return fmt.Sprintf("%s%d", prefix, val.Mod(&val, &max))
+}
+
+// ParseResult holds the outcome of parsing one file within `ParseDirectory`.
+type ParseResult struct {
+	Path  string
+	Dicom Dicom
+	Err   error
+}
+
+// ParseDirectory recursively traverses `root`, parsing each file found using `workers` goroutines,
+// and sends the outcome of each to the returned channel. The channel is closed once all files
+// have been parsed. Errors encountered whilst traversing are sent as results without a Dicom.
+func ParseDirectory(root string, workers int) <-chan ParseResult {
+	return parseDirectory(root, workers, FromFile)
+}
+
+// parseDirectory implements `ParseDirectory`, parsing each file with `parse`.
+func parseDirectory(root string, workers int, parse func(path string) (Dicom, error)) <-chan ParseResult {
+	if workers < 1 {
+		workers = 1
+	}
+	paths := make(chan string)
+	results := make(chan ParseResult)
+	wg := sync.WaitGroup{}
+	wg.Add(workers + 1)
+	go func() {
+		defer wg.Done()
+		defer close(paths)
+		filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				// for a directory, returning nil skips its contents
+				results <- ParseResult{Path: filePath, Err: err}
+				return nil
+			}
+			if !info.IsDir() {
+				paths <- filePath
+			}
+			return nil
+		})
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				dcm, err := parse(path)
+				results <- ParseResult{Path: path, Dicom: dcm, Err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// ExportMetadata recursively traverses `root`, as per `ParseDirectory`, writing the values of `tags`
+// within each file to `w` as it is parsed, such that archives of any size may be exported. `format`
+// is either "ndjson", for one JSON object per line, or "csv", for one row per line following a header.
+// Elements are named by dictionary keyword (or by "gggg,eeee" if not in the dictionary), alongside the
+// "path" of the file. Values are as given by `Element.Describe`, except that sequences and binary
+// values are omitted. Values which are too large to be of interest (e.g. PixelData) are not read, and
+// files which cannot be parsed are logged and skipped.
+func ExportMetadata(root string, w io.Writer, format string, tags []uint32) error {
+	names := []string{"path"}
+	for _, tag := range tags {
+		e := NewElementWithTag(tag)
+		name, found := e.Keyword()
+		if !found {
+			name = fmt.Sprintf("%04X,%04X", tag>>16, tag&0xFFFF)
+		}
+		names = append(names, name)
+	}
+	var writeRow func(values []string, present []bool) error
+	switch format {
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		writeRow = func(values []string, present []bool) error {
+			object := make(map[string]string, len(values))
+			for i, value := range values {
+				if present[i] {
+					object[names[i]] = value
+				}
+			}
+			return encoder.Encode(object)
+		}
+	case "csv":
+		cw := csv.NewWriter(w)
+		writeRow = func(values []string, present []bool) error {
+			cw.Write(values)
+			cw.Flush()
+			return cw.Error()
+		}
+		if err := writeRow(names, nil); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("ExportMetadata(): format %q is neither \"ndjson\" nor \"csv\"", format)
+	}
+	parse := func(path string) (Dicom, error) {
+		dcm, result, err := FromFileLazy(path)
+		if err != nil {
+			return dcm, err
+		}
+		return dcm, result.Err
+	}
+	// results are written as they are received, such that only those being parsed are held in memory
+	var err error
+	for result := range parseDirectory(root, config.OpenFileLimit, parse) {
+		if err != nil {
+			// the remaining results must be received, such that the workers finish
+			continue
+		}
+		if result.Err != nil {
+			Errorf(`ExportMetadata(): error parsing "%s": %v`, result.Path, result.Err)
+			continue
+		}
+		values, present := []string{result.Path}, []bool{true}
+		for _, tag := range tags {
+			e := NewElement()
+			found := result.Dicom.GetElement(tag, &e)
+			values, present = append(values, metadataValue(e, found)), append(present, found)
+		}
+		err = writeRow(values, present)
+	}
+	return err
+}
+
+// metadataValue returns the value of `e` for `ExportMetadata`, or an empty string if it was not
+// `found`, is a sequence, or is a binary (O*, UN) value, which `Describe` would summarise.
+func metadataValue(e Element, found bool) string {
+	switch vr := e.GetVR(); {
+	case !found || e.IsLazy() || e.HasItems() || vr == "SQ" || vr == "UN" || strings.HasPrefix(vr, "O"):
+		return ""
+	}
+	return e.DescribeValue()
+}
@@ -2,12 +2,16 @@ package opendcm
 
 import (
 	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -146,6 +150,125 @@ func TestConcurrentlyWalkDir(t *testing.T) {
 	assert.NotEqual(t, 0, files)
 }
 
+func TestConcurrentlyWalkDirWithProgress(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "opendcm")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	for i := 0; i < 10; i++ {
+		f, err := ioutil.TempFile(tmpdir, strconv.Itoa(i))
+		assert.NoError(t, err)
+		_, err = f.Write(make([]byte, 16))
+		assert.NoError(t, err)
+		f.Close()
+	}
+	var last WalkProgress
+	err = ConcurrentlyWalkDirWithProgress(tmpdir, func(path string) {}, time.Millisecond, func(p WalkProgress) {
+		last = p
+	})
+	assert.NoError(t, err)
+	// final report is always made upon completion
+	assert.Equal(t, int64(10), last.FilesProcessed)
+	assert.Equal(t, int64(10), last.FilesTotal)
+	assert.Equal(t, int64(160), last.BytesProcessed)
+	assert.Equal(t, int64(160), last.BytesTotal)
+}
+
+func TestProgressReporterStop(t *testing.T) {
+	// ensures that Stop reports the final progress exactly once, whether or not
+	// Start was called, and that Start has no effect once stopped.
+	t.Parallel()
+	reports := 0
+	pr := NewProgressReporter(1, 16, func(p WalkProgress) { reports++ })
+	pr.FileDone(16)
+	pr.Stop()
+	pr.Stop()
+	pr.Start(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 1, reports)
+
+	started := NewProgressReporter(1, 16, func(p WalkProgress) {})
+	started.Start(time.Millisecond)
+	started.Start(time.Millisecond)
+	started.Stop()
+	started.Stop()
+}
+
+func TestParseDirectory(t *testing.T) {
+	// ensures that each file within the directory yields exactly one result.
+	t.Parallel()
+	tmpdir, err := ioutil.TempDir("", "opendcm")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.Mkdir(filepath.Join(tmpdir, "nested"), 0755))
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpdir, "nested", strconv.Itoa(i)), buf, 0644))
+	}
+	// not a dicom
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpdir, "invalid"), []byte("invalid"), 0644))
+
+	paths := make(map[string]bool)
+	numErrors := 0
+	for result := range ParseDirectory(tmpdir, 4) {
+		assert.False(t, paths[result.Path], result.Path)
+		paths[result.Path] = true
+		if result.Err != nil {
+			numErrors++
+			continue
+		}
+		assert.NotZero(t, result.Dicom.Len())
+	}
+	assert.Len(t, paths, 11)
+	assert.Equal(t, 1, numErrors)
+
+	// traversal errors are reported as results
+	results := make([]ParseResult, 0)
+	for result := range ParseDirectory(filepath.Join(tmpdir, "__missing"), 0) {
+		results = append(results, result)
+	}
+	if assert.Len(t, results, 1) {
+		assert.Error(t, results[0].Err)
+	}
+}
+
+func TestExportMetadata(t *testing.T) {
+	// ensures that a row is written for each parsable file, in either format.
+	t.Parallel()
+	tmpdir, err := ioutil.TempDir("", "opendcm")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpdir, strconv.Itoa(i)), buf, 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpdir, "invalid"), []byte("invalid"), 0644))
+	tags := []uint32{0x00080060, 0x00280010, 0x7FE00010, 0x00091001}
+
+	out := bytes.NewBuffer(nil)
+	assert.NoError(t, ExportMetadata(tmpdir, out, "ndjson", tags))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 3) {
+		object := map[string]string{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &object))
+		path := object["path"]
+		assert.Equal(t, tmpdir, filepath.Dir(path))
+		assert.Equal(t, map[string]string{"path": path, "Modality": "PT", "Rows": "168", "PixelData": ""}, object)
+	}
+
+	out.Reset()
+	assert.NoError(t, ExportMetadata(tmpdir, out, "csv", tags))
+	rows, err := csv.NewReader(out).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 4) {
+		assert.Equal(t, []string{"path", "Modality", "Rows", "PixelData", "0009,1001"}, rows[0])
+		assert.Equal(t, []string{"PT", "168", "", ""}, rows[1][1:])
+	}
+
+	assert.Error(t, ExportMetadata(tmpdir, out, "xml", tags))
+}
+
 func TestGetImplementationUID(t *testing.T) {
 	t.Parallel()
 	uid := GetImplementationUID(true)
@@ -156,6 +279,46 @@ func TestGetImplementationUID(t *testing.T) {
 	assert.Equal(t, expected, uid)
 }
 
+func TestImplementationIdentity(t *testing.T) {
+	defer OverrideConfig(config)
+	cfg := GetConfig()
+	cfg.ImplementationClassUID = ""
+	cfg.ImplementationVersionName = ""
+	OverrideConfig(cfg)
+	assert.Equal(t, GetImplementationUID(false), ImplementationClassUID())
+	assert.Equal(t, "opendcm-"+OpenDCMVersion, ImplementationVersionName())
+
+	cfg.ImplementationClassUID = "1.2.3.4"
+	cfg.ImplementationVersionName = "product-1.0"
+	OverrideConfig(cfg)
+	assert.Equal(t, "1.2.3.4", ImplementationClassUID())
+	assert.Equal(t, "product-1.0", ImplementationVersionName())
+
+	// as configured by opendcm-create, whose output is synthetic
+	cfg.ImplementationClassUID = GetImplementationUID(true)
+	OverrideConfig(cfg)
+	ds := newDataSet(newStringElement(0x00100010, "DOE^JOHN"))
+	buf := bytes.NewBuffer(nil)
+	_, err := ds.WriteTo(buf)
+	assert.NoError(t, err)
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	uid := ""
+	_, err = written.GetElementValue(0x00020012, &uid)
+	assert.NoError(t, err)
+	assert.Equal(t, GetImplementationUID(true), uid)
+}
+
+func TestIsValidUID(t *testing.T) {
+	t.Parallel()
+	assert.True(t, IsValidUID("1.2.840.10008.1.2"))
+	assert.True(t, IsValidUID(GetImplementationUID(false)))
+	assert.False(t, IsValidUID(""))
+	assert.False(t, IsValidUID("1.2..3"))
+	assert.False(t, IsValidUID("1.2.3a"))
+	assert.False(t, IsValidUID("1."+strings.Repeat("2", 64)))
+}
+
 func TestNewRandInstanceUID(t *testing.T) {
 	t.Parallel()
 	uid, err := NewRandInstanceUID()
@@ -164,6 +327,16 @@ func TestNewRandInstanceUID(t *testing.T) {
 	assert.Equal(t, 0, index)
 }
 
+func TestNewDeterministicUID(t *testing.T) {
+	t.Parallel()
+	uid := NewDeterministicUID("fixture")
+	assert.True(t, strings.HasPrefix(uid, OpenDCMRootUID))
+	assert.True(t, IsValidUID(uid), uid)
+	assert.Equal(t, uid, NewDeterministicUID("fixture"))
+	assert.NotEqual(t, uid, NewDeterministicUID("fixture2"))
+	assert.True(t, IsValidUID(NewDeterministicUID("")))
+}
+
 func TestColourForLevel(t *testing.T) {
 	t.Parallel()
 	assert.Equal(t, ansiMagenta, colourForLevel("D"))
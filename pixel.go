@@ -0,0 +1,989 @@
+package opendcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+===============================================================================
+	Pixel Decoding
+	---
+	Provides mechanisms for decoding the frames contained within PixelData
+	into images, according to the transfer syntax and the image pixel module.
+===============================================================================
+*/
+
+const (
+	// PixelDataProviderURL (0028,7FE0)
+	pixelDataProviderURLTag = uint32(0x00287FE0)
+
+	// FloatPixelData (7FE0,0008)
+	floatPixelDataTag = uint32(0x7FE00008)
+
+	// DoubleFloatPixelData (7FE0,0009)
+	doubleFloatPixelDataTag = uint32(0x7FE00009)
+
+	// IconImageSequence (0088,0200)
+	iconImageSequenceTag = uint32(0x00880200)
+
+	// PixelPaddingValue (0028,0120)
+	pixelPaddingValueTag = uint32(0x00280120)
+
+	// PixelPaddingRangeLimit (0028,0121)
+	pixelPaddingRangeLimitTag = uint32(0x00280121)
+
+	// PresentationLUTShape (2050,0020)
+	presentationLUTShapeTag = uint32(0x20500020)
+
+	// ExtendedOffsetTable (7FE0,0001)
+	extendedOffsetTableTag = uint32(0x7FE00001)
+
+	// ExtendedOffsetTableLengths (7FE0,0002)
+	extendedOffsetTableLengthsTag = uint32(0x7FE00002)
+)
+
+// ErrUnsupportedPixelEncoding is returned when pixel data is encoded in a manner
+// for which OpenDCM has no decoder, i.e. a compressed transfer syntax such as JPEG 2000.
+var ErrUnsupportedPixelEncoding = errors.New("pixel data encoding is not supported")
+
+// PixelModule contains the attributes of the Image Pixel Module which
+// describe how PixelData should be interpreted,
+// as per http://dicom.nema.org/dicom/2013/output/chtml/part03/sect_C.7.6.3.html
+type PixelModule struct {
+	Rows                      int
+	Columns                   int
+	SamplesPerPixel           int
+	BitsAllocated             int
+	BitsStored                int
+	PixelRepresentation       int
+	PlanarConfiguration       int
+	NumberOfFrames            int
+	PhotometricInterpretation string
+}
+
+// FrameSize returns the number of bytes occupied by one native (uncompressed) frame.
+func (pm *PixelModule) FrameSize() int {
+	return pm.Rows * pm.Columns * pm.SamplesPerPixel * (pm.BitsAllocated / 8)
+}
+
+// getUint16 writes the US value indexed by `tag` into `dst`, if present.
+func (ds *DataSet) getUint16(tag uint32, dst *int) error {
+	e := NewElement()
+	if !ds.GetElement(tag, &e) {
+		return nil
+	}
+	val := uint16(0)
+	if err := e.GetValue(&val); err != nil {
+		return err
+	}
+	*dst = int(val)
+	return nil
+}
+
+// ImageDimensions returns the values of (0028,0010) Rows and (0028,0011) Columns. An error is
+// returned if either is missing, zero, or not a US value. These are present at the top level of
+// both legacy and enhanced (multi-frame) objects, as each frame of the latter shares them.
+func (dcm *Dicom) ImageDimensions() (rows, cols int, err error) {
+	return dcm.DataSet.imageDimensions()
+}
+
+// imageDimensions returns the validated values of (0028,0010) Rows and (0028,0011) Columns.
+func (ds *DataSet) imageDimensions() (rows, cols int, err error) {
+	for tag, dst := range map[uint32]*int{0x00280010: &rows, 0x00280011: &cols} {
+		e := NewElementWithTag(tag)
+		if !ds.GetElement(tag, &e) {
+			return 0, 0, fmt.Errorf("ImageDimensions(): %s is missing", e.dictEntry)
+		}
+		val := uint16(0)
+		if err = e.GetValue(&val); err != nil {
+			return 0, 0, fmt.Errorf("ImageDimensions(): %s: %v", e.dictEntry, err)
+		}
+		if val == 0 {
+			return 0, 0, fmt.Errorf("ImageDimensions(): %s is zero", e.dictEntry)
+		}
+		*dst = int(val)
+	}
+	return rows, cols, nil
+}
+
+// GetPixelModule returns the Image Pixel Module attributes contained within the data set.
+// An error is returned if Rows, Columns or BitsAllocated are missing or invalid.
+func (ds *DataSet) GetPixelModule() (pm PixelModule, err error) {
+	pm = PixelModule{SamplesPerPixel: 1, NumberOfFrames: 1, PhotometricInterpretation: "MONOCHROME2"}
+	for tag, dst := range map[uint32]*int{
+		0x00280002: &pm.SamplesPerPixel,
+		0x00280006: &pm.PlanarConfiguration,
+		0x00280100: &pm.BitsAllocated,
+		0x00280101: &pm.BitsStored,
+		0x00280103: &pm.PixelRepresentation,
+	} {
+		if err = ds.getUint16(tag, dst); err != nil {
+			return
+		}
+	}
+	if pm.Rows, pm.Columns, err = ds.imageDimensions(); err != nil {
+		return
+	}
+	if pm.BitsAllocated != 8 && pm.BitsAllocated != 16 {
+		return pm, fmt.Errorf("GetPixelModule(): BitsAllocated of %d is not supported", pm.BitsAllocated)
+	}
+	if pm.BitsStored == 0 || pm.BitsStored > pm.BitsAllocated {
+		pm.BitsStored = pm.BitsAllocated
+	}
+	photometric := ""
+	if found, _ := ds.GetElementValue(0x00280004, &photometric); found {
+		pm.PhotometricInterpretation = strings.TrimSpace(photometric)
+	}
+	numFrames := ""
+	if found, _ := ds.GetElementValue(0x00280008, &numFrames); found {
+		if pm.NumberOfFrames, err = strconv.Atoi(strings.TrimSpace(numFrames)); err != nil {
+			return
+		}
+	}
+	return pm, nil
+}
+
+// GetTransferSyntax returns the value of (0002,0010) TransferSyntaxUID, or
+// Implicit VR Little Endian (the default transfer syntax) if absent. Padding, such as is
+// retained by `Dicom.Normalize`, is removed.
+func (ds *DataSet) GetTransferSyntax() string {
+	ts := ""
+	if found, _ := ds.GetElementValue(transferSyntaxTag, &ts); !found || strings.TrimRight(ts, "\x00 ") == "" {
+		return ImplicitVRLittleEndian
+	}
+	return strings.TrimRight(ts, "\x00 ")
+}
+
+// PresentationLUTShape returns the value of (2050,0020) PresentationLUTShape: IDENTITY, INVERSE,
+// or an empty string if absent.
+func (dcm *Dicom) PresentationLUTShape() string {
+	shape := ""
+	dcm.GetElementValue(presentationLUTShapeTag, &shape)
+	return strings.TrimSpace(shape)
+}
+
+// IsInverted returns whether the minimum sample value is intended to be displayed as white. This is
+// determined by `PresentationLUTShape` being INVERSE or, if it is absent, by (0028,0004)
+// PhotometricInterpretation being MONOCHROME1. Images returned by `Frame` have already been
+// inverted, such that they display correctly; raw samples (e.g. from `Samples`) have not.
+func (dcm *Dicom) IsInverted() bool {
+	switch dcm.PresentationLUTShape() {
+	case "INVERSE":
+		return true
+	case "IDENTITY":
+		return false
+	}
+	photometric := ""
+	if found, _ := dcm.GetElementValue(0x00280004, &photometric); !found {
+		return false
+	}
+	return strings.TrimSpace(photometric) == "MONOCHROME1"
+}
+
+// getSampleValue returns the value of the US or SS element indexed by `tag`, whose VR is
+// determined by (0028,0103) PixelRepresentation; as such, it is read as signed if `signed` is set.
+// Its return value (bool) indicates whether the element is present and valid.
+func (ds *DataSet) getSampleValue(tag uint32, signed bool) (int32, bool) {
+	e := NewElement()
+	if !ds.GetElement(tag, &e) {
+		return 0, false
+	}
+	val := uint16(0)
+	if e.GetVR() == "SS" {
+		signedVal := int16(0)
+		if err := e.GetValue(&signedVal); err != nil {
+			return 0, false
+		}
+		val = uint16(signedVal)
+	} else if err := e.GetValue(&val); err != nil {
+		return 0, false
+	}
+	if signed {
+		return int32(int16(val)), true
+	}
+	return int32(val), true
+}
+
+// PixelPadding returns (0028,0120) PixelPaddingValue and, if `hasRange`, (0028,0121)
+// PixelPaddingRangeLimit. Together, they denote the raw sample values of background pixels
+// which are not part of the image: either `value` alone, or every value between `value`
+// and `rangeLimit` inclusive. The values are signed if (0028,0103) PixelRepresentation is 1.
+// Its return value `present` indicates whether PixelPaddingValue is present.
+func (dcm *Dicom) PixelPadding() (value int32, hasRange bool, rangeLimit int32, present bool) {
+	pixelRepresentation := 0
+	if err := dcm.getUint16(0x00280103, &pixelRepresentation); err != nil {
+		return 0, false, 0, false
+	}
+	signed := pixelRepresentation == 1
+	if value, present = dcm.getSampleValue(pixelPaddingValueTag, signed); !present {
+		return 0, false, 0, false
+	}
+	rangeLimit, hasRange = dcm.getSampleValue(pixelPaddingRangeLimitTag, signed)
+	return value, hasRange, rangeLimit, true
+}
+
+// PixelDataURL returns the value of (0028,7FE0) PixelDataProviderURL, which is present when
+// pixel data is to be retrieved out-of-band (e.g. from a DICOMweb bulk data resource)
+// rather than being contained within (7FE0,0010) PixelData.
+func (dcm *Dicom) PixelDataURL() (string, bool) {
+	url := ""
+	if found, _ := dcm.GetElementValue(pixelDataProviderURLTag, &url); !found || url == "" {
+		return "", false
+	}
+	return strings.TrimSpace(url), true
+}
+
+// Frame decodes frame `index` into an image, choosing the decoder registered for
+// (0002,0010) TransferSyntaxUID and applying (0028,0004) PhotometricInterpretation.
+// Native, RLE Lossless and JPEG Baseline encodings are supported by default; others
+// result in `ErrUnsupportedPixelEncoding` unless registered with `RegisterTransferSyntax`.
+// Monochrome images are inverted if `IsInverted`, and pixels denoted as padding by `PixelPadding`
+// are mapped to black, such that the background is excluded from the displayed range.
+func (dcm *Dicom) Frame(index int) (image.Image, error) {
+	pm, err := dcm.GetPixelModule()
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= pm.NumberOfFrames {
+		return nil, fmt.Errorf("Frame(%d): dicom has %d frames", index, pm.NumberOfFrames)
+	}
+	decoder := getPixelDecoder(dcm.GetTransferSyntax())
+	if decoder == nil {
+		return nil, ErrUnsupportedPixelEncoding
+	}
+	img, err := decoder.DecodeFrame(&dcm.pixelData, pm, index)
+	if err != nil {
+		return nil, err
+	}
+	// monochrome images are inverted according to PhotometricInterpretation when decoded, but
+	// PresentationLUTShape takes precedence
+	switch pm.PhotometricInterpretation {
+	case "MONOCHROME1", "MONOCHROME2":
+		if inverted := dcm.IsInverted(); inverted != (pm.PhotometricInterpretation == "MONOCHROME1") {
+			invertMonochrome(img)
+			pm.PhotometricInterpretation = "MONOCHROME2"
+			if inverted {
+				pm.PhotometricInterpretation = "MONOCHROME1"
+			}
+		}
+	}
+	if value, hasRange, rangeLimit, present := dcm.PixelPadding(); present {
+		if !hasRange {
+			rangeLimit = value
+		}
+		pm.maskPadding(img, value, rangeLimit)
+	}
+	return img, nil
+}
+
+// IconImage decodes the thumbnail contained within (0088,0200) IconImageSequence. The icon has
+// its own pixel module and PixelData, which are encoded according to the dicom's transfer syntax;
+// as such, the full resolution pixel data need not be decoded.
+func (dcm *Dicom) IconImage() (image.Image, error) {
+	itm := Item{}
+	if !dcm.firstItem(iconImageSequenceTag, &itm) {
+		return nil, errors.New("IconImage(): dicom does not contain an Icon Image Sequence")
+	}
+	icon := newDicom()
+	for _, e := range itm.dataset {
+		icon.addElement(e)
+	}
+	ts := NewElement()
+	if dcm.GetElement(transferSyntaxTag, &ts) {
+		icon.addElement(ts)
+	}
+	pixelData := NewElement()
+	if !icon.GetElement(pixelDataTag, &pixelData) {
+		return nil, errors.New("IconImage(): Icon Image Sequence does not contain PixelData")
+	}
+	icon.onPixelData(pixelData)
+	return icon.Frame(0)
+}
+
+// nativeDecoder decodes uncompressed pixel data, in which all frames are contained
+// within a single value, encoded in the byte order of the transfer syntax.
+type nativeDecoder struct {
+	littleEndian bool
+}
+
+// DecodeFrame implements PixelDecoder
+func (nd nativeDecoder) DecodeFrame(pd *PixelData, pm PixelModule, index int) (image.Image, error) {
+	if pd.NumFrames() == 0 {
+		return nil, errors.New("Frame(): dicom does not contain native pixel data")
+	}
+	frame := pd.NativeFrames(pm)(index)
+	if frame == nil {
+		return nil, fmt.Errorf("Frame(%d): pixel data is truncated", index)
+	}
+	var bo binary.ByteOrder = binary.LittleEndian
+	if !nd.littleEndian != (config.ForcePixelByteSwap || pd.DetectByteSwap(pm.BitsAllocated)) {
+		bo = binary.BigEndian
+	}
+	return pm.toImage(frame, bo, pm.PlanarConfiguration == 1)
+}
+
+// NativeFrames returns a function which slices frame `i` of native pixel data, as described by
+// `pm`, from the single contiguous value in which all frames are held. No frame is copied, so
+// frames may be accessed on demand (e.g. for cine playback) without the cost of copying each
+// upfront; the returned slices must not be modified. Each frame is of `pm.FrameSize()` bytes
+// regardless of `pm.PlanarConfiguration`, which determines whether the samples of a colour
+// frame are interleaved (0) or held plane by plane (1).
+// The function returns nil if frame `i` does not exist, or is truncated.
+func (pd *PixelData) NativeFrames(pm PixelModule) func(i int) []byte {
+	var data []byte
+	if pd.NumFrames() > 0 && !pd.encapsulated {
+		data = pd.GetFrame(0)
+	}
+	size := pm.FrameSize()
+	return func(i int) []byte {
+		start, end := i*size, (i+1)*size
+		if i < 0 || size == 0 || end > len(data) {
+			return nil
+		}
+		return data[start:end:end]
+	}
+}
+
+// ReadFrame reads frame `frame` of the dicom file at `path`, without reading the PixelData
+// of any other frame. The file is parsed lazily to obtain its pixel module and to locate
+// PixelData, from which the frame is then read: native frames are located by their size, and
+// encapsulated frames by the Basic Offset Table, the Extended Offset Table or, if neither is
+// present, by assuming one fragment per frame. The frame is returned as stored, i.e. still compressed if encapsulated.
+// Deflated files are not supported, as their PixelData cannot be located without inflation.
+func ReadFrame(path string, frame int) ([]byte, PixelModule, error) {
+	dcm, result, err := FromFileLazy(path)
+	if err != nil {
+		return nil, PixelModule{}, err
+	}
+	if !result.Valid {
+		return nil, PixelModule{}, result.Err
+	}
+	pm, err := dcm.GetPixelModule()
+	if err != nil {
+		return nil, pm, err
+	}
+	if frame < 0 || frame >= pm.NumberOfFrames {
+		return nil, pm, fmt.Errorf("ReadFrame(%d): dicom has %d frames", frame, pm.NumberOfFrames)
+	}
+	if dcm.GetTransferSyntax() == DeflatedExplicitVRLittleEndian {
+		return nil, pm, errors.New("ReadFrame(): deflated dicoms are not supported")
+	}
+	pixelData := NewElement()
+	if !dcm.GetElement(pixelDataTag, &pixelData) {
+		return nil, pm, errors.New("ReadFrame(): dicom does not contain PixelData")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, pm, err
+	}
+	defer f.Close()
+	if !pixelData.HasItems() {
+		size, length := int64(pm.FrameSize()), int64(pixelData.datalen)
+		if !pixelData.lazy {
+			length = int64(len(pixelData.data))
+		}
+		if int64(frame+1)*size > length {
+			return nil, pm, fmt.Errorf("ReadFrame(%d): pixel data is truncated", frame)
+		}
+		if !pixelData.lazy {
+			return pixelData.data[int64(frame)*size : int64(frame+1)*size], pm, nil
+		}
+		data := make([]byte, size)
+		_, err = f.ReadAt(data, pixelData.valueOffset+int64(frame)*size)
+		return data, pm, err
+	}
+	for _, span := range dcm.layout {
+		if span.tag == pixelDataTag {
+			// encapsulated pixel data is always explicit VR: a 12 byte header precedes its items
+			extended, _ := dcm.extendedOffsetTable(f)
+			data, err := readEncapsulatedFrame(f, span.offset+12, frame, extended)
+			return data, pm, err
+		}
+	}
+	return nil, pm, errors.New("ReadFrame(): PixelData could not be located")
+}
+
+// readItemHeader reads the tag and length of the item header at `pos` within `r`.
+func readItemHeader(r io.ReaderAt, pos int64) (tag uint32, length uint32, err error) {
+	header := make([]byte, 8)
+	if _, err = r.ReadAt(header, pos); err != nil {
+		return 0, 0, err
+	}
+	tag = uint32(binary.LittleEndian.Uint16(header))<<16 | uint32(binary.LittleEndian.Uint16(header[2:]))
+	return tag, binary.LittleEndian.Uint32(header[4:]), nil
+}
+
+// readEncapsulatedFrame reads frame `frame` of the encapsulated pixel data whose first item,
+// the Basic Offset Table, is at `pos` within `r`. The fragments of the frame are concatenated.
+// The offsets of the Extended Offset Table, `extended`, are used if the Basic Offset Table is empty.
+func readEncapsulatedFrame(r io.ReaderAt, pos int64, frame int, extended []int64) ([]byte, error) {
+	tag, length, err := readItemHeader(r, pos)
+	if err != nil {
+		return nil, err
+	}
+	if tag != itemTag {
+		return nil, fmt.Errorf("ReadFrame(%d): expected Basic Offset Table item at offset %d", frame, pos)
+	}
+	offsetTable := make([]byte, length)
+	if _, err = r.ReadAt(offsetTable, pos+8); err != nil {
+		return nil, err
+	}
+	first := pos + 8 + int64(length)
+	// without an offset table, each fragment holds exactly one frame
+	start, end, fragments := first, int64(-1), 1
+	offsets := extended
+	if len(offsetTable) >= 4 {
+		offsets = make([]int64, len(offsetTable)/4)
+		for i := range offsets {
+			offsets[i] = int64(binary.LittleEndian.Uint32(offsetTable[i*4:]))
+		}
+	}
+	if n := len(offsets); n > 0 {
+		if frame >= n {
+			return nil, fmt.Errorf("ReadFrame(%d): offset table has %d entries", frame, n)
+		}
+		start = first + offsets[frame]
+		if frame+1 < n {
+			end = first + offsets[frame+1]
+		}
+		fragments = -1
+	} else {
+		for i := 0; i < frame; i++ {
+			if tag, length, err = readItemHeader(r, start); err != nil {
+				return nil, err
+			}
+			if tag != itemTag {
+				return nil, fmt.Errorf("ReadFrame(%d): pixel data contains %d fragments", frame, i)
+			}
+			start += 8 + int64(length)
+		}
+	}
+	data := make([]byte, 0)
+	for pos = start; (end < 0 || pos < end) && fragments != 0; fragments-- {
+		if tag, length, err = readItemHeader(r, pos); err != nil {
+			return nil, err
+		}
+		if tag != itemTag {
+			if len(data) == 0 {
+				return nil, fmt.Errorf("ReadFrame(%d): no fragment at offset %d", frame, pos)
+			}
+			break
+		}
+		fragment := make([]byte, length)
+		if _, err = r.ReadAt(fragment, pos+8); err != nil {
+			return nil, err
+		}
+		data = append(data, fragment...)
+		pos += 8 + int64(length)
+	}
+	return data, nil
+}
+
+// decodeRLEFrame decodes frame `index` of RLE Lossless encapsulated pixel data.
+func decodeRLEFrame(pd *PixelData, pm PixelModule, index int) (image.Image, error) {
+	if index >= pd.NumFrames() {
+		return nil, fmt.Errorf("Frame(%d): pixel data contains %d frames", index, pd.NumFrames())
+	}
+	data, err := DecodeRLEFrame(pd.GetFrame(index), pm.Rows, pm.Columns, pm.SamplesPerPixel, pm.BitsAllocated)
+	if err != nil {
+		return nil, err
+	}
+	// decoded RLE data is always little endian, and colour-by-plane
+	return pm.toImage(data, binary.LittleEndian, true)
+}
+
+// decodeJPEGFrame decodes frame `index` of JPEG Baseline encapsulated pixel data, applying
+// PhotometricInterpretation as `toImage` does: MONOCHROME1 is inverted, and the components of
+// RGB are not converted from YCbCr, as the decoder would otherwise presume them to be.
+func decodeJPEGFrame(pd *PixelData, pm PixelModule, index int) (image.Image, error) {
+	if index >= pd.NumFrames() {
+		return nil, fmt.Errorf("Frame(%d): pixel data contains %d frames", index, pd.NumFrames())
+	}
+	img, err := jpeg.Decode(bytes.NewReader(pd.GetFrame(index)))
+	if err != nil {
+		return nil, err
+	}
+	switch pm.PhotometricInterpretation {
+	case "MONOCHROME1":
+		invertMonochrome(img)
+	case "RGB":
+		if ycc, ok := img.(*image.YCbCr); ok {
+			rgb := image.NewRGBA(ycc.Rect)
+			for y := ycc.Rect.Min.Y; y < ycc.Rect.Max.Y; y++ {
+				for x := ycc.Rect.Min.X; x < ycc.Rect.Max.X; x++ {
+					yi, ci := ycc.YOffset(x, y), ycc.COffset(x, y)
+					rgb.SetRGBA(x, y, color.RGBA{R: ycc.Y[yi], G: ycc.Cb[ci], B: ycc.Cr[ci], A: 0xFF})
+				}
+			}
+			return rgb, nil
+		}
+	}
+	return img, nil
+}
+
+// roughness returns the mean absolute difference between consecutive 16-bit samples of `data`.
+func roughness(data []byte, bo binary.ByteOrder) float64 {
+	total, n := 0.0, 0
+	for i := 2; i+2 <= len(data); i += 2 {
+		total += math.Abs(float64(bo.Uint16(data[i:])) - float64(bo.Uint16(data[i-2:])))
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / float64(n)
+}
+
+// DetectByteSwap returns whether native 16-bit pixel data appears to have been encoded in the
+// opposite byte order to that of the transfer syntax, as some vendors do for big endian files.
+//
+// Neighbouring pixels of an image tend to have similar values. Swapping the bytes of each
+// sample moves the noisy low-order byte into the high-order position, so data which is
+// considerably smoother when swapped is likely to have been encoded in the wrong byte order.
+func (pd *PixelData) DetectByteSwap(bitsAllocated int) bool {
+	if bitsAllocated != 16 || pd.NumFrames() == 0 {
+		return false
+	}
+	data := pd.GetFrame(0)
+	// a sample of the data is sufficient
+	if len(data) > 1<<20 {
+		data = data[:1<<20]
+	}
+	var declared, swapped binary.ByteOrder = binary.LittleEndian, binary.BigEndian
+	if !pd.isLittleEndian {
+		declared, swapped = swapped, declared
+	}
+	return roughness(data, swapped)*4 < roughness(data, declared)
+}
+
+// Samples returns the native pixel data of all frames as a typed slice of samples, according to
+// `bitsAllocated` and `pixelRepresentation` (0 = unsigned, 1 = signed), decoded in the byte order
+// of the transfer syntax: one of []uint8, []int8, []uint16 or []int16.
+// Pixel data read from FloatPixelData or DoubleFloatPixelData is instead returned as
+// []float32 or []float64, with `bitsAllocated` of 32 or 64 respectively.
+func (pd *PixelData) Samples(bitsAllocated, pixelRepresentation int) (interface{}, error) {
+	if pd.NumFrames() == 0 {
+		return nil, errors.New("Samples(): pixel data is empty")
+	}
+	if pd.encapsulated {
+		return nil, ErrUnsupportedPixelEncoding
+	}
+	data := pd.GetFrame(0)
+	var bo binary.ByteOrder = binary.LittleEndian
+	if !pd.isLittleEndian {
+		bo = binary.BigEndian
+	}
+	signed := pixelRepresentation == 1
+	switch {
+	case pd.floating && bitsAllocated == 32:
+		samples := make([]float32, len(data)/4)
+		for i := range samples {
+			samples[i] = math.Float32frombits(bo.Uint32(data[i*4:]))
+		}
+		return samples, nil
+	case pd.floating && bitsAllocated == 64:
+		samples := make([]float64, len(data)/8)
+		for i := range samples {
+			samples[i] = math.Float64frombits(bo.Uint64(data[i*8:]))
+		}
+		return samples, nil
+	case pd.floating:
+		return nil, fmt.Errorf("Samples(%d, %d): floating point pixel data must have 32 or 64 bits allocated", bitsAllocated, pixelRepresentation)
+	case bitsAllocated == 8 && signed:
+		samples := make([]int8, len(data))
+		for i, b := range data {
+			samples[i] = int8(b)
+		}
+		return samples, nil
+	case bitsAllocated == 8:
+		return append([]uint8{}, data...), nil
+	case bitsAllocated == 16 && signed:
+		samples := make([]int16, len(data)/2)
+		for i := range samples {
+			samples[i] = int16(bo.Uint16(data[i*2:]))
+		}
+		return samples, nil
+	case bitsAllocated == 16:
+		samples := make([]uint16, len(data)/2)
+		for i := range samples {
+			samples[i] = bo.Uint16(data[i*2:])
+		}
+		return samples, nil
+	}
+	return nil, fmt.Errorf("Samples(%d, %d): BitsAllocated of %d is not supported", bitsAllocated, pixelRepresentation, bitsAllocated)
+}
+
+// PaletteLUT holds the red, green and blue lookup tables through which the indexed samples of
+// a PALETTE COLOR image are mapped, as per
+// http://dicom.nema.org/dicom/2013/output/chtml/part03/sect_C.7.6.3.html#sect_C.7.6.3.1.5
+type PaletteLUT struct {
+	// FirstMapped is the sample value which maps to the first entry of each table
+	FirstMapped int
+	// BitsPerEntry is the number of significant bits (8 or 16) of each entry
+	BitsPerEntry int
+	Red          []uint16
+	Green        []uint16
+	Blue         []uint16
+}
+
+// getPaletteTable decodes the lookup table described by the element indexed by `descriptorTag`,
+// whose entries are contained within the element indexed by `dataTag`.
+func (ds *DataSet) getPaletteTable(descriptorTag, dataTag uint32) (table []uint16, firstMapped int, bits int, err error) {
+	descriptor := []uint16{}
+	e := NewElementWithTag(descriptorTag)
+	if !ds.GetElement(descriptorTag, &e) {
+		return nil, 0, 0, fmt.Errorf("%s is missing", e.dictEntry)
+	}
+	if err = e.GetValue(&descriptor); err != nil {
+		return
+	}
+	if len(descriptor) != 3 {
+		return nil, 0, 0, fmt.Errorf("%s contains %d values; expected 3", e.dictEntry, len(descriptor))
+	}
+	entries, firstMapped, bits := int(descriptor[0]), int(descriptor[1]), int(descriptor[2])
+	if entries == 0 {
+		entries = 65536
+	}
+	if bits != 8 && bits != 16 {
+		return nil, 0, 0, fmt.Errorf("%s specifies %d bits per entry; expected 8 or 16", e.dictEntry, bits)
+	}
+	data := NewElementWithTag(dataTag)
+	if !ds.GetElement(dataTag, &data) {
+		return nil, 0, 0, fmt.Errorf("%s is missing", data.dictEntry)
+	}
+	table = make([]uint16, entries)
+	if bits == 8 && (len(data.data) == entries || len(data.data) == entries+1) {
+		// 8 bit entries may be packed, rather than occupying a 16 bit word each
+		for i := range table {
+			table[i] = uint16(data.data[i])
+		}
+		return table, firstMapped, bits, nil
+	}
+	if len(data.data) < entries*2 {
+		return nil, 0, 0, fmt.Errorf("%s contains %d bytes; expected %d entries", data.dictEntry, len(data.data), entries)
+	}
+	var bo binary.ByteOrder = binary.LittleEndian
+	if !data.isLittleEndian {
+		bo = binary.BigEndian
+	}
+	for i := range table {
+		table[i] = bo.Uint16(data.data[i*2:])
+		if bits == 8 {
+			table[i] &= 0xFF
+		}
+	}
+	return table, firstMapped, bits, nil
+}
+
+// PaletteLUT returns the lookup tables of a PALETTE COLOR image, as described by (0028,1101-1103)
+// Red, Green and Blue Palette Color Lookup Table Descriptor, and contained within (0028,1201-1203)
+// Red, Green and Blue Palette Color Lookup Table Data. Segmented tables are not supported.
+func (dcm *Dicom) PaletteLUT() (*PaletteLUT, error) {
+	lut := PaletteLUT{}
+	tables := []*[]uint16{&lut.Red, &lut.Green, &lut.Blue}
+	for i, dst := range tables {
+		table, firstMapped, bits, err := dcm.getPaletteTable(0x00281101+uint32(i), 0x00281201+uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && (len(table) != len(lut.Red) || firstMapped != lut.FirstMapped || bits != lut.BitsPerEntry) {
+			return nil, errors.New("PaletteLUT(): the red, green and blue descriptors differ")
+		}
+		*dst, lut.FirstMapped, lut.BitsPerEntry = table, firstMapped, bits
+	}
+	return &lut, nil
+}
+
+// ApplyPalette maps the indexed samples of one frame, `pixels`, through the dicom's palette
+// lookup tables into an image. Samples outside of the range of the tables are mapped to their
+// first or last entry.
+func (dcm *Dicom) ApplyPalette(pixels []uint16) (image.Image, error) {
+	pm, err := dcm.GetPixelModule()
+	if err != nil {
+		return nil, err
+	}
+	if len(pixels) != pm.Rows*pm.Columns {
+		return nil, fmt.Errorf("ApplyPalette(): %d samples given for a %dx%d image", len(pixels), pm.Columns, pm.Rows)
+	}
+	lut, err := dcm.PaletteLUT()
+	if err != nil {
+		return nil, err
+	}
+	// scale 8 bit entries onto the full range of the output image
+	scale := uint16(1)
+	if lut.BitsPerEntry == 8 {
+		scale = 0x0101
+	}
+	img := image.NewRGBA64(image.Rect(0, 0, pm.Columns, pm.Rows))
+	for i, p := range pixels {
+		index := int(p) - lut.FirstMapped
+		if index < 0 {
+			index = 0
+		} else if index >= len(lut.Red) {
+			index = len(lut.Red) - 1
+		}
+		img.SetRGBA64(i%pm.Columns, i/pm.Columns, color.RGBA64{
+			R: lut.Red[index] * scale,
+			G: lut.Green[index] * scale,
+			B: lut.Blue[index] * scale,
+			A: 0xFFFF,
+		})
+	}
+	return img, nil
+}
+
+// ExtractEmbeddedOverlay returns the overlay of repeating group `group` (0x6000 to 0x601E) which
+// is embedded within the unused high bits of the samples of the first frame of PixelData, as was
+// permitted by the retired (60xx,0102) OverlayBitPosition. The overlay is returned as one value
+// per pixel, in row-major order, which is set where the overlay bit of its sample is set.
+// Frames decoded by `Frame` are unaffected by embedded overlays, as only BitsStored bits
+// of each sample are considered; raw samples (e.g. from `Samples`) must be masked.
+func (dcm *Dicom) ExtractEmbeddedOverlay(group uint16) ([]bool, error) {
+	if group < 0x6000 || group > 0x601E || group%2 != 0 {
+		return nil, fmt.Errorf("ExtractEmbeddedOverlay(%04X): not an overlay group", group)
+	}
+	pm, err := dcm.GetPixelModule()
+	if err != nil {
+		return nil, err
+	}
+	base := uint32(group) << 16
+	if dcm.HasElement(base | 0x3000) {
+		return nil, fmt.Errorf("ExtractEmbeddedOverlay(%04X): overlay is held within OverlayData, rather than PixelData", group)
+	}
+	rows, cols, bitsAllocated, bitPosition := pm.Rows, pm.Columns, 0, 0
+	for tag, dst := range map[uint32]*int{
+		base | 0x0010: &rows,
+		base | 0x0011: &cols,
+		base | 0x0100: &bitsAllocated,
+		base | 0x0102: &bitPosition,
+	} {
+		if err := dcm.getUint16(tag, dst); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case bitsAllocated != pm.BitsAllocated:
+		return nil, fmt.Errorf("ExtractEmbeddedOverlay(%04X): OverlayBitsAllocated of %d does not match BitsAllocated", group, bitsAllocated)
+	case bitPosition < pm.BitsStored || bitPosition >= pm.BitsAllocated:
+		return nil, fmt.Errorf("ExtractEmbeddedOverlay(%04X): OverlayBitPosition of %d is not an unused bit of the samples", group, bitPosition)
+	case rows != pm.Rows || cols != pm.Columns:
+		return nil, fmt.Errorf("ExtractEmbeddedOverlay(%04X): overlay is %dx%d, but the image is %dx%d", group, cols, rows, pm.Columns, pm.Rows)
+	case pm.SamplesPerPixel != 1:
+		return nil, ErrUnsupportedPixelEncoding
+	}
+	if pd := &dcm.pixelData; pd.encapsulated || pd.floating {
+		return nil, ErrUnsupportedPixelEncoding
+	}
+	frame := dcm.pixelData.NativeFrames(pm)(0)
+	if frame == nil {
+		return nil, errors.New("ExtractEmbeddedOverlay(): dicom does not contain native pixel data")
+	}
+	// the overlay bit is not smooth, so would mislead byte swap detection
+	var bo binary.ByteOrder = binary.LittleEndian
+	if !dcm.pixelData.isLittleEndian != config.ForcePixelByteSwap {
+		bo = binary.BigEndian
+	}
+	overlay := make([]bool, rows*cols)
+	for i := range overlay {
+		sample := uint16(frame[i])
+		if pm.BitsAllocated == 16 {
+			sample = bo.Uint16(frame[i*2:])
+		}
+		overlay[i] = sample&(1<<uint(bitPosition)) != 0
+	}
+	return overlay, nil
+}
+
+// toImage converts one frame of native pixel data into an image, according to the pixel module.
+// Monochrome samples are scaled from BitsStored to the full range of the output image.
+func (pm *PixelModule) toImage(data []byte, bo binary.ByteOrder, planar bool) (image.Image, error) {
+	rect := image.Rect(0, 0, pm.Columns, pm.Rows)
+	nPixels := pm.Rows * pm.Columns
+	switch pm.PhotometricInterpretation {
+	case "MONOCHROME1", "MONOCHROME2":
+		inverted := pm.PhotometricInterpretation == "MONOCHROME1"
+		if pm.BitsAllocated == 8 {
+			img := image.NewGray(rect)
+			for i := 0; i < nPixels; i++ {
+				v := uint8(pm.normaliseSample(uint32(data[i])) >> 8)
+				if inverted {
+					v = 0xFF - v
+				}
+				img.Pix[i] = v
+			}
+			return img, nil
+		}
+		img := image.NewGray16(rect)
+		for i := 0; i < nPixels; i++ {
+			v := pm.normaliseSample(uint32(bo.Uint16(data[i*2:])))
+			if inverted {
+				v = 0xFFFF - v
+			}
+			img.SetGray16(i%pm.Columns, i/pm.Columns, color.Gray16{Y: v})
+		}
+		return img, nil
+	case "RGB", "YBR_FULL":
+		if pm.BitsAllocated != 8 || pm.SamplesPerPixel != 3 {
+			return nil, ErrUnsupportedPixelEncoding
+		}
+		img := image.NewRGBA(rect)
+		for i := 0; i < nPixels; i++ {
+			var a, b, c uint8
+			if planar {
+				a, b, c = data[i], data[nPixels+i], data[2*nPixels+i]
+			} else {
+				a, b, c = data[i*3], data[i*3+1], data[i*3+2]
+			}
+			if pm.PhotometricInterpretation == "YBR_FULL" {
+				a, b, c = color.YCbCrToRGB(a, b, c)
+			}
+			img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = a, b, c, 0xFF
+		}
+		return img, nil
+	}
+	return nil, ErrUnsupportedPixelEncoding
+}
+
+// invertMonochrome inverts each pixel of a monochrome image, as returned by `toImage`.
+func invertMonochrome(img image.Image) {
+	switch typed := img.(type) {
+	case *image.Gray:
+		for i, v := range typed.Pix {
+			typed.Pix[i] = 0xFF - v
+		}
+	case *image.Gray16:
+		// inverting each byte of a big endian sample inverts the sample
+		for i, v := range typed.Pix {
+			typed.Pix[i] = 0xFF - v
+		}
+	}
+}
+
+// maskPadding sets the pixels of a monochrome image, as returned by `toImage`, to black where
+// their raw sample lies between `value` and `rangeLimit` inclusive.
+func (pm *PixelModule) maskPadding(img image.Image, value, rangeLimit int32) {
+	if value > rangeLimit {
+		value, rangeLimit = rangeLimit, value
+	}
+	// normalisation preserves the order of raw samples, so the range remains contiguous
+	output := func(raw int32) uint16 {
+		v := pm.normaliseSample(uint32(raw))
+		if pm.PhotometricInterpretation == "MONOCHROME1" {
+			v = 0xFFFF - v
+		}
+		return v
+	}
+	lo, hi := output(value), output(rangeLimit)
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	switch typed := img.(type) {
+	case *image.Gray:
+		for i, v := range typed.Pix {
+			if uint16(v) >= lo>>8 && uint16(v) <= hi>>8 {
+				typed.Pix[i] = 0
+			}
+		}
+	case *image.Gray16:
+		for i := 0; i+1 < len(typed.Pix); i += 2 {
+			if v := binary.BigEndian.Uint16(typed.Pix[i:]); v >= lo && v <= hi {
+				typed.Pix[i], typed.Pix[i+1] = 0, 0
+			}
+		}
+	}
+}
+
+// normaliseSample maps a raw monochrome sample of BitsStored bits onto the range of a uint16,
+// taking into account its signedness (PixelRepresentation).
+func (pm *PixelModule) normaliseSample(raw uint32) uint16 {
+	mask := uint32(1)<<uint(pm.BitsStored) - 1
+	v := raw & mask
+	if pm.PixelRepresentation == 1 {
+		// two's complement: flip the sign bit to shift the range to be non-negative
+		v ^= uint32(1) << uint(pm.BitsStored-1)
+	}
+	return uint16(v << uint(16-pm.BitsStored))
+}
+
+// DecodeRLEFrame decodes one frame of RLE Lossless compressed data, being the single fragment
+// which holds it, into native, colour-by-plane, little endian pixel data, as per
+// http://dicom.nema.org/dicom/2013/output/chtml/part05/chapter_G.html
+// The frame begins with a 64-byte header: the number of segments (1-15), followed by the offset
+// of each. Each segment holds one byte of each sample, most significant first, PackBits
+// compressed. The final segment extends to the end of the frame, which may have been padded to
+// even length; any bytes beyond those of the `rows*cols` pixels are ignored.
+func DecodeRLEFrame(frame []byte, rows, cols, samplesPerPixel, bitsAllocated int) ([]byte, error) {
+	if len(frame) < 64 {
+		return nil, errors.New("DecodeRLEFrame(): frame is shorter than the RLE header")
+	}
+	nSegments := int(binary.LittleEndian.Uint32(frame[0:4]))
+	if nSegments < 1 || nSegments > 15 {
+		return nil, fmt.Errorf("DecodeRLEFrame(): header declares %d segments, but there may be 1 to 15", nSegments)
+	}
+	bytesPerSample := bitsAllocated / 8
+	if nSegments != samplesPerPixel*bytesPerSample {
+		return nil, fmt.Errorf("DecodeRLEFrame(): expected %d segments, found %d", samplesPerPixel*bytesPerSample, nSegments)
+	}
+	nPixels := rows * cols
+	out := make([]byte, nPixels*nSegments)
+	for seg := 0; seg < nSegments; seg++ {
+		start := int(binary.LittleEndian.Uint32(frame[4+seg*4:]))
+		end := len(frame)
+		if seg+1 < nSegments {
+			end = int(binary.LittleEndian.Uint32(frame[4+(seg+1)*4:]))
+		}
+		if start < 64 || start > end || end > len(frame) {
+			return nil, fmt.Errorf("DecodeRLEFrame(): segment %d has invalid offsets", seg+1)
+		}
+		decoded := decodePackBits(frame[start:end], nPixels)
+		if len(decoded) < nPixels {
+			return nil, fmt.Errorf("DecodeRLEFrame(): segment %d decodes to %d bytes, expected %d", seg+1, len(decoded), nPixels)
+		}
+		// segments are ordered most significant byte first, for each sample in turn
+		sample, byteIndex := seg/bytesPerSample, bytesPerSample-1-seg%bytesPerSample
+		for i := 0; i < nPixels; i++ {
+			out[(sample*nPixels+i)*bytesPerSample+byteIndex] = decoded[i]
+		}
+	}
+	return out, nil
+}
+
+// decodePackBits decompresses a PackBits-encoded RLE segment, stopping after `n` bytes.
+func decodePackBits(src []byte, n int) []byte {
+	dst := make([]byte, 0, n)
+	for i := 0; i < len(src) && len(dst) < n; {
+		header := int8(src[i])
+		i++
+		switch {
+		case header >= 0:
+			// copy the next header+1 bytes literally
+			count := int(header) + 1
+			if i+count > len(src) {
+				count = len(src) - i
+			}
+			dst = append(dst, src[i:i+count]...)
+			i += count
+		case header != -128:
+			// replicate the next byte -header+1 times
+			if i >= len(src) {
+				return dst
+			}
+			for j := 0; j < int(-header)+1; j++ {
+				dst = append(dst, src[i])
+			}
+			i++
+		}
+	}
+	return dst
+}
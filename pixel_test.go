@@ -0,0 +1,609 @@
+package opendcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newUSElement returns a little endian US element indexed by `tag` with value `v`.
+func newUSElement(tag uint32, v uint16) Element {
+	e := NewElementWithTag(tag)
+	e.data = make([]byte, 2)
+	binary.LittleEndian.PutUint16(e.data, v)
+	return e
+}
+
+// newStringElement returns an element indexed by `tag` with value `v`.
+func newStringElement(tag uint32, v string) Element {
+	e := NewElementWithTag(tag)
+	e.data = []byte(v)
+	e.datalen = uint32(len(e.data))
+	return e
+}
+
+// newPixelDicom returns a Dicom with a pixel module describing a `rows` x `cols` image.
+func newPixelDicom(ts string, rows, cols, spp, bitsAllocated uint16, photometric string) Dicom {
+	dcm := newDicom()
+	dcm.addElement(newStringElement(transferSyntaxTag, ts))
+	dcm.addElement(newUSElement(0x00280002, spp))
+	dcm.addElement(newUSElement(0x00280010, rows))
+	dcm.addElement(newUSElement(0x00280011, cols))
+	dcm.addElement(newUSElement(0x00280100, bitsAllocated))
+	dcm.addElement(newUSElement(0x00280101, bitsAllocated))
+	dcm.addElement(newStringElement(0x00280004, photometric))
+	return dcm
+}
+
+func TestGetPixelModule(t *testing.T) {
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	pm, err := dcm.GetPixelModule()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pm.SamplesPerPixel)
+	assert.Equal(t, 16, pm.BitsAllocated)
+	assert.Equal(t, pm.Rows*pm.Columns*2, pm.FrameSize())
+
+	// missing rows / columns
+	ds := make(DataSet, 0)
+	_, err = ds.GetPixelModule()
+	assert.Error(t, err)
+}
+
+func TestImageDimensions(t *testing.T) {
+	// ensures that Rows and Columns are read, and that absent, zero or malformed values are reported.
+	t.Parallel()
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 3, 4, 1, 8, "MONOCHROME2")
+	rows, cols, err := dcm.ImageDimensions()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, rows)
+	assert.Equal(t, 4, cols)
+
+	dcm.addElement(newUSElement(0x00280011, 0))
+	_, _, err = dcm.ImageDimensions()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Columns is zero")
+	}
+	dcm.addElement(newStringElement(0x00280011, "4"))
+	_, _, err = dcm.ImageDimensions()
+	assert.Error(t, err)
+	delete(dcm.DataSet, 0x00280011)
+	_, _, err = dcm.ImageDimensions()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Columns is missing")
+	}
+}
+
+func TestFrameNative(t *testing.T) {
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	pm, err := dcm.GetPixelModule()
+	assert.NoError(t, err)
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.IsType(t, &image.Gray16{}, img)
+	assert.Equal(t, image.Rect(0, 0, pm.Columns, pm.Rows), img.Bounds())
+
+	// out of range
+	_, err = dcm.Frame(pm.NumberOfFrames)
+	assert.Error(t, err)
+}
+
+func TestDetectByteSwap(t *testing.T) {
+	// ensures that byte-swapped 16-bit pixel data is detected, and that
+	// correctly encoded pixel data is not.
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	pd := dcm.GetPixelData()
+	assert.False(t, pd.DetectByteSwap(16))
+	assert.False(t, pd.DetectByteSwap(8))
+
+	swapped := newPixelData()
+	swapped.addFrame(swapByteOrder(pd.GetFrame(0), 2), false)
+	assert.True(t, swapped.DetectByteSwap(16))
+	// declared as big endian, the swapped data is correct
+	swapped.isLittleEndian = false
+	assert.False(t, swapped.DetectByteSwap(16))
+}
+
+func TestFrameForcePixelByteSwap(t *testing.T) {
+	defer OverrideConfig(config)
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 1, 2, 1, 16, "MONOCHROME2")
+	dcm.pixelData.frames = [][]byte{{0x00, 0x01, 0x00, 0x02}}
+	cfg := GetConfig()
+	cfg.ForcePixelByteSwap = true
+	OverrideConfig(cfg)
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0x00, 0x01, 0x00, 0x02}, img.(*image.Gray16).Pix)
+}
+
+func TestFrameMonochrome1(t *testing.T) {
+	t.Parallel()
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 1, 2, 1, 8, "MONOCHROME1")
+	assert.True(t, dcm.IsInverted())
+	dcm.pixelData.frames = [][]byte{{0x00, 0xFF}}
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0xFF, 0x00}, img.(*image.Gray).Pix)
+
+	dcm = newPixelDicom(ExplicitVRLittleEndian, 1, 2, 1, 8, "MONOCHROME2")
+	assert.False(t, dcm.IsInverted())
+	dcm = newDicom()
+	assert.False(t, dcm.IsInverted())
+}
+
+func TestFramePresentationLUTShape(t *testing.T) {
+	// ensures that PresentationLUTShape takes precedence over PhotometricInterpretation.
+	t.Parallel()
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 1, 2, 1, 16, "MONOCHROME2")
+	dcm.pixelData.frames = [][]byte{{0x00, 0x00, 0xFF, 0xFF}}
+	assert.Equal(t, "", dcm.PresentationLUTShape())
+	dcm.addElement(newStringElement(presentationLUTShapeTag, "INVERSE "))
+	assert.Equal(t, "INVERSE", dcm.PresentationLUTShape())
+	assert.True(t, dcm.IsInverted())
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0xFF, 0xFF, 0x00, 0x00}, img.(*image.Gray16).Pix)
+
+	// padding remains black once inverted
+	dcm.addElement(newUSElement(pixelPaddingValueTag, 0x0000))
+	img, err = dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0x00, 0x00, 0x00, 0x00}, img.(*image.Gray16).Pix)
+
+	dcm = newPixelDicom(ExplicitVRLittleEndian, 1, 2, 1, 8, "MONOCHROME1")
+	dcm.addElement(newStringElement(presentationLUTShapeTag, "IDENTITY"))
+	dcm.pixelData.frames = [][]byte{{0x00, 0xFF}}
+	assert.False(t, dcm.IsInverted())
+	img, err = dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0x00, 0xFF}, img.(*image.Gray).Pix)
+}
+
+func TestFramePixelPadding(t *testing.T) {
+	// ensures that padding values, and ranges, are read according to PixelRepresentation,
+	// and that padded pixels are mapped to black.
+	t.Parallel()
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 1, 4, 1, 8, "MONOCHROME2")
+	dcm.pixelData.frames = [][]byte{{0x00, 0x40, 0xFE, 0xFF}}
+	_, _, _, present := dcm.PixelPadding()
+	assert.False(t, present)
+	dcm.addElement(newUSElement(pixelPaddingValueTag, 0xFF))
+	value, hasRange, _, present := dcm.PixelPadding()
+	assert.True(t, present)
+	assert.False(t, hasRange)
+	assert.Equal(t, int32(0xFF), value)
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0x00, 0x40, 0xFE, 0x00}, img.(*image.Gray).Pix)
+
+	// a range, given in descending order, of an inverted image
+	dcm.addElement(newStringElement(0x00280004, "MONOCHROME1"))
+	dcm.addElement(newUSElement(pixelPaddingRangeLimitTag, 0xFE))
+	img, err = dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0xFF, 0xBF, 0x00, 0x00}, img.(*image.Gray).Pix)
+
+	// signed samples
+	dcm = newPixelDicom(ExplicitVRLittleEndian, 1, 3, 1, 16, "MONOCHROME2")
+	dcm.addElement(newUSElement(0x00280103, 1))
+	dcm.addElement(newUSElement(pixelPaddingValueTag, 0xFC00))
+	dcm.addElement(newUSElement(pixelPaddingRangeLimitTag, 0xFC18))
+	dcm.pixelData.isLittleEndian = true
+	dcm.pixelData.frames = [][]byte{{0x17, 0xFC, 0x18, 0xFC, 0x19, 0xFC}}
+	value, hasRange, rangeLimit, present := dcm.PixelPadding()
+	assert.True(t, present)
+	assert.True(t, hasRange)
+	assert.Equal(t, int32(-1024), value)
+	assert.Equal(t, int32(-1000), rangeLimit)
+	img, err = dcm.Frame(0)
+	assert.NoError(t, err)
+	gray := img.(*image.Gray16)
+	assert.Equal(t, uint16(0), gray.Gray16At(0, 0).Y)
+	assert.Equal(t, uint16(0), gray.Gray16At(1, 0).Y)
+	assert.Equal(t, uint16(0x7C19), gray.Gray16At(2, 0).Y)
+}
+
+func TestExtractEmbeddedOverlay(t *testing.T) {
+	// ensures that the overlay bit plane is extracted from the unused high bits of the
+	// samples, and that it does not contaminate the decoded frame.
+	t.Parallel()
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 2, 2, 1, 16, "MONOCHROME2")
+	dcm.addElement(newUSElement(0x00280101, 12))
+	dcm.pixelData.isLittleEndian = true
+	dcm.pixelData.frames = [][]byte{{0xFF, 0x0F, 0xFF, 0x8F, 0x00, 0x80, 0x00, 0x00}}
+	_, err := dcm.ExtractEmbeddedOverlay(0x6000)
+	assert.Error(t, err)
+	dcm.addElement(newUSElement(0x60000100, 16))
+	dcm.addElement(newUSElement(0x60000102, 15))
+	overlay, err := dcm.ExtractEmbeddedOverlay(0x6000)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, true, true, false}, overlay)
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	gray := img.(*image.Gray16)
+	assert.Equal(t, gray.Gray16At(0, 0), gray.Gray16At(1, 0))
+	assert.Equal(t, gray.Gray16At(0, 1), gray.Gray16At(1, 1))
+
+	// the overlay bit must not be a stored bit, and the group must be an overlay group
+	dcm.addElement(newUSElement(0x60000102, 11))
+	_, err = dcm.ExtractEmbeddedOverlay(0x6000)
+	assert.Error(t, err)
+	_, err = dcm.ExtractEmbeddedOverlay(0x6001)
+	assert.Error(t, err)
+}
+
+func TestNativeFrames(t *testing.T) {
+	// ensures that frames are sliced, rather than copied, from the contiguous value.
+	t.Parallel()
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 1, 2, 3, 8, "RGB")
+	dcm.addElement(newUSElement(0x00280006, 1))
+	data := []byte{
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+		0x11, 0x12, 0x13, 0x14, 0x15, 0x16,
+		0x21, 0x22, 0x23, 0x24, 0x25, 0x26,
+		0x31, // truncated fourth frame
+	}
+	dcm.pixelData.frames = [][]byte{data}
+	pm, err := dcm.GetPixelModule()
+	assert.NoError(t, err)
+	frames := dcm.GetPixelData().NativeFrames(pm)
+	assert.Equal(t, []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16}, frames(1))
+	assert.True(t, &data[12] == &frames(2)[0])
+	assert.Nil(t, frames(3))
+	assert.Nil(t, frames(-1))
+
+	// the frame is interpreted according to PlanarConfiguration
+	img, err := pm.toImage(frames(0), binary.LittleEndian, pm.PlanarConfiguration == 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0x01, 0x03, 0x05, 0xFF, 0x02, 0x04, 0x06, 0xFF}, img.(*image.RGBA).Pix)
+
+	// encapsulated pixel data has no native frames
+	dcm.pixelData.encapsulated = true
+	assert.Nil(t, dcm.GetPixelData().NativeFrames(pm)(0))
+}
+
+func TestReadFrame(t *testing.T) {
+	// ensures that a single frame is read from native and encapsulated pixel data,
+	// with and without an offset table.
+	t.Parallel()
+	writeTemp := func(dcm Dicom) string {
+		f, err := ioutil.TempFile("", "opendcm")
+		assert.NoError(t, err)
+		f.Close()
+		assert.NoError(t, dcm.WriteToFile(f.Name()))
+		return f.Name()
+	}
+
+	// native, large enough to be skipped by the lazy parse
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 32, 32, 1, 8, "MONOCHROME2")
+	dcm.addElement(newStringElement(0x00280008, "3"))
+	pixelData := NewElementWithTag(pixelDataTag)
+	for i := 0; i < 3; i++ {
+		pixelData.data = append(pixelData.data, bytes.Repeat([]byte{byte(i + 1)}, 32*32)...)
+	}
+	dcm.addElement(pixelData)
+	path := writeTemp(dcm)
+	defer os.Remove(path)
+	frame, pm, err := ReadFrame(path, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pm.NumberOfFrames)
+	assert.Equal(t, bytes.Repeat([]byte{0x02}, 32*32), frame)
+	_, _, err = ReadFrame(path, 3)
+	assert.Error(t, err)
+
+	// encapsulated, with and without an offset table
+	for _, withOffsetTable := range []bool{true, false} {
+		dcm = newPixelDicom(JPEGBaseline, 1, 1, 1, 8, "MONOCHROME2")
+		dcm.addElement(newStringElement(0x00280008, "3"))
+		frames := [][]byte{{0x01, 0x02}, bytes.Repeat([]byte{0x03}, 2048), {0x04, 0x05}}
+		encapsulated := dcm.GetPixelData().Encapsulate(frames, withOffsetTable)
+		buf := bytes.NewBuffer(nil)
+		assert.NoError(t, dcm.Write(buf))
+		input := append(buf.Bytes(), 0xE0, 0x7F, 0x10, 0x00, 'O', 'B', 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF)
+		input = append(input, encapsulated...)
+		f, err := ioutil.TempFile("", "opendcm")
+		assert.NoError(t, err)
+		_, err = f.Write(input)
+		assert.NoError(t, err)
+		f.Close()
+		defer os.Remove(f.Name())
+		for i, expected := range frames {
+			frame, _, err = ReadFrame(f.Name(), i)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, frame, "frame %d, offset table: %v", i, withOffsetTable)
+		}
+	}
+}
+
+func TestExtendedOffsetTable(t *testing.T) {
+	// ensures that, when the Basic Offset Table is empty, frames are located by the
+	// 64-bit Extended Offset Table, both when parsed and when read with `ReadFrame`.
+	// the fixture's first frame spans two fragments, so differs from one fragment per frame.
+	t.Parallel()
+	path := filepath.Join("testdata", "synthetic", "ExtendedOffsetTable.dcm")
+	dcm, err := FromFile(path)
+	assert.NoError(t, err)
+	offsets, found := dcm.extendedOffsetTable(nil)
+	assert.True(t, found)
+	assert.Equal(t, []int64{0, 24}, offsets)
+	pd := dcm.GetPixelData()
+	assert.Equal(t, 2, pd.NumFrames())
+	assert.Equal(t, []byte("AAAABBBB"), pd.GetFrame(0))
+	assert.Equal(t, []byte("CCCCCC"), pd.GetFrame(1))
+	frame, _, err := ReadFrame(path, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("AAAABBBB"), frame)
+
+	// a table whose lengths are missing is ignored
+	delete(dcm.DataSet, extendedOffsetTableLengthsTag)
+	_, found = dcm.extendedOffsetTable(nil)
+	assert.False(t, found)
+}
+
+func TestFrameRLE(t *testing.T) {
+	t.Parallel()
+	dcm := newPixelDicom(RLELossless, 2, 2, 1, 16, "MONOCHROME2")
+	// two segments (high bytes, then low bytes) of four pixels each
+	frame := make([]byte, 64)
+	binary.LittleEndian.PutUint32(frame[0:], 2)
+	binary.LittleEndian.PutUint32(frame[4:], 64)
+	binary.LittleEndian.PutUint32(frame[8:], 66)
+	frame = append(frame, 0xFD, 0x12)                   // replicate 0x12 four times
+	frame = append(frame, 0x03, 0x01, 0x02, 0x03, 0x04) // four literal bytes
+	dcm.pixelData.frames = [][]byte{frame}
+	data, err := DecodeRLEFrame(frame, 2, 2, 1, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x12, 0x02, 0x12, 0x03, 0x12, 0x04, 0x12}, data)
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x1204), img.(*image.Gray16).Gray16At(1, 1).Y)
+
+	// the padding byte of an odd length final segment is ignored
+	padded, err := DecodeRLEFrame(append(frame, 0x00), 2, 2, 1, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, data, padded)
+
+	// the header must declare between 1 and 15 segments, as the pixel module requires
+	for _, n := range []uint32{0, 16, 1} {
+		header := append([]byte{}, frame...)
+		binary.LittleEndian.PutUint32(header[0:], n)
+		_, err = DecodeRLEFrame(header, 2, 2, 1, 16)
+		assert.Error(t, err, n)
+	}
+	// segments which decode to fewer bytes than there are pixels are truncated
+	_, err = DecodeRLEFrame(frame[:len(frame)-1], 2, 2, 1, 16)
+	assert.Error(t, err)
+	_, err = DecodeRLEFrame(frame[:32], 2, 2, 1, 16)
+	assert.Error(t, err)
+}
+
+func TestFrameJPEG(t *testing.T) {
+	// ensures that JPEG Baseline frames are decoded according to PhotometricInterpretation:
+	// MONOCHROME1 is inverted, and RGB components are not converted from YCbCr.
+	t.Parallel()
+	gray := image.NewGray(image.Rect(0, 0, 8, 8))
+	for i := range gray.Pix {
+		gray.Pix[i] = 0x20
+	}
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, jpeg.Encode(buf, gray, &jpeg.Options{Quality: 100}))
+	for photometric, expected := range map[string]uint8{"MONOCHROME2": 0x20, "MONOCHROME1": 0xDF} {
+		dcm := newPixelDicom(JPEGBaseline, 8, 8, 1, 8, photometric)
+		dcm.pixelData.frames = [][]byte{buf.Bytes()}
+		img, err := dcm.Frame(0)
+		assert.NoError(t, err)
+		assert.InDelta(t, expected, img.(*image.Gray).Pix[0], 1, photometric)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2], rgba.Pix[i+3] = 0xC0, 0x40, 0x20, 0xFF
+	}
+	buf.Reset()
+	assert.NoError(t, jpeg.Encode(buf, rgba, &jpeg.Options{Quality: 100}))
+	dcm := newPixelDicom(JPEGBaseline, 8, 8, 3, 8, "YBR_FULL_422")
+	dcm.pixelData.frames = [][]byte{buf.Bytes()}
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	r, g, b, _ := img.At(0, 0).RGBA()
+	assert.InDelta(t, 0xC0, r>>8, 2)
+	assert.InDelta(t, 0x40, g>>8, 2)
+	assert.InDelta(t, 0x20, b>>8, 2)
+	ycc := img.(*image.YCbCr)
+
+	// the same components, declared as RGB, are taken as is
+	dcm = newPixelDicom(JPEGBaseline, 8, 8, 3, 8, "RGB")
+	dcm.pixelData.frames = [][]byte{buf.Bytes()}
+	img, err = dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: ycc.Y[0], G: ycc.Cb[0], B: ycc.Cr[0], A: 0xFF}, img.At(0, 0))
+}
+
+func TestFrameUnsupported(t *testing.T) {
+	t.Parallel()
+	dcm := newPixelDicom(JPEG2000, 1, 1, 1, 8, "MONOCHROME2")
+	dcm.pixelData.frames = [][]byte{{0x00}}
+	_, err := dcm.Frame(0)
+	assert.Equal(t, ErrUnsupportedPixelEncoding, err)
+}
+
+func TestPixelDataURL(t *testing.T) {
+	// ensures that a zero length PixelData accompanied by a
+	// PixelDataProviderURL is parsed, and the URL is exposed.
+	t.Parallel()
+	url := "http://pacs/wado/studies/1/bulk"
+	buf := append(make([]byte, 128), []byte("DICM")...)
+	buf = append(buf,
+		0x28, 0x00, 0xE0, 0x7F, 0x55, 0x52, 0x00, 0x00, // (0028,7FE0) UR
+		byte(len(url)+1), 0x00, 0x00, 0x00, // Length
+	)
+	buf = append(buf, []byte(url+" ")...)
+	buf = append(buf,
+		0xE0, 0x7F, 0x10, 0x00, 0x4F, 0x42, 0x00, 0x00, // (7FE0,0010) OB
+		0x00, 0x00, 0x00, 0x00, // Length: 0 bytes
+	)
+	dcm, err := FromReader(bytes.NewReader(buf))
+	assert.NoError(t, err)
+	assert.True(t, dcm.HasElement(pixelDataTag))
+	val, found := dcm.PixelDataURL()
+	assert.True(t, found)
+	assert.Equal(t, url, val)
+
+	dcm = newDicom()
+	_, found = dcm.PixelDataURL()
+	assert.False(t, found)
+}
+
+func TestSamples(t *testing.T) {
+	t.Parallel()
+	pd := newPixelData()
+	pd.addFrame([]byte{0x01, 0xFF, 0x02, 0x80}, false)
+	samples, err := pd.Samples(8, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{0x01, 0xFF, 0x02, 0x80}, samples)
+	samples, err = pd.Samples(8, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int8{1, -1, 2, -128}, samples)
+	samples, err = pd.Samples(16, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{0xFF01, 0x8002}, samples)
+	samples, err = pd.Samples(16, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int16{-255, -32766}, samples)
+	pd.isLittleEndian = false
+	samples, err = pd.Samples(16, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{0x01FF, 0x0280}, samples)
+	_, err = pd.Samples(32, 0)
+	assert.Error(t, err)
+
+	// encapsulated, and empty
+	encapsulated := newPixelData()
+	encapsulated.addFrame([]byte{0xFF, 0xD8}, true)
+	_, err = encapsulated.Samples(8, 0)
+	assert.Equal(t, ErrUnsupportedPixelEncoding, err)
+	empty := newPixelData()
+	_, err = empty.Samples(8, 0)
+	assert.Error(t, err)
+}
+
+func TestSamplesFloat(t *testing.T) {
+	// ensures that FloatPixelData is read as pixel data, and returned as floats.
+	t.Parallel()
+	input := append(make([]byte, 128), "DICM"...)
+	input = append(input, 0xE0, 0x7F, 0x08, 0x00, 'O', 'F', 0x08, 0x00)
+	values := make([]byte, 8)
+	binary.LittleEndian.PutUint32(values[0:], math.Float32bits(1.5))
+	binary.LittleEndian.PutUint32(values[4:], math.Float32bits(0))
+	input = append(input, values...)
+	dcm, err := FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	samples, err := dcm.GetPixelData().Samples(32, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1.5, 0}, samples)
+	_, err = dcm.GetPixelData().Samples(16, 0)
+	assert.Error(t, err)
+}
+
+func TestApplyPalette(t *testing.T) {
+	// ensures that indexed samples are mapped through the palette lookup tables.
+	t.Parallel()
+	descriptor := func(tag uint32, entries, firstMapped, bits uint16) Element {
+		e := NewElementWithTag(tag)
+		e.data = make([]byte, 6)
+		binary.LittleEndian.PutUint16(e.data[0:], entries)
+		binary.LittleEndian.PutUint16(e.data[2:], firstMapped)
+		binary.LittleEndian.PutUint16(e.data[4:], bits)
+		return e
+	}
+	table := func(tag uint32, entries ...uint16) Element {
+		e := NewElementWithTag(tag)
+		e.data = make([]byte, 2*len(entries))
+		for i, v := range entries {
+			binary.LittleEndian.PutUint16(e.data[i*2:], v)
+		}
+		return e
+	}
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 1, 4, 1, 8, "PALETTE COLOR")
+	for i := uint32(0); i < 3; i++ {
+		dcm.addElement(descriptor(0x00281101+i, 3, 10, 16))
+	}
+	dcm.addElement(table(0x00281201, 0x0000, 0x8000, 0xFFFF))
+	dcm.addElement(table(0x00281202, 0xFFFF, 0x8000, 0x0000))
+	dcm.addElement(table(0x00281203, 0x1000, 0x2000, 0x3000))
+	lut, err := dcm.PaletteLUT()
+	assert.NoError(t, err)
+	assert.Equal(t, 10, lut.FirstMapped)
+	assert.Equal(t, []uint16{0x1000, 0x2000, 0x3000}, lut.Blue)
+
+	// below and above the range of the tables are clamped
+	img, err := dcm.ApplyPalette([]uint16{9, 10, 11, 13})
+	assert.NoError(t, err)
+	rgba := img.(*image.RGBA64)
+	for x, expected := range []color.RGBA64{
+		{0x0000, 0xFFFF, 0x1000, 0xFFFF},
+		{0x0000, 0xFFFF, 0x1000, 0xFFFF},
+		{0x8000, 0x8000, 0x2000, 0xFFFF},
+		{0xFFFF, 0x0000, 0x3000, 0xFFFF},
+	} {
+		assert.Equal(t, expected, rgba.RGBA64At(x, 0))
+	}
+	_, err = dcm.ApplyPalette([]uint16{10})
+	assert.Error(t, err)
+
+	// 8 bit entries, packed
+	e := NewElementWithTag(0x00281201)
+	e.data = []byte{0x00, 0x80, 0xFF, 0x00}
+	dcm.addElement(e)
+	for i := uint32(0); i < 3; i++ {
+		dcm.addElement(descriptor(0x00281101+i, 3, 10, 8))
+	}
+	dcm.addElement(table(0x00281202, 0xFF, 0x80, 0x00))
+	dcm.addElement(table(0x00281203, 0x10, 0x20, 0x30))
+	img, err = dcm.ApplyPalette([]uint16{10, 11, 12, 12})
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA64{0xFFFF, 0x0000, 0x3030, 0xFFFF}, img.(*image.RGBA64).RGBA64At(3, 0))
+
+	// missing tables
+	dcm = newPixelDicom(ExplicitVRLittleEndian, 1, 4, 1, 8, "PALETTE COLOR")
+	_, err = dcm.PaletteLUT()
+	assert.Error(t, err)
+}
+
+func TestIconImage(t *testing.T) {
+	// ensures that the thumbnail is decoded according to the icon's own pixel module.
+	t.Parallel()
+	icon := newPixelDicom(ExplicitVRLittleEndian, 2, 2, 1, 8, "MONOCHROME2")
+	delete(icon.DataSet, transferSyntaxTag)
+	pixelData := NewElementWithTag(pixelDataTag)
+	pixelData.data = []byte{0x00, 0x40, 0x80, 0xFF}
+	icon.addElement(pixelData)
+	dcm := newPixelDicom(ExplicitVRLittleEndian, 512, 512, 1, 16, "MONOCHROME2")
+	dcm.addElement(newSequence(iconImageSequenceTag, icon.DataSet))
+	img, err := dcm.IconImage()
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 2, 2), img.Bounds())
+	assert.Equal(t, []uint8{0x00, 0x40, 0x80, 0xFF}, img.(*image.Gray).Pix)
+
+	// missing PixelData, and no icon at all
+	delete(icon.DataSet, pixelDataTag)
+	_, err = dcm.IconImage()
+	assert.Error(t, err)
+	dcm = newPixelDicom(ExplicitVRLittleEndian, 512, 512, 1, 16, "MONOCHROME2")
+	_, err = dcm.IconImage()
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package opendcm
+
+import (
+	"image"
+	"sync"
+)
+
+/*
+===============================================================================
+	Transfer Syntaxes
+	---
+	Provides a registry of transfer syntaxes, describing how the data set of
+	each is encoded and how its pixel data is to be decoded. The syntaxes
+	supported by OpenDCM are registered at init; users may register others.
+===============================================================================
+*/
+
+// Encoding describes the VR encoding and byte ordering of a data set.
+type Encoding struct {
+	ImplicitVR   bool
+	LittleEndian bool
+}
+
+// PixelDecoder decodes frames of pixel data, as encoded by a transfer syntax, into images.
+type PixelDecoder interface {
+	// DecodeFrame decodes frame `index` of `pd` into an image, according to the pixel module.
+	DecodeFrame(pd *PixelData, pm PixelModule, index int) (image.Image, error)
+}
+
+// PixelDecoderFunc allows the use of an ordinary function as a PixelDecoder.
+type PixelDecoderFunc func(pd *PixelData, pm PixelModule, index int) (image.Image, error)
+
+// DecodeFrame calls f(pd, pm, index).
+func (f PixelDecoderFunc) DecodeFrame(pd *PixelData, pm PixelModule, index int) (image.Image, error) {
+	return f(pd, pm, index)
+}
+
+// transferSyntax holds the registered attributes of a transfer syntax.
+type transferSyntax struct {
+	encoding     Encoding
+	pixelDecoder PixelDecoder
+}
+
+var (
+	transferSyntaxesMu sync.RWMutex
+	transferSyntaxes   = make(map[string]transferSyntax)
+)
+
+func init() {
+	implicitLE := Encoding{ImplicitVR: true, LittleEndian: true}
+	explicitLE := Encoding{ImplicitVR: false, LittleEndian: true}
+	explicitBE := Encoding{ImplicitVR: false, LittleEndian: false}
+	RegisterTransferSyntax(ImplicitVRLittleEndian, implicitLE, nativeDecoder{littleEndian: true})
+	RegisterTransferSyntax(ExplicitVRLittleEndian, explicitLE, nativeDecoder{littleEndian: true})
+	RegisterTransferSyntax(DeflatedExplicitVRLittleEndian, explicitLE, nativeDecoder{littleEndian: true})
+	RegisterTransferSyntax(ExplicitVRBigEndian, explicitBE, nativeDecoder{littleEndian: false})
+	RegisterTransferSyntax(RLELossless, explicitLE, PixelDecoderFunc(decodeRLEFrame))
+	RegisterTransferSyntax(JPEGBaseline, explicitLE, PixelDecoderFunc(decodeJPEGFrame))
+	// data sets can be parsed, but there is no decoder for the pixel data
+	RegisterTransferSyntax(JPEGLSLossless, explicitLE, nil)
+	RegisterTransferSyntax(JPEGLSNearLossless, explicitLE, nil)
+	RegisterTransferSyntax(JPEG2000Lossless, explicitLE, nil)
+	RegisterTransferSyntax(JPEG2000, explicitLE, nil)
+}
+
+// RegisterTransferSyntax registers the transfer syntax `uid`, whose data sets are encoded
+// according to `enc`, and whose pixel data is decoded by `pixelDecoder`. A nil `pixelDecoder`
+// indicates that data sets can be parsed, but their pixel data cannot be decoded.
+// Registering an existing `uid` replaces it, allowing the built-in decoders to be overridden.
+func RegisterTransferSyntax(uid string, enc Encoding, pixelDecoder PixelDecoder) {
+	transferSyntaxesMu.Lock()
+	defer transferSyntaxesMu.Unlock()
+	transferSyntaxes[uid] = transferSyntax{encoding: enc, pixelDecoder: pixelDecoder}
+}
+
+// lookupTransferSyntax returns the registered attributes of transfer syntax `uid`.
+func lookupTransferSyntax(uid string) (transferSyntax, bool) {
+	transferSyntaxesMu.RLock()
+	defer transferSyntaxesMu.RUnlock()
+	ts, found := transferSyntaxes[uid]
+	return ts, found
+}
+
+// GetEncodingForTransferSyntax returns the encoding of data sets of transfer syntax `uid`.
+// Its return value (bool) indicates whether `uid` has been registered.
+func GetEncodingForTransferSyntax(uid string) (Encoding, bool) {
+	ts, found := lookupTransferSyntax(uid)
+	return ts.encoding, found
+}
+
+// TransferSyntaxForEncoding returns the canonical uncompressed transfer syntax whose data sets
+// are encoded according to `enc`. Its return value (bool) indicates whether there is such a
+// transfer syntax; implicit VR big endian, for example, is not permitted.
+func TransferSyntaxForEncoding(enc Encoding) (string, bool) {
+	switch {
+	case enc.ImplicitVR && enc.LittleEndian:
+		return ImplicitVRLittleEndian, true
+	case !enc.ImplicitVR && enc.LittleEndian:
+		return ExplicitVRLittleEndian, true
+	case !enc.ImplicitVR && !enc.LittleEndian:
+		return ExplicitVRBigEndian, true
+	}
+	return "", false
+}
+
+// isNativeTransferSyntax returns whether pixel data of transfer syntax `uid` is uncompressed.
+func isNativeTransferSyntax(uid string) bool {
+	switch uid {
+	case ImplicitVRLittleEndian, ExplicitVRLittleEndian, DeflatedExplicitVRLittleEndian, ExplicitVRBigEndian:
+		return true
+	}
+	return false
+}
+
+// IsTransferSyntaxSupported returns whether the transfer syntax `uid` has been registered.
+func IsTransferSyntaxSupported(uid string) bool {
+	_, found := lookupTransferSyntax(uid)
+	return found
+}
+
+// getPixelDecoder returns the decoder registered for transfer syntax `uid`, or nil if none.
+func getPixelDecoder(uid string) PixelDecoder {
+	ts, _ := lookupTransferSyntax(uid)
+	return ts.pixelDecoder
+}
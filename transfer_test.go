@@ -0,0 +1,83 @@
+package opendcm
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEncodingForTransferSyntax(t *testing.T) {
+	t.Parallel()
+	enc, found := GetEncodingForTransferSyntax(ImplicitVRLittleEndian)
+	assert.True(t, found)
+	assert.Equal(t, Encoding{ImplicitVR: true, LittleEndian: true}, enc)
+	enc, found = GetEncodingForTransferSyntax(ExplicitVRBigEndian)
+	assert.True(t, found)
+	assert.Equal(t, Encoding{ImplicitVR: false, LittleEndian: false}, enc)
+	assert.True(t, IsTransferSyntaxSupported(JPEG2000))
+	_, found = GetEncodingForTransferSyntax("1.2.3.4")
+	assert.False(t, found)
+	assert.False(t, IsTransferSyntaxSupported("1.2.3.4"))
+}
+
+func TestRegisterTransferSyntax(t *testing.T) {
+	// ensures that a registered decoder is used both for parsing and for decoding frames.
+	t.Parallel()
+	uid := "1.2.826.0.1.3680043.2.1143.1"
+	decoded := image.NewGray(image.Rect(0, 0, 2, 1))
+	RegisterTransferSyntax(uid, Encoding{ImplicitVR: true, LittleEndian: true}, PixelDecoderFunc(
+		func(pd *PixelData, pm PixelModule, index int) (image.Image, error) {
+			return decoded, nil
+		}))
+	assert.True(t, IsTransferSyntaxSupported(uid))
+
+	dcm := newPixelDicom(uid, 1, 2, 1, 8, "MONOCHROME2")
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, decoded, img)
+
+	// elements are read with the registered encoding
+	e, err := ParseElement([]byte{0x28, 0x00, 0x10, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x02}, uid)
+	assert.NoError(t, err)
+	rows := uint16(0)
+	assert.NoError(t, e.GetValue(&rows))
+	assert.Equal(t, uint16(512), rows)
+
+	// registered without a decoder
+	uid = "1.2.826.0.1.3680043.2.1143.2"
+	RegisterTransferSyntax(uid, Encoding{ImplicitVR: false, LittleEndian: true}, nil)
+	dcm = newPixelDicom(uid, 1, 2, 1, 8, "MONOCHROME2")
+	_, err = dcm.Frame(0)
+	assert.Equal(t, ErrUnsupportedPixelEncoding, err)
+}
+
+func TestJPEGLS(t *testing.T) {
+	// ensures that JPEG-LS data sets are parsed, although their pixel data cannot be decoded.
+	t.Parallel()
+	for _, uid := range []string{JPEGLSLossless, JPEGLSNearLossless} {
+		assert.True(t, IsTransferSyntaxSupported(uid))
+		e, err := ParseElement([]byte{0x28, 0x00, 0x10, 0x00, 'U', 'S', 0x02, 0x00, 0x00, 0x02}, uid)
+		assert.NoError(t, err)
+		rows := uint16(0)
+		assert.NoError(t, e.GetValue(&rows))
+		assert.Equal(t, uint16(512), rows)
+
+		dcm := newPixelDicom(uid, 1, 2, 1, 8, "MONOCHROME2")
+		_, err = dcm.Frame(0)
+		assert.Equal(t, ErrUnsupportedPixelEncoding, err)
+	}
+}
+
+func TestTransferSyntaxForEncoding(t *testing.T) {
+	t.Parallel()
+	for _, ts := range []string{ImplicitVRLittleEndian, ExplicitVRLittleEndian, ExplicitVRBigEndian} {
+		enc, found := GetEncodingForTransferSyntax(ts)
+		assert.True(t, found)
+		derived, found := TransferSyntaxForEncoding(enc)
+		assert.True(t, found)
+		assert.Equal(t, ts, derived)
+	}
+	_, found := TransferSyntaxForEncoding(Encoding{ImplicitVR: true, LittleEndian: false})
+	assert.False(t, found)
+}
@@ -0,0 +1,664 @@
+package opendcm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/b71729/bin"
+	"golang.org/x/text/encoding"
+)
+
+/*
+===============================================================================
+	Writer
+	---
+	Provides mechanisms for encoding elements, and entire dicoms, according to
+	a transfer syntax. This is the counterpart to the ElementReader.
+===============================================================================
+*/
+
+// ElementWriter provides methods for encoding elements to an underlying `io.Writer`.
+type ElementWriter struct {
+	bw       bin.Writer
+	implicit bool
+	encoder  *encoding.Encoder
+}
+
+// NewElementWriter returns a new ElementWriter targetted at `dest`,
+// encoding elements as explicit VR little endian until told otherwise.
+func NewElementWriter(dest io.Writer) ElementWriter {
+	return ElementWriter{bw: bin.NewWriter(dest, binary.LittleEndian)}
+}
+
+// SetTransferSyntax sets the VR encoding and byte ordering of this ElementWriter
+// according to the registered encoding of transfer syntax `ts`.
+// See: ElementReader.SetTransferSyntax for more information
+func (elw *ElementWriter) SetTransferSyntax(ts string) {
+	enc, found := GetEncodingForTransferSyntax(ts)
+	if !found {
+		enc = Encoding{ImplicitVR: false, LittleEndian: true}
+	}
+	elw.implicit = enc.ImplicitVR
+	if enc.LittleEndian {
+		elw.bw.SetByteOrder(binary.LittleEndian)
+	} else {
+		elw.bw.SetByteOrder(binary.BigEndian)
+	}
+}
+
+// isLittleEndian returns whether this ElementWriter encodes using Little Endian byte ordering.
+func (elw *ElementWriter) isLittleEndian() bool {
+	return elw.bw.GetByteOrder() == binary.LittleEndian
+}
+
+// hasLongLength returns whether, in explicit VR, elements of `vr` have two reserved
+// bytes followed by a 32-bit length, rather than a 16-bit length.
+func hasLongLength(vr string) bool {
+	switch vr {
+	case "OB", "OD", "OF", "OL", "OV", "OW", "SQ", "SV", "UC", "UN", "UR", "UT", "UV":
+		return true
+	}
+	return false
+}
+
+// sampleSize returns the number of bytes occupied by each binary value of `vr`,
+// or zero if values of `vr` are not subject to byte ordering.
+func sampleSize(vr string) int {
+	switch vr {
+	case "US", "SS", "OW", "AT":
+		return 2
+	case "UL", "SL", "FL", "OF", "OL":
+		return 4
+	case "FD", "OD", "SV", "UV", "OV":
+		return 8
+	}
+	return 0
+}
+
+// swapByteOrder returns a copy of `data` with the byte order of each `size`-byte value reversed.
+func swapByteOrder(data []byte, size int) []byte {
+	swapped := make([]byte, len(data))
+	copy(swapped, data)
+	for i := 0; i+size <= len(swapped); i += size {
+		for j := 0; j < size/2; j++ {
+			swapped[i+j], swapped[i+size-1-j] = swapped[i+size-1-j], swapped[i+j]
+		}
+	}
+	return swapped
+}
+
+// writeTag encodes `tag` as a pair of 16-bit words: group, then element.
+func (elw *ElementWriter) writeTag(tag uint32) error {
+	if err := elw.bw.WriteUint16(uint16(tag >> 16)); err != nil {
+		return err
+	}
+	return elw.bw.WriteUint16(uint16(tag))
+}
+
+// writeHeader encodes the tag, VR (if explicit) and length components of an element.
+func (elw *ElementWriter) writeHeader(tag uint32, vr string, length uint32) error {
+	if err := elw.writeTag(tag); err != nil {
+		return err
+	}
+	if elw.implicit {
+		return elw.bw.WriteUint32(length)
+	}
+	if err := elw.bw.WriteBytes([]byte(vr)); err != nil {
+		return err
+	}
+	if hasLongLength(vr) {
+		if err := elw.bw.ZeroFill(2); err != nil {
+			return err
+		}
+		return elw.bw.WriteUint32(length)
+	}
+	if length > 0xFFFF {
+		return errors.New("value length would overflow uint16")
+	}
+	return elw.bw.WriteUint16(uint16(length))
+}
+
+// writeItem encodes an item tag of `length`, followed by `data`.
+func (elw *ElementWriter) writeItem(tag uint32, length uint32, data []byte) error {
+	if err := elw.writeTag(tag); err != nil {
+		return err
+	}
+	if err := elw.bw.WriteUint32(length); err != nil {
+		return err
+	}
+	return elw.bw.WriteBytes(data)
+}
+
+// writeDataSet encodes each element within `ds`, in ascending tag order.
+func (elw *ElementWriter) writeDataSet(ds DataSet) error {
+	tags := make([]int, 0, len(ds))
+	for tag := range ds {
+		tags = append(tags, int(tag))
+	}
+	sort.Ints(tags)
+	for _, tag := range tags {
+		if err := elw.WriteElement(ds[uint32(tag)]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteElement encodes `e` according to the current transfer syntax.
+// Odd length values are padded, and binary values are converted to the writer's byte order.
+// Sequences and encapsulated pixel data are written with undefined length.
+// Values exceeding the length permitted by their VR are rejected in `StrictMode`. Values which
+// were skipped during a lazy (or compact) parse cannot be written, unless loaded with `Element.LoadValue`.
+func (elw *ElementWriter) WriteElement(e Element) error {
+	if e.lazy {
+		return fmt.Errorf("cannot write %s, as its value was not read", e.dictEntry)
+	}
+	if err := e.checkVRLength(); err != nil {
+		if config.StrictMode {
+			return err
+		}
+		Warnf("writing non-conformant element: %v", err)
+	}
+	vr := e.GetVR()
+	if len(vr) != 2 {
+		vr = "UN"
+	}
+	if e.HasItems() {
+		if vr != "SQ" && e.GetTag() != pixelDataTag {
+			vr = "SQ"
+		}
+		if err := elw.writeHeader(e.GetTag(), vr, 0xFFFFFFFF); err != nil {
+			return err
+		}
+		if e.GetTag() == pixelDataTag {
+			// encapsulated pixel data: the offset table and fragments are written as they were read
+			fragments := make([][]byte, 0, len(e.items))
+			for _, itm := range e.items {
+				if itm.fragment == nil && itm.length > 0 {
+					return fmt.Errorf("cannot write %s, as its fragments were not read", e.dictEntry)
+				}
+			}
+			for _, itm := range e.items[1:] {
+				fragments = append(fragments, itm.fragment)
+			}
+			return elw.bw.WriteBytes(encapsulate(e.items[0].fragment, fragments))
+		}
+		for i := range e.items {
+			// items whose parsing was deferred are parsed in order to be written
+			itm, err := e.parseItem(i)
+			if err != nil {
+				return err
+			}
+			if err := elw.writeItem(itemTag, 0xFFFFFFFF, nil); err != nil {
+				return err
+			}
+			if err := elw.writeDataSet(itm.dataset); err != nil {
+				return err
+			}
+			if err := elw.writeItem(itemDelimTag, 0, nil); err != nil {
+				return err
+			}
+		}
+		return elw.writeItem(seqDelimTag, 0, nil)
+	}
+
+	data := e.data
+	if elw.encoder != nil {
+		switch vr {
+		case "SH", "LO", "ST", "PN", "LT", "UT":
+			encoded, err := elw.encoder.Bytes(data)
+			if err != nil {
+				return err
+			}
+			data = encoded
+		}
+	}
+	if size := sampleSize(vr); size > 0 && e.isLittleEndian != elw.isLittleEndian() {
+		data = swapByteOrder(data, size)
+	}
+	if len(data)%2 != 0 {
+		data = append(append(make([]byte, 0, len(data)+1), data...), paddingForVR(vr))
+	}
+	if err := elw.writeHeader(e.GetTag(), vr, uint32(len(data))); err != nil {
+		return err
+	}
+	return elw.bw.WriteBytes(data)
+}
+
+// encapsulate encodes the value of encapsulated pixel data: the Basic Offset Table item holding
+// `offsetTable`, an item per fragment, and the sequence delimitation item. Encapsulated pixel
+// data is always little endian. Odd length fragments are padded.
+func encapsulate(offsetTable []byte, fragments [][]byte) []byte {
+	size := 16 + len(offsetTable)
+	for _, fragment := range fragments {
+		size += 9 + len(fragment)
+	}
+	buf := make([]byte, 0, size)
+	appendItem := func(tag uint32, value []byte) {
+		header := make([]byte, 8)
+		binary.LittleEndian.PutUint16(header, uint16(tag>>16))
+		binary.LittleEndian.PutUint16(header[2:], uint16(tag))
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(value)+len(value)%2))
+		buf = append(append(buf, header...), value...)
+		if len(value)%2 != 0 {
+			buf = append(buf, 0x00)
+		}
+	}
+	appendItem(itemTag, offsetTable)
+	for _, fragment := range fragments {
+		appendItem(itemTag, fragment)
+	}
+	appendItem(seqDelimTag, nil)
+	return buf
+}
+
+// Encapsulate encodes `frames` as the value of undefined length (7FE0,0010) PixelData, as is
+// required for compressed transfer syntaxes, with each frame held in a single fragment. If `frames`
+// is nil, the frames of `pd` are encoded. If `withOffsetTable` is set, the Basic Offset Table holds
+// the offset of each frame; otherwise it is left empty.
+func (pd *PixelData) Encapsulate(frames [][]byte, withOffsetTable bool) []byte {
+	if frames == nil {
+		frames = pd.frames
+	}
+	offsetTable := make([]byte, 0)
+	if withOffsetTable {
+		offset := 0
+		for _, frame := range frames {
+			offsetTable = append(offsetTable, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(offsetTable[len(offsetTable)-4:], uint32(offset))
+			offset += 8 + len(frame) + len(frame)%2
+		}
+	}
+	return encapsulate(offsetTable, frames)
+}
+
+// outputTransferSyntax returns the transfer syntax with which the dicom will be written.
+// If (0002,0010) TransferSyntaxUID is absent, as for headerless streams, the transfer syntax
+// is derived from the encoding with which the data set was read.
+// If `DeflateOnWrite` is enabled, uncompressed transfer syntaxes are substituted for
+// Deflated Explicit VR Little Endian.
+func (dcm *Dicom) outputTransferSyntax() (string, error) {
+	ts := dcm.GetTransferSyntax()
+	if !dcm.HasElement(transferSyntaxTag) {
+		if derived, found := TransferSyntaxForEncoding(dcm.encoding); found {
+			ts = derived
+		}
+	}
+	if !config.DeflateOnWrite {
+		return ts, nil
+	}
+	if isNativeTransferSyntax(ts) {
+		return DeflatedExplicitVRLittleEndian, nil
+	}
+	return "", errors.New("cannot deflate a dicom with compressed pixel data")
+}
+
+// reencodeDataSet converts the binary values within `ds`, and within its sequences, to the given
+// byte order. Meta elements are left as they are, as they are always written as little endian.
+func reencodeDataSet(ds DataSet, littleEndian bool) error {
+	for tag, e := range ds {
+		if tag>>16 == 0x0002 {
+			continue
+		}
+		for i := range e.items {
+			// deferred items are parsed whilst the byte order of their source is known
+			itm, err := e.parseItem(i)
+			if err != nil {
+				return err
+			}
+			if err := reencodeDataSet(itm.dataset, littleEndian); err != nil {
+				return err
+			}
+			e.items[i] = itm
+		}
+		if e.isLittleEndian != littleEndian {
+			if size := sampleSize(e.GetVR()); size > 0 {
+				if e.lazy {
+					return fmt.Errorf("cannot re-encode %s, as its value was not read", e.dictEntry)
+				}
+				e.data = swapByteOrder(e.data, size)
+			}
+			e.isLittleEndian = littleEndian
+		}
+		ds[tag] = e
+	}
+	return nil
+}
+
+// reencodePixelData returns a copy of native PixelData converted to the given byte order. Its return
+// value (bool) indicates whether a conversion was necessary. Native pixel data is typically read as
+// OB, which is not otherwise converted, so its sample size is given by BitsAllocated.
+func (dcm *Dicom) reencodePixelData(littleEndian bool) (Element, bool, error) {
+	pixelData := NewElement()
+	if !dcm.GetElement(pixelDataTag, &pixelData) || pixelData.HasItems() || pixelData.isLittleEndian == littleEndian {
+		return pixelData, false, nil
+	}
+	if pixelData.lazy {
+		return pixelData, false, errors.New("cannot re-encode PixelData, as its value was not read")
+	}
+	bitsAllocated := 0
+	if err := dcm.getUint16(0x00280100, &bitsAllocated); err != nil {
+		return pixelData, false, err
+	}
+	if bitsAllocated > 8 {
+		pixelData.data = swapByteOrder(pixelData.data, bitsAllocated/8)
+		// do not modify the dictionary's own entry
+		entry := *pixelData.dictEntry
+		entry.VR = "OW"
+		pixelData.dictEntry = &entry
+	}
+	pixelData.isLittleEndian = littleEndian
+	return pixelData, true, nil
+}
+
+// SetTransferSyntax sets (0002,0010) TransferSyntaxUID to `uid`, re-encoding the binary values
+// of the data set (including native pixel data) to the byte order of the new transfer syntax.
+// Only conversions between uncompressed transfer syntaxes are supported, as compressed
+// pixel data is not transcoded.
+func (dcm *Dicom) SetTransferSyntax(uid string) error {
+	current := dcm.GetTransferSyntax()
+	if uid == current {
+		return nil
+	}
+	enc, found := GetEncodingForTransferSyntax(uid)
+	if !found {
+		return fmt.Errorf("SetTransferSyntax(%s): transfer syntax is not registered", uid)
+	}
+	if !isNativeTransferSyntax(current) || !isNativeTransferSyntax(uid) {
+		return fmt.Errorf("SetTransferSyntax(%s): cannot convert from %s, as pixel data would need transcoding", uid, current)
+	}
+
+	pixelData, reencoded, err := dcm.reencodePixelData(enc.LittleEndian)
+	if err != nil {
+		return fmt.Errorf("SetTransferSyntax(%s): %v", uid, err)
+	}
+	if reencoded {
+		dcm.addElement(pixelData)
+		dcm.pixelData = newPixelData()
+		dcm.onPixelData(pixelData)
+	}
+	if err := reencodeDataSet(dcm.DataSet, enc.LittleEndian); err != nil {
+		return err
+	}
+	// values are held without padding, as when parsed
+	tsElement := NewElementWithTag(transferSyntaxTag)
+	tsElement.data = []byte(uid)
+	tsElement.datalen = uint32(len(uid))
+	dcm.addElement(tsElement)
+	dcm.encoding = enc
+	return nil
+}
+
+// mediaStorageSOPTags maps the media storage SOP identifiers of the meta group to those of the data set.
+var mediaStorageSOPTags = map[uint32]uint32{0x00020002: sopClassUIDTag, mediaStorageSOPInstanceUIDTag: sopInstanceUIDTag}
+
+// metaGroup returns the (0002) meta elements with which the dicom will be written.
+// The version, transfer syntax and implementation identification are always set, and the
+// media storage SOP identifiers are taken from the data set if absent (or, if
+// `SyncSOPUIDsOnWrite` is enabled, regardless).
+func (dcm *Dicom) metaGroup(ts string) DataSet {
+	meta := make(DataSet, 0)
+	for tag, e := range dcm.DataSet {
+		if tag>>16 == 0x0002 && tag != 0x00020000 {
+			meta.addElement(e)
+		}
+	}
+	meta.addElement(newElementWithData(0x00020001, []byte{0x00, 0x01}))
+	for metaTag, tag := range mediaStorageSOPTags {
+		e := NewElement()
+		if (config.SyncSOPUIDsOnWrite || !meta.HasElement(metaTag)) && dcm.GetElement(tag, &e) {
+			meta.addElement(newElementWithData(metaTag, e.data))
+		}
+	}
+	meta.addElement(newElementWithData(transferSyntaxTag, []byte(ts)))
+	meta.addElement(newElementWithData(0x00020012, []byte(ImplementationClassUID())))
+	meta.addElement(newElementWithData(0x00020013, []byte(ImplementationVersionName())))
+	return meta
+}
+
+// Normalize addresses the most common reasons for which otherwise valid dicoms are rejected:
+// retired group length (gggg,0000) elements are removed, other than those of the (0000) command
+// and (0002) meta groups; the media storage SOP identifiers of the meta group are set from the data
+// set, as per `CheckSOPConsistency`; odd length values, including those within sequences, are
+// padded to even length according to their VR; and (0002,0000) FileMetaInformationGroupLength is
+// recomputed. Padding remains part of the values returned by `GetValue`, as if it had been read.
+func (dcm *Dicom) Normalize() error {
+	if err := normalizeDataSet(dcm.DataSet); err != nil {
+		return err
+	}
+	for metaTag, tag := range mediaStorageSOPTags {
+		e := NewElement()
+		if dcm.GetElement(tag, &e) {
+			dcm.addElement(newElementWithData(metaTag, e.data))
+		}
+	}
+	meta := make(DataSet, 0)
+	for _, e := range dcm.ElementsMatching(func(tag uint32) bool { return tag>>16 == 0x0002 && tag != 0x00020000 }) {
+		meta.addElement(e)
+	}
+	if meta.Len() == 0 {
+		return nil
+	}
+	// meta elements are always explicit vr, little endian
+	metaBuf := bytes.NewBuffer(nil)
+	metaWriter := NewElementWriter(metaBuf)
+	if err := metaWriter.writeDataSet(meta); err != nil {
+		return err
+	}
+	groupLength := make([]byte, 4)
+	binary.LittleEndian.PutUint32(groupLength, uint32(metaBuf.Len()))
+	dcm.addElement(newElementWithData(0x00020000, groupLength))
+	return nil
+}
+
+// normalizeDataSet removes the retired group length elements of `ds`, and pads its odd length
+// values, as per `Normalize`. Items whose parsing was deferred are parsed.
+func normalizeDataSet(ds DataSet) error {
+	for tag, e := range ds {
+		if group := tag >> 16; tag&0xFFFF == 0 && group != 0x0000 && group != 0x0002 {
+			delete(ds, tag)
+			continue
+		}
+		for i := range e.items {
+			itm, err := e.parseItem(i)
+			if err != nil {
+				return err
+			}
+			if err = normalizeDataSet(itm.dataset); err != nil {
+				return err
+			}
+			e.items[i] = itm
+		}
+		if len(e.data)%2 != 0 {
+			e.data = append(append(make([]byte, 0, len(e.data)+1), e.data...), paddingForVR(e.GetVR()))
+			e.datalen = uint32(len(e.data))
+			ds[tag] = e
+		}
+	}
+	return nil
+}
+
+// Write encodes the dicom to `w`: the preamble, "DICM" magic, the meta group (with recomputed
+// group length), and then the data set, according to (0002,0010) TransferSyntaxUID.
+func (dcm *Dicom) Write(w io.Writer) error {
+	ts, err := dcm.outputTransferSyntax()
+	if err != nil {
+		return err
+	}
+
+	// meta elements are always explicit vr, little endian
+	metaBuf := bytes.NewBuffer(nil)
+	metaWriter := NewElementWriter(metaBuf)
+	if err = metaWriter.writeDataSet(dcm.metaGroup(ts)); err != nil {
+		return err
+	}
+	groupLength := make([]byte, 4)
+	binary.LittleEndian.PutUint32(groupLength, uint32(metaBuf.Len()))
+
+	bw := bin.NewWriter(w, binary.LittleEndian)
+	if err = bw.WriteBytes(dcm.preamble[:]); err != nil {
+		return err
+	}
+	if err = bw.WriteBytes(dicmTestString); err != nil {
+		return err
+	}
+	header := NewElementWriter(w)
+	if err = header.WriteElement(newElementWithData(0x00020000, groupLength)); err != nil {
+		return err
+	}
+	if err = bw.WriteBytes(metaBuf.Bytes()); err != nil {
+		return err
+	}
+	return dcm.writeBody(w, ts)
+}
+
+// writeBody encodes the non-meta elements of the dicom to `w`, according to the transfer syntax `ts`.
+func (dcm *Dicom) writeBody(w io.Writer, ts string) (err error) {
+	body := make(DataSet, len(dcm.DataSet))
+	for tag, e := range dcm.DataSet {
+		if tag>>16 != 0x0002 {
+			body.addElement(e)
+		}
+	}
+	if enc, found := GetEncodingForTransferSyntax(ts); found {
+		pixelData, reencoded, err := dcm.reencodePixelData(enc.LittleEndian)
+		if err != nil {
+			return err
+		}
+		if reencoded {
+			body.addElement(pixelData)
+		}
+	}
+	var deflater *flate.Writer
+	if ts == DeflatedExplicitVRLittleEndian {
+		if deflater, err = flate.NewWriter(w, flate.DefaultCompression); err != nil {
+			return err
+		}
+		w = deflater
+	}
+	elw := NewElementWriter(w)
+	elw.SetTransferSyntax(ts)
+	// textual values were decoded to UTF-8 upon parse, so must be re-encoded
+	elw.encoder = dcm.GetCharacterSet().Encoding.NewEncoder()
+	if err = elw.writeDataSet(body); err != nil {
+		return err
+	}
+	if deflater != nil {
+		return deflater.Close()
+	}
+	return nil
+}
+
+// WriteDataset encodes only the data set of the dicom to `w`, without the preamble, "DICM" magic
+// or meta group, according to the transfer syntax `transferSyntaxUID`, as is exchanged in DIMSE
+// messages or stored in databases. Binary values are converted to the byte order of the transfer
+// syntax, but pixel data is not transcoded: native pixel data may only be written with an
+// uncompressed transfer syntax, and encapsulated pixel data only with its own transfer syntax.
+func (dcm *Dicom) WriteDataset(w io.Writer, transferSyntaxUID string) error {
+	if !IsTransferSyntaxSupported(transferSyntaxUID) {
+		return fmt.Errorf("WriteDataset(%s): transfer syntax is not registered", transferSyntaxUID)
+	}
+	current := dcm.GetTransferSyntax()
+	if transferSyntaxUID != current && (!isNativeTransferSyntax(current) || !isNativeTransferSyntax(transferSyntaxUID)) {
+		return fmt.Errorf("WriteDataset(%s): cannot convert from %s, as pixel data would need transcoding", transferSyntaxUID, current)
+	}
+	return dcm.writeBody(w, transferSyntaxUID)
+}
+
+// WriteToFile encodes the dicom to a file at `path`.
+// See: Write for more information
+func (dcm *Dicom) WriteToFile(path string) error {
+	return writeFile(path, dcm.Write)
+}
+
+// writeFile creates a file at `path`, to which `write` encodes. The writer makes many small
+// writes, which would otherwise each be a system call, so the file is buffered.
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if err = write(bw); err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteTo encodes the dicom to `w` as per `Write`, returning the number of bytes written. It implements
+// `io.WriterTo` in place of the promoted `DataSet.WriteTo`, which would discard the preamble and the
+// encoding with which the dicom was read.
+func (dcm *Dicom) WriteTo(w io.Writer) (int64, error) {
+	cw := countingWriter{w: w}
+	err := dcm.Write(&cw)
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written to the underlying `io.Writer`.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// WriteTo encodes the data set to `w` as a dicom file, according to its (0002,0010)
+// TransferSyntaxUID, returning the number of bytes written. It implements `io.WriterTo`.
+// See: WriteToFile for more information
+func (ds *DataSet) WriteTo(w io.Writer) (int64, error) {
+	cw := countingWriter{w: w}
+	if err := ds.write(&cw, ds.GetTransferSyntax()); err != nil {
+		return cw.n, fmt.Errorf("WriteTo(): %v", err)
+	}
+	return cw.n, nil
+}
+
+// WriteToFile encodes the data set to a file at `path` as a dicom file, according to the transfer
+// syntax `tsuid`: a zeroed preamble, "DICM" magic, the meta group (with recomputed group length),
+// and then the remaining elements, as per `Dicom.Write`. Binary values are converted to the
+// byte order of `tsuid`, but pixel data is not transcoded, as per `Dicom.WriteDataset`.
+// The data set itself is left unchanged. As `Dicom` embeds `DataSet`, this method is shadowed by
+// `Dicom.WriteToFile`, which writes in the dicom's own transfer syntax; to choose the transfer
+// syntax of a dicom, call `dcm.DataSet.WriteToFile`.
+func (ds *DataSet) WriteToFile(path string, tsuid string) error {
+	return writeFile(path, func(w io.Writer) error {
+		if err := ds.write(w, tsuid); err != nil {
+			return fmt.Errorf("WriteToFile(%s): %v", tsuid, err)
+		}
+		return nil
+	})
+}
+
+// write encodes the data set to `w` as a dicom file, according to the transfer syntax `tsuid`.
+func (ds *DataSet) write(w io.Writer, tsuid string) error {
+	enc, found := GetEncodingForTransferSyntax(tsuid)
+	if !found {
+		return fmt.Errorf("transfer syntax %s is not registered", tsuid)
+	}
+	current := ds.GetTransferSyntax()
+	if tsuid != current && (!isNativeTransferSyntax(current) || !isNativeTransferSyntax(tsuid)) {
+		return fmt.Errorf("cannot convert from %s, as pixel data would need transcoding", current)
+	}
+	dcm := newDicom()
+	for _, e := range *ds {
+		dcm.addElement(e)
+	}
+	dcm.addElement(newElementWithData(transferSyntaxTag, []byte(tsuid)))
+	dcm.encoding = enc
+	return dcm.Write(w)
+}
@@ -0,0 +1,540 @@
+package opendcm
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertEquivalentDataSets asserts that each non-meta element of `expected` is present
+// within `actual` with the same value.
+func assertEquivalentDataSets(t *testing.T, expected DataSet, actual DataSet) {
+	for tag, e := range expected {
+		if tag>>16 == 0x0002 {
+			continue
+		}
+		other := NewElement()
+		if !assert.True(t, actual.GetElement(tag, &other), e.GetName()) {
+			continue
+		}
+		data := other.data
+		if len(e.data)%2 != 0 && len(data) == len(e.data)+1 {
+			// odd length values are padded upon write
+			data = data[:len(e.data)]
+		}
+		assert.Equal(t, e.data, data, e.GetName())
+		if assert.Len(t, other.items, len(e.items), e.GetName()) {
+			for i, itm := range e.items {
+				assert.Equal(t, itm.fragment, other.items[i].fragment, e.GetName())
+				assertEquivalentDataSets(t, itm.dataset, other.items[i].dataset)
+			}
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	// ensures that writing a parsed dicom, and parsing the output,
+	// results in an equivalent data set.
+	for _, path := range []string{
+		filepath.Join("testdata", "synthetic", "VRTest.dcm"),
+		filepath.Join("testdata", "synthetic", "ShiftJIS.dcm"),
+		filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"),
+	} {
+		dcm, err := FromFile(path)
+		assert.NoError(t, err)
+		buf := bytes.NewBuffer(nil)
+		assert.NoError(t, dcm.Write(buf), path)
+		written, err := FromReader(buf)
+		assert.NoError(t, err, path)
+		assert.Equal(t, dcm.GetTransferSyntax(), written.GetTransferSyntax(), path)
+		uid := ""
+		_, err = written.GetElementValue(0x00020012, &uid)
+		assert.NoError(t, err)
+		assert.Equal(t, ImplementationClassUID(), uid)
+		assertEquivalentDataSets(t, dcm.DataSet, written.DataSet)
+	}
+}
+
+func TestWriteMetaVersion(t *testing.T) {
+	// ensures that binary values beginning or ending with NULL bytes, such as the version of the
+	// meta group, are written as they were read, by checking the raw bytes written.
+	t.Parallel()
+	versionElement := []byte{
+		0x02, 0x00, 0x01, 0x00, 0x4F, 0x42, 0x00, 0x00, // (0002,0001) OB
+		0x02, 0x00, 0x00, 0x00, // Length: 2 bytes
+		0x00, 0x01, // Data: version 1
+	}
+	dcm, err := FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	e := NewElement()
+	if assert.True(t, dcm.GetElement(0x00020001, &e)) {
+		assert.Equal(t, []byte{0x00, 0x01}, e.data)
+	}
+	document := []byte{0x00, 0x25, 0x50, 0x00}
+	dcm.addElement(newElementWithData(0x00420011, document)) // EncapsulatedDocument, OB
+
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	assert.True(t, bytes.Contains(buf.Bytes(), versionElement))
+	assert.True(t, bytes.Contains(buf.Bytes(), append([]byte{0x42, 0x00, 0x11, 0x00, 0x4F, 0x42, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00}, document...)))
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	if assert.True(t, written.GetElement(0x00420011, &e)) {
+		assert.Equal(t, document, e.data)
+	}
+
+	// nor when writing the data set alone
+	buf.Reset()
+	_, err = dcm.DataSet.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Contains(buf.Bytes(), versionElement))
+}
+
+func TestWriteDeflated(t *testing.T) {
+	// ensures that, when deflation is requested, the data set is
+	// compressed and can be read back.
+	defer OverrideConfig(config)
+	cfg := GetConfig()
+	cfg.DeflateOnWrite = true
+	OverrideConfig(cfg)
+
+	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
+	assert.NoError(t, err)
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	written, err := FromReader(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, DeflatedExplicitVRLittleEndian, written.GetTransferSyntax())
+	assertEquivalentDataSets(t, dcm.DataSet, written.DataSet)
+
+	// and re-written, remains deflated
+	rewritten := bytes.NewBuffer(nil)
+	assert.NoError(t, written.Write(rewritten))
+	reread, err := FromReader(rewritten)
+	assert.NoError(t, err)
+	assertEquivalentDataSets(t, dcm.DataSet, reread.DataSet)
+
+	// compressed pixel data cannot be deflated
+	dcm = newPixelDicom(JPEGBaseline, 1, 1, 1, 8, "MONOCHROME2")
+	assert.Error(t, dcm.Write(bytes.NewBuffer(nil)))
+}
+
+func TestWriteEmptyElements(t *testing.T) {
+	// ensures that present but empty elements remain present, and empty, when written and
+	// read back, whereas absent elements remain absent.
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "ZeroElementLength.dcm"))
+	assert.NoError(t, err)
+	dcm.addElement(NewElementWithTag(0x00100010)) // PatientName
+	dcm.addElement(NewElementWithTag(0x00081140)) // ReferencedImageSequence
+	assert.False(t, dcm.HasElement(0x00100020))   // PatientID
+	for i := 0; i < 2; i++ {
+		buf := bytes.NewBuffer(nil)
+		assert.NoError(t, dcm.Write(buf))
+		dcm, err = FromReader(buf)
+		assert.NoError(t, err)
+		for _, tag := range []uint32{0x00080005, 0x00100010, 0x00081140} {
+			e := NewElement()
+			if assert.True(t, dcm.GetElement(tag, &e), "%08X", tag) {
+				assert.True(t, e.IsEmpty(), "%08X", tag)
+			}
+		}
+		assert.False(t, dcm.HasElement(0x00100020))
+	}
+	name := newStringElement(0x00100010, "DOE^JOHN")
+	assert.False(t, name.IsEmpty())
+}
+
+func TestWriteAddedItems(t *testing.T) {
+	// ensures that a sequence constructed with AddItem is written, and read back, as a sequence.
+	t.Parallel()
+	dcm := newDicom()
+	dcm.addElement(newStringElement(transferSyntaxTag, ExplicitVRLittleEndian))
+	seq := NewElementWithTag(0x00081140) // ReferencedImageSequence
+	for _, uid := range []string{"1.2.3.4", "1.2.3.5"} {
+		itm := NewItem()
+		ds := itm.GetDataSet()
+		ds.addElement(newStringElement(0x00081155, uid))
+		seq.AddItem(itm)
+	}
+	assert.Len(t, seq.Items(), 2)
+	assert.Equal(t, seq.GetItems(), seq.Items())
+	dcm.addElement(seq)
+
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	e := NewElement()
+	if assert.True(t, written.GetElement(0x00081140, &e)) {
+		assert.Equal(t, "SQ", e.GetVR())
+		if assert.Len(t, e.Items(), 2) {
+			uid := ""
+			itm := e.Items()[1]
+			ds := itm.GetDataSet()
+			_, err = ds.GetElementValue(0x00081155, &uid)
+			assert.NoError(t, err)
+			assert.Equal(t, "1.2.3.5", uid)
+		}
+	}
+}
+
+func TestWriteHeaderless(t *testing.T) {
+	// ensures that a data set read without a meta group is written with the
+	// transfer syntax corresponding to the encoding with which it was read.
+	t.Parallel()
+	input := append(make([]byte, 128), "DICM"...)
+	input = append(input, 0x10, 0x00, 0x10, 0x00, 'P', 'N', 0x08, 0x00)
+	input = append(input, "DOE^JOHN"...)
+	dcm, err := FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	assert.False(t, dcm.HasElement(transferSyntaxTag))
+
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ExplicitVRLittleEndian, written.GetTransferSyntax())
+	assertEquivalentDataSets(t, dcm.DataSet, written.DataSet)
+}
+
+func TestDicomWriteTo(t *testing.T) {
+	// ensures that `Dicom.WriteTo` writes as `Dicom.Write` does, rather than as the
+	// promoted `DataSet.WriteTo`, retaining the preamble and the encoding of the source.
+	t.Parallel()
+	input := append(append(make([]byte, 0, 128), "PREAMBLE"...), make([]byte, 120)...)
+	input = append(input, "DICM"...)
+	input = append(input, 0x10, 0x00, 0x10, 0x00, 'P', 'N', 0x08, 0x00)
+	input = append(input, "DOE^JOHN"...)
+	dcm, err := FromReader(bytes.NewReader(input))
+	assert.NoError(t, err)
+	assert.Implements(t, (*io.WriterTo)(nil), &dcm)
+
+	buf := bytes.NewBuffer(nil)
+	n, err := dcm.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, []byte("PREAMBLE"), buf.Bytes()[:8])
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ExplicitVRLittleEndian, written.GetTransferSyntax())
+	assertEquivalentDataSets(t, dcm.DataSet, written.DataSet)
+}
+
+func TestSetTransferSyntax(t *testing.T) {
+	// ensures that converting between little and big endian re-encodes binary values,
+	// such that they are unchanged when read back.
+	t.Parallel()
+	path := filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm")
+	original, err := FromFile(path)
+	assert.NoError(t, err)
+	expectedImage, err := original.Frame(0)
+	assert.NoError(t, err)
+	dcm, err := FromFile(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, dcm.SetTransferSyntax(ExplicitVRBigEndian))
+	assert.Equal(t, ExplicitVRBigEndian, dcm.GetTransferSyntax())
+	rows, expectedRows := uint16(0), uint16(0)
+	_, err = original.GetElementValue(0x00280010, &expectedRows)
+	assert.NoError(t, err)
+	_, err = dcm.GetElementValue(0x00280010, &rows)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRows, rows)
+	img, err := dcm.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedImage, img)
+
+	// written and read back as big endian
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ExplicitVRBigEndian, written.GetTransferSyntax())
+	_, err = written.GetElementValue(0x00280010, &rows)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRows, rows)
+	img, err = written.Frame(0)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedImage, img)
+
+	// and back to little endian
+	assert.NoError(t, written.SetTransferSyntax(ExplicitVRLittleEndian))
+	assertEquivalentDataSets(t, original.DataSet, written.DataSet)
+
+	// compressed transfer syntaxes cannot be converted to or from
+	assert.Error(t, written.SetTransferSyntax(JPEGBaseline))
+	assert.Error(t, written.SetTransferSyntax("1.2.3.4"))
+	compressed := newPixelDicom(JPEGBaseline, 1, 1, 1, 8, "MONOCHROME2")
+	assert.Error(t, compressed.SetTransferSyntax(ExplicitVRLittleEndian))
+}
+
+func TestWriteDataset(t *testing.T) {
+	// ensures that only the data set is written, and that it can be read back
+	// in the given transfer syntax, including native pixel data of a differing byte order.
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "synthetic", "VRTest.dcm"))
+	assert.NoError(t, err)
+	for _, ts := range []string{ImplicitVRLittleEndian, ExplicitVRLittleEndian} {
+		buf := bytes.NewBuffer(nil)
+		assert.NoError(t, dcm.WriteDataset(buf, ts), ts)
+		assert.False(t, bytes.Contains(buf.Bytes(), dicmTestString), ts)
+		written, err := FromReader(buf)
+		assert.NoError(t, err, ts)
+		assert.False(t, written.HasElement(transferSyntaxTag), ts)
+		enc, _ := GetEncodingForTransferSyntax(ts)
+		assert.Equal(t, enc, written.encoding, ts)
+		assertEquivalentDataSets(t, dcm.DataSet, written.DataSet)
+	}
+
+	dcm, err = FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.WriteDataset(buf, ExplicitVRBigEndian))
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, Encoding{ImplicitVR: false, LittleEndian: false}, written.encoding)
+	expected, err := dcm.GetPixelData().Samples(16, 0)
+	assert.NoError(t, err)
+	samples, err := written.GetPixelData().Samples(16, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, samples)
+	// the dicom itself is unchanged
+	assert.Equal(t, ExplicitVRLittleEndian, dcm.GetTransferSyntax())
+	assert.True(t, dcm.GetPixelData().isLittleEndian)
+
+	// pixel data cannot be transcoded
+	assert.Error(t, dcm.WriteDataset(buf, JPEGBaseline))
+	assert.Error(t, dcm.WriteDataset(buf, "1.2.3.4"))
+}
+
+func TestDataSetWriteToFile(t *testing.T) {
+	// ensures that a data set can be written in each uncompressed transfer syntax, and read back
+	// as an equivalent data set, without modifying the data set itself.
+	t.Parallel()
+	dcm, err := FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	tmpdir, err := ioutil.TempDir("", "opendcm")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	for _, ts := range []string{ImplicitVRLittleEndian, ExplicitVRLittleEndian, ExplicitVRBigEndian} {
+		path := filepath.Join(tmpdir, ts+".dcm")
+		assert.NoError(t, dcm.DataSet.WriteToFile(path, ts), ts)
+		written, err := FromFile(path)
+		if !assert.NoError(t, err, ts) {
+			continue
+		}
+		assert.Equal(t, ts, written.GetTransferSyntax())
+		if ts == ExplicitVRBigEndian {
+			// values were converted, so are compared once converted back
+			assert.NoError(t, written.SetTransferSyntax(ExplicitVRLittleEndian))
+		}
+		assertEquivalentDataSets(t, dcm.DataSet, written.DataSet)
+	}
+	assert.Equal(t, ExplicitVRLittleEndian, dcm.GetTransferSyntax())
+
+	// WriteTo uses the data set's own transfer syntax
+	buf := bytes.NewBuffer(nil)
+	n, err := dcm.DataSet.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ExplicitVRLittleEndian, written.GetTransferSyntax())
+	assertEquivalentDataSets(t, dcm.DataSet, written.DataSet)
+
+	// pixel data cannot be transcoded
+	assert.Error(t, dcm.DataSet.WriteToFile(filepath.Join(tmpdir, "compressed.dcm"), JPEGBaseline))
+	assert.Error(t, dcm.DataSet.WriteToFile(filepath.Join(tmpdir, "unknown.dcm"), "1.2.3.4"))
+}
+
+func TestWriteLazy(t *testing.T) {
+	// ensures that values skipped during a lazy parse are not written as empty values,
+	// but can be written once loaded.
+	t.Parallel()
+	path := filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm")
+	expected, err := FromFile(path)
+	assert.NoError(t, err)
+	dcm, result, err := FromFileLazy(path)
+	assert.NoError(t, err)
+	assert.True(t, result.Valid)
+	buf := bytes.NewBuffer(nil)
+	assert.Error(t, dcm.Write(buf))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	for tag, e := range dcm.DataSet {
+		if e.IsLazy() {
+			assert.NoError(t, e.LoadValue(f))
+			dcm.DataSet[tag] = e
+		}
+	}
+	buf.Reset()
+	assert.NoError(t, dcm.Write(buf))
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	assertEquivalentDataSets(t, expected.DataSet, written.DataSet)
+
+	// the fragments of encapsulated pixel data are discarded, so cannot be written either
+	pixelData := NewElementWithTag(pixelDataTag)
+	assert.NoError(t, pixelData.SetValue([][]byte{make([]byte, lazyValueThreshold+2)}))
+	buf.Reset()
+	ds := newDataSet(pixelData)
+	_, err = ds.WriteTo(buf)
+	assert.NoError(t, err)
+	dcm, result = FromReaderLazy(buf)
+	assert.True(t, result.Valid)
+	assert.Error(t, dcm.Write(bytes.NewBuffer(nil)))
+}
+
+func TestEncapsulate(t *testing.T) {
+	// ensures that frames are encapsulated with an offset table which locates each,
+	// and that encapsulated pixel data is read and re-written unchanged.
+	t.Parallel()
+	pd := newPixelData()
+	encapsulated := pd.Encapsulate([][]byte{{0x01, 0x02, 0x03}, {0x04, 0x05, 0x06, 0x07}}, true)
+	assert.Equal(t, []byte{
+		0xFE, 0xFF, 0x00, 0xE0, 0x08, 0x00, 0x00, 0x00, // offset table
+		0x00, 0x00, 0x00, 0x00, 0x0C, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0x00, 0xE0, 0x04, 0x00, 0x00, 0x00, // odd length frame, padded
+		0x01, 0x02, 0x03, 0x00,
+		0xFE, 0xFF, 0x00, 0xE0, 0x04, 0x00, 0x00, 0x00,
+		0x04, 0x05, 0x06, 0x07,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00, // sequence delimitation
+	}, encapsulated)
+	assert.Equal(t, []byte{0xFE, 0xFF, 0x00, 0xE0, 0x00, 0x00, 0x00, 0x00},
+		pd.Encapsulate([][]byte{{0x01, 0x02, 0x03}}, false)[:8])
+
+	// newEncapsulatedDicom encodes a JPEG baseline dicom holding the encapsulated `value`
+	newEncapsulatedDicom := func(value []byte) []byte {
+		input := append(make([]byte, 128), "DICM"...)
+		input = append(input, 0x02, 0x00, 0x10, 0x00, 'U', 'I', byte(len(JPEGBaseline)+1), 0x00)
+		input = append(append(input, JPEGBaseline...), 0x00)
+		input = append(input, 0xE0, 0x7F, 0x10, 0x00, 'O', 'B', 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF)
+		return append(input, value...)
+	}
+	dcm, err := FromReader(bytes.NewReader(newEncapsulatedDicom(encapsulated)))
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, dcm.GetPixelData().NumFrames()) {
+		assert.Equal(t, []byte{0x01, 0x02, 0x03, 0x00}, dcm.GetPixelData().GetFrame(0))
+		assert.Equal(t, []byte{0x04, 0x05, 0x06, 0x07}, dcm.GetPixelData().GetFrame(1))
+	}
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), encapsulated))
+
+	// a frame split across fragments is located by the offset table
+	fragmented := encapsulate([]byte{0x00, 0x00, 0x00, 0x00, 0x14, 0x00, 0x00, 0x00},
+		[][]byte{{0x01, 0x02}, {0x03, 0x04}, {0x05, 0x06}})
+	dcm, err = FromReader(bytes.NewReader(newEncapsulatedDicom(fragmented)))
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, dcm.GetPixelData().NumFrames()) {
+		assert.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, dcm.GetPixelData().GetFrame(0))
+		assert.Equal(t, []byte{0x05, 0x06}, dcm.GetPixelData().GetFrame(1))
+	}
+}
+
+func TestWriteStrictVRLength(t *testing.T) {
+	// ensures that, in strict mode, elements exceeding the length permitted by their VR are not written.
+	defer OverrideConfig(config)
+	dcm := newDicom()
+	dcm.addElement(newStringElement(transferSyntaxTag, ExplicitVRLittleEndian))
+	dcm.addElement(newStringElement(0x00080060, "SEVENTEEN_BYTES_X"))
+	assert.NoError(t, dcm.Write(bytes.NewBuffer(nil)))
+	cfg := GetConfig()
+	cfg.StrictMode = true
+	OverrideConfig(cfg)
+	err := dcm.Write(bytes.NewBuffer(nil))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "exceeding the maximum of 16 for VR CS")
+	}
+}
+
+func TestWriteSyncSOPUIDs(t *testing.T) {
+	// ensures that, when requested, the meta SOP identifiers are written from the data set.
+	defer OverrideConfig(config)
+	dcm := newDicom()
+	dcm.addElement(newStringElement(transferSyntaxTag, ExplicitVRLittleEndian))
+	dcm.addElement(newStringElement(mediaStorageSOPInstanceUIDTag, "1.2.3.4"))
+	dcm.addElement(newStringElement(sopInstanceUIDTag, "1.2.3.5"))
+	assert.Error(t, dcm.CheckSOPConsistency())
+
+	// by default, existing meta elements are left alone
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	assert.Error(t, written.CheckSOPConsistency())
+
+	cfg := GetConfig()
+	cfg.SyncSOPUIDsOnWrite = true
+	OverrideConfig(cfg)
+	buf = bytes.NewBuffer(nil)
+	assert.NoError(t, dcm.Write(buf))
+	written, err = FromReader(buf)
+	assert.NoError(t, err)
+	assert.NoError(t, written.CheckSOPConsistency())
+	uid := ""
+	_, err = written.GetElementValue(mediaStorageSOPInstanceUIDTag, &uid)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.5", strings.TrimRight(uid, "\x00"))
+}
+
+func TestNormalize(t *testing.T) {
+	// ensures that odd length values are padded, retired group lengths are removed, the
+	// meta SOP identifiers are synchronised, and that the meta group length is recomputed.
+	t.Parallel()
+	dcm := newDicom()
+	dcm.addElement(newElementWithData(0x00020000, []byte{0xE7, 0x03, 0x00, 0x00}))
+	dcm.addElement(newStringElement(transferSyntaxTag, ExplicitVRLittleEndian))
+	dcm.addElement(newStringElement(mediaStorageSOPInstanceUIDTag, "1.2.3.4"))
+	dcm.addElement(newStringElement(sopInstanceUIDTag, "1.2.3.5"))
+	dcm.addElement(newElementWithData(0x00080000, []byte{0x01, 0x00, 0x00, 0x00}))
+	dcm.addElement(newStringElement(0x00100010, "DOE^JOH"))
+	dcm.addElement(newSequence(0x00081140, newDataSet(
+		newElementWithData(0x00080000, []byte{0x0E, 0x00, 0x00, 0x00}),
+		newStringElement(0x00081155, "1.2.3"),
+	)))
+	assert.Error(t, dcm.CheckSOPConsistency())
+	assert.NoError(t, dcm.Normalize())
+
+	assert.NoError(t, dcm.CheckSOPConsistency())
+	assert.False(t, dcm.HasElement(0x00080000))
+	name := ""
+	_, err := dcm.GetElementValue(0x00100010, &name)
+	assert.NoError(t, err)
+	assert.Equal(t, "DOE^JOH ", name)
+	e := NewElement()
+	assert.True(t, dcm.GetElement(0x00081140, &e))
+	ds := e.Items()[0].GetDataSet()
+	assert.False(t, ds.HasElement(0x00080000))
+	assert.True(t, ds.GetElement(0x00081155, &e))
+	assert.Equal(t, []byte("1.2.3\x00"), e.data)
+
+	// (0002,0003) and (0002,0010), each of 8 header bytes, and values of 8 and 20 bytes
+	length := uint32(0)
+	_, err = dcm.GetElementValue(0x00020000, &length)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(44), length)
+
+	// the padded transfer syntax is still recognised, and the meta version is unaltered
+	assert.Equal(t, ExplicitVRLittleEndian, dcm.GetTransferSyntax())
+	dcm, err = FromFile(filepath.Join("testdata", "TCIA", "1.3.12.2.1107.5.1.4.1001.30000013072513125762500009613.dcm"))
+	assert.NoError(t, err)
+	assert.NoError(t, dcm.Normalize())
+	assert.True(t, dcm.GetElement(0x00020001, &e))
+	assert.Equal(t, []byte{0x00, 0x01}, e.data)
+	buf := bytes.NewBuffer(nil)
+	_, err = dcm.DataSet.WriteTo(buf)
+	assert.NoError(t, err)
+	written, err := FromReader(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, ExplicitVRLittleEndian, written.GetTransferSyntax())
+}